@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const cachePurgeTokenEnvKey = "WG_CACHE_PURGE_TOKEN"
+
+var (
+	cachePurgeOperation string
+	cachePurgeTag       string
+	cachePurgeNodeUrl   string
+	cachePurgeApiPrefix string
+	cachePurgeToken     string
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Subcommand to work with the cache of a WunderGraph node",
+}
+
+var cachePurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Purge evicts cached operation responses by operation name or tag",
+	Long: `
+		Purge requires the node to be configured with a cache purge token.
+		The token is read from the --token flag or the ` + cachePurgeTokenEnvKey + ` environment variable.
+
+		Example usage:
+			wunderctl cache purge --operation Users
+			wunderctl cache purge --tag users --node-url https://api.example.com
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (cachePurgeOperation == "") == (cachePurgeTag == "") {
+			return errors.New("either --operation or --tag is required")
+		}
+		token := cachePurgeToken
+		if token == "" {
+			token = os.Getenv(cachePurgeTokenEnvKey)
+		}
+		if token == "" {
+			return fmt.Errorf("--token or %s is required", cachePurgeTokenEnvKey)
+		}
+
+		body, err := json.Marshal(map[string]string{
+			"operationName": cachePurgeOperation,
+			"tag":           cachePurgeTag,
+		})
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("%s/internal/%s/cache/purge", strings.TrimSuffix(cachePurgeNodeUrl, "/"), strings.Trim(cachePurgeApiPrefix, "/"))
+		req, err := http.NewRequestWithContext(cmd.Context(), http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		res, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("could not reach node: %w", err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusNoContent {
+			message, _ := io.ReadAll(res.Body)
+			return fmt.Errorf("purge failed with status %d: %s", res.StatusCode, strings.TrimSpace(string(message)))
+		}
+
+		_, _ = green.Println("Cache purged")
+		return nil
+	},
+}
+
+func init() {
+	cachePurgeCmd.Flags().StringVar(&cachePurgeOperation, "operation", "", "name of the operation whose cached responses are purged")
+	cachePurgeCmd.Flags().StringVar(&cachePurgeTag, "tag", "", "cache tag whose responses are purged")
+	cachePurgeCmd.Flags().StringVar(&cachePurgeNodeUrl, "node-url", "http://localhost:9991", "url of the WunderGraph node")
+	cachePurgeCmd.Flags().StringVar(&cachePurgeApiPrefix, "api-prefix", "api/main", "path prefix of the API, i.e. the api name and deployment name")
+	cachePurgeCmd.Flags().StringVar(&cachePurgeToken, "token", "", "cache purge token configured on the node")
+
+	cacheCmd.AddCommand(cachePurgeCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wundergraph/wundergraph/pkg/fixtures"
+)
+
+var (
+	fixturesMode    string
+	fixturesDir     string
+	fixturesHeaders []string
+)
+
+func addFixturesFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&fixturesMode, "fixtures", "", "records the responses of all origins as fixtures (record) or serves the recorded fixtures without network access (replay)")
+	cmd.Flags().StringVar(&fixturesDir, "fixtures-dir", "fixtures", "directory of the fixtures, relative to the WunderGraph directory")
+	cmd.Flags().StringSliceVar(&fixturesHeaders, "fixtures-header", nil, "request headers identifying a fixture in addition to the method, URL and body, e.g. Authorization")
+}
+
+// fixturesOptions returns the fixtures configured by the flags, the directory is resolved relative to wunderGraphDir
+func fixturesOptions(wunderGraphDir string) (fixtures.Options, error) {
+	mode, err := fixtures.ParseMode(fixturesMode)
+	if err != nil {
+		return fixtures.Options{}, err
+	}
+	dir := fixturesDir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(wunderGraphDir, dir)
+	}
+	return fixtures.Options{
+		Mode:    mode,
+		Dir:     dir,
+		Headers: fixturesHeaders,
+	}, nil
+}
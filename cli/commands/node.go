@@ -21,6 +21,8 @@ import (
 	"github.com/wundergraph/wundergraph/pkg/wgpb"
 )
 
+var apiConfigFiles []string
+
 var nodeCmd = &cobra.Command{
 	Use:   "node",
 	Short: "Subcommand to work with WunderGraph node",
@@ -32,6 +34,7 @@ var nodeStartCmd = &cobra.Command{
 	Long: `
 		Example usage:
 			wunderctl node start
+			wunderctl node start --api-config users/wundergraph.config.json --api-config posts/wundergraph.config.json
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -65,6 +68,10 @@ func init() {
 	rootCmd.AddCommand(nodeCmd)
 
 	nodeStartCmd.Flags().IntVar(&shutdownAfterIdle, "shutdown-after-idle", 0, "shuts down the server after given seconds in idle when no requests have been served")
+	addFixturesFlags(nodeStartCmd)
+	addRemoteConfigFlags(nodeStartCmd)
+	addReadinessFlags(nodeStartCmd)
+	nodeStartCmd.Flags().StringSliceVar(&apiConfigFiles, "api-config", nil, "serves the APIs of multiple config files, each API reloads when its file changes")
 }
 
 func NewWunderGraphNode(ctx context.Context) (*node.Node, error) {
@@ -101,37 +108,25 @@ func StartWunderGraphNode(n *node.Node, opts ...Option) error {
 		opts[i](&options)
 	}
 
-	configFile := path.Join(n.WundergraphDir, "generated", configJsonFilename)
-	if !files.FileExists(configFile) {
-		return fmt.Errorf("could not find configuration file: %s", configFile)
-	}
-
-	data, err := ioutil.ReadFile(configFile)
+	configOption, err := remoteConfigOption()
 	if err != nil {
-		log.Error("Failed to read file", abstractlogger.String("filePath", configFile), abstractlogger.Error(err))
 		return err
 	}
-
-	if len(data) == 0 {
-		log.Error("Config file is empty", abstractlogger.String("filePath", configFile))
-		return errors.New("config file is empty")
-	}
-
-	var graphConfig wgpb.WunderGraphConfiguration
-	err = json.Unmarshal(data, &graphConfig)
-	if err != nil {
-		log.Error("Failed to unmarshal", abstractlogger.String("filePath", configFile), abstractlogger.Error(err))
-		return errors.New("failed to unmarshal config file")
+	if len(apiConfigFiles) > 0 {
+		if configOption != nil {
+			return errors.New("--api-config can't be combined with --config-url")
+		}
+		configOption = node.WithFileSystemConfigs(apiConfigFiles...)
 	}
-
-	wunderNodeConfig, err := node.CreateConfig(&graphConfig)
-	if err != nil {
-		log.Error("Failed to create config", abstractlogger.String("filePath", configFile), abstractlogger.Error(err))
-		return err
+	if configOption == nil {
+		configOption, err = staticConfigOption(n.WundergraphDir)
+		if err != nil {
+			return err
+		}
 	}
 
 	nodeOpts := []node.Option{
-		node.WithStaticWunderNodeConfig(wunderNodeConfig),
+		configOption,
 		node.WithDebugMode(rootFlags.DebugMode),
 		node.WithForceHttpsRedirects(!disableForceHttpsRedirects),
 		node.WithIntrospection(enableIntrospection),
@@ -147,6 +142,15 @@ func StartWunderGraphNode(n *node.Node, opts ...Option) error {
 	if options.hooksServerHealthCheck {
 		nodeOpts = append(nodeOpts, node.WithHooksServerHealthCheck(time.Duration(healthCheckTimeout)*time.Second))
 	}
+	if readiness := readinessOption(); readiness != nil {
+		nodeOpts = append(nodeOpts, readiness)
+	}
+
+	fixtures, err := fixturesOptions(n.WundergraphDir)
+	if err != nil {
+		return err
+	}
+	nodeOpts = append(nodeOpts, node.WithFixtures(fixtures))
 
 	err = n.StartBlocking(nodeOpts...)
 	if err != nil {
@@ -155,3 +159,37 @@ func StartWunderGraphNode(n *node.Node, opts ...Option) error {
 
 	return nil
 }
+
+// staticConfigOption loads the generated config file
+func staticConfigOption(wunderGraphDir string) (node.Option, error) {
+	configFile := path.Join(wunderGraphDir, "generated", configJsonFilename)
+	if !files.FileExists(configFile) {
+		return nil, fmt.Errorf("could not find configuration file: %s", configFile)
+	}
+
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		log.Error("Failed to read file", abstractlogger.String("filePath", configFile), abstractlogger.Error(err))
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		log.Error("Config file is empty", abstractlogger.String("filePath", configFile))
+		return nil, errors.New("config file is empty")
+	}
+
+	var graphConfig wgpb.WunderGraphConfiguration
+	err = json.Unmarshal(data, &graphConfig)
+	if err != nil {
+		log.Error("Failed to unmarshal", abstractlogger.String("filePath", configFile), abstractlogger.Error(err))
+		return nil, errors.New("failed to unmarshal config file")
+	}
+
+	wunderNodeConfig, err := node.CreateConfig(&graphConfig)
+	if err != nil {
+		log.Error("Failed to create config", abstractlogger.String("filePath", configFile), abstractlogger.Error(err))
+		return nil, err
+	}
+
+	return node.WithStaticWunderNodeConfig(wunderNodeConfig), nil
+}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wundergraph/wundergraph/pkg/node"
+)
+
+var (
+	readinessProbes       bool
+	readinessProbeTimeout time.Duration
+)
+
+func addReadinessFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&readinessProbes, "readiness-probes", false, "probes the datasources and the cache of the API on /health/ready, database probes start stopped query engines")
+	cmd.Flags().DurationVar(&readinessProbeTimeout, "readiness-probe-timeout", 5*time.Second, "timeout of each readiness probe, unless its datasource configures a request timeout")
+}
+
+// readinessOption returns the readiness probes configured by the flags, or nil if they're disabled
+func readinessOption() node.Option {
+	if !readinessProbes {
+		return nil
+	}
+	return node.WithReadinessProbes(readinessProbeTimeout)
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wundergraph/wundergraph/pkg/node"
+	"github.com/wundergraph/wundergraph/pkg/remoteconfig"
+)
+
+const configPublicKeyEnvKey = "WG_CONFIG_PUBLIC_KEY"
+
+var (
+	configURL          string
+	configPublicKey    string
+	configPollInterval time.Duration
+	configS3Endpoint   string
+	configS3Region     string
+	configS3DisableSSL bool
+)
+
+func addRemoteConfigFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&configURL, "config-url", "", "loads the config from an http(s):// URL or s3://bucket/key instead of the generated config file and polls it for changes")
+	cmd.Flags().StringVar(&configPublicKey, "config-public-key", "", "Ed25519 public key (PEM or base64) verifying the signature of the remote config, defaults to $"+configPublicKeyEnvKey)
+	cmd.Flags().DurationVar(&configPollInterval, "config-poll-interval", 10*time.Second, "interval to poll the remote config for changes")
+	cmd.Flags().StringVar(&configS3Endpoint, "config-s3-endpoint", "s3.amazonaws.com", "endpoint of the S3-compatible service storing the remote config")
+	cmd.Flags().StringVar(&configS3Region, "config-s3-region", "", "region of the bucket storing the remote config")
+	cmd.Flags().BoolVar(&configS3DisableSSL, "config-s3-disable-ssl", false, "connects to the S3-compatible service without TLS")
+}
+
+// remoteConfigOption returns the remote config configured by the flags, or nil if the node should use the config file
+func remoteConfigOption() (node.Option, error) {
+	if configURL == "" {
+		return nil, nil
+	}
+	key := configPublicKey
+	if key == "" {
+		key = os.Getenv(configPublicKeyEnvKey)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("--config-public-key or %s is required to verify the remote config", configPublicKeyEnvKey)
+	}
+	publicKey, err := remoteconfig.ParsePublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := url.Parse(configURL)
+	if err != nil {
+		return nil, err
+	}
+	var source remoteconfig.Source
+	switch location.Scheme {
+	case "http", "https":
+		source = remoteconfig.NewHTTPSource(remoteconfig.HTTPOptions{
+			URL: configURL,
+		})
+	case "s3":
+		objectKey := strings.TrimPrefix(location.Path, "/")
+		if location.Host == "" || objectKey == "" {
+			return nil, errors.New("--config-url must be s3://bucket/key")
+		}
+		source, err = remoteconfig.NewS3Source(remoteconfig.S3Options{
+			Endpoint: configS3Endpoint,
+			Bucket:   location.Host,
+			Key:      objectKey,
+			Region:   configS3Region,
+			UseSSL:   !configS3DisableSSL,
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported --config-url scheme %q, must be http, https or s3", location.Scheme)
+	}
+	return node.WithRemoteConfig(source, publicKey, configPollInterval), nil
+}
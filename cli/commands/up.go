@@ -256,6 +256,11 @@ var upCmd = &cobra.Command{
 			}
 		}()
 
+		fixtures, err := fixturesOptions(wunderGraphDir)
+		if err != nil {
+			return err
+		}
+
 		n := node.New(ctx, BuildInfo, wunderGraphDir, log)
 		go func() {
 			configFile := path.Join(wunderGraphDir, "generated", "wundergraph.config.json")
@@ -267,6 +272,7 @@ var upCmd = &cobra.Command{
 				node.WithIntrospection(true),
 				node.WithGitHubAuthDemo(GitHubAuthDemo),
 				node.WithDevMode(),
+				node.WithFixtures(fixtures),
 			)
 			if err != nil {
 				log.Error("node exited", abstractlogger.Error(err))
@@ -295,6 +301,7 @@ var upCmd = &cobra.Command{
 
 func init() {
 	upCmd.PersistentFlags().BoolVar(&upCmdPrettyLogging, "pretty-logging", true, "switches the logging to human readable format")
+	addFixturesFlags(upCmd)
 
 	rootCmd.AddCommand(upCmd)
 }
@@ -0,0 +1,76 @@
+// Package statusrecorder implements an http.ResponseWriter wrapper
+// capturing the status code written by a handler.
+package statusrecorder
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter records the status code of the response.
+// It implements http.Flusher, http.Hijacker and http.Pusher on top of the wrapped writer,
+// so it can wrap the writers of streaming responses and WebSocket upgrades.
+type ResponseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func New(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{
+		ResponseWriter: w,
+		statusCode:     http.StatusOK,
+	}
+}
+
+// StatusCode returns the status code written so far, http.StatusOK if none was written explicitly
+func (w *ResponseWriter) StatusCode() int {
+	return w.statusCode
+}
+
+func (w *ResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.statusCode = statusCode
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *ResponseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *ResponseWriter) Flush() {
+	w.wroteHeader = true
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands over the connection, the response counts as switching protocols
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && !w.wroteHeader {
+		w.statusCode = http.StatusSwitchingProtocols
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
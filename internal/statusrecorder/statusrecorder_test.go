@@ -0,0 +1,47 @@
+package statusrecorder
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseWriter_Hijack(t *testing.T) {
+	statusCodes := make(chan int, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := New(w)
+		conn, rw, err := recorder.Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\n")
+		_ = rw.Flush()
+		statusCodes <- recorder.StatusCode()
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	assert.Equal(t, http.StatusSwitchingProtocols, <-statusCodes)
+}
+
+func TestResponseWriter_Unsupported(t *testing.T) {
+	w := httptest.NewRecorder()
+	recorder := New(w)
+	_, _, err := recorder.Hijack()
+	assert.ErrorIs(t, err, http.ErrNotSupported)
+	assert.ErrorIs(t, recorder.Push("/style.css", nil), http.ErrNotSupported)
+	assert.Same(t, w, recorder.Unwrap())
+}
@@ -70,6 +70,8 @@ export const RunTemplateTest = async (...templates: Template[]) => {
 							environmentVariableDefaultValue: '',
 							placeholderVariableName: '',
 						},
+						tls: undefined,
+						h2c: false,
 					},
 					logger: {
 						level: {
@@ -81,6 +83,12 @@ export const RunTemplateTest = async (...templates: Template[]) => {
 						},
 					},
 					defaultRequestTimeoutSeconds: 0,
+					metrics: undefined,
+					tracing: undefined,
+					rateLimit: undefined,
+					cachePurgeToken: undefined,
+					cache: undefined,
+					server: undefined,
 				},
 				serverOptions: {
 					serverUrl: {
@@ -105,6 +113,8 @@ export const RunTemplateTest = async (...templates: Template[]) => {
 							environmentVariableDefaultValue: '',
 							placeholderVariableName: '',
 						},
+						tls: undefined,
+						h2c: false,
 					},
 					logger: {
 						level: {
@@ -115,6 +125,7 @@ export const RunTemplateTest = async (...templates: Template[]) => {
 							placeholderVariableName: '',
 						},
 					},
+					hooks: undefined,
 				},
 				application: {
 					Name: 'Test',
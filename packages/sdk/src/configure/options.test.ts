@@ -0,0 +1,263 @@
+import {
+	AcmeChallengeKind,
+	AcmeStorageKind,
+	ApiCacheKind,
+	CacheCompression,
+	ConfigurationVariableKind,
+	HookFailurePolicy,
+	RateLimitStoreKind,
+	RedisTopology,
+	TracingExporterKind,
+} from '@wundergraph/protobuf';
+import { resolveNodeOptions, resolveServerOptions, serverOptionsWithDefaults } from './options';
+import { EnvironmentVariable } from './variables';
+
+const staticVariable = (value: string) => ({
+	kind: ConfigurationVariableKind.STATIC_CONFIGURATION_VARIABLE,
+	staticVariableContent: value,
+	environmentVariableName: '',
+	environmentVariableDefaultValue: '',
+	placeholderVariableName: '',
+});
+
+test('resolveNodeOptions metrics', () => {
+	expect(resolveNodeOptions().metrics).toBeUndefined();
+	expect(resolveNodeOptions({ metrics: { enabled: false } }).metrics).toBeUndefined();
+
+	expect(resolveNodeOptions({ metrics: { enabled: true } }).metrics).toEqual({
+		enabled: true,
+		listen: {
+			host: staticVariable('localhost'),
+			port: staticVariable('8881'),
+			tls: undefined,
+			h2c: false,
+		},
+	});
+
+	const metrics = resolveNodeOptions({
+		metrics: { enabled: true, listen: { host: '0.0.0.0', port: new EnvironmentVariable('METRICS_PORT', '9090') } },
+	}).metrics;
+	expect(metrics?.listen?.host).toEqual(staticVariable('0.0.0.0'));
+	expect(metrics?.listen?.port).toEqual({
+		kind: ConfigurationVariableKind.ENV_CONFIGURATION_VARIABLE,
+		staticVariableContent: '',
+		environmentVariableName: 'METRICS_PORT',
+		environmentVariableDefaultValue: '9090',
+		placeholderVariableName: '',
+	});
+});
+
+test('resolveNodeOptions tracing', () => {
+	expect(resolveNodeOptions().tracing).toBeUndefined();
+
+	expect(resolveNodeOptions({ tracing: { enabled: true } }).tracing).toEqual({
+		enabled: true,
+		exporter: TracingExporterKind.OTLP_TRACING_EXPORTER,
+		otlpEndpoint: undefined,
+		filePath: undefined,
+	});
+	expect(resolveNodeOptions({ tracing: { enabled: true, exporter: 'file', filePath: 'spans.json' } }).tracing).toEqual({
+		enabled: true,
+		exporter: TracingExporterKind.FILE_TRACING_EXPORTER,
+		otlpEndpoint: undefined,
+		filePath: staticVariable('spans.json'),
+	});
+	expect(() => resolveNodeOptions({ tracing: { enabled: true, exporter: 'file' } })).toThrow();
+});
+
+test('resolveNodeOptions rateLimit', () => {
+	expect(resolveNodeOptions().rateLimit).toBeUndefined();
+
+	expect(resolveNodeOptions({ rateLimit: { trustedProxies: ['10.0.0.0/8'] } }).rateLimit).toEqual({
+		store: RateLimitStoreKind.IN_MEMORY_RATE_LIMIT_STORE,
+		redisUrl: undefined,
+		trustedProxies: [staticVariable('10.0.0.0/8')],
+	});
+	expect(resolveNodeOptions({ rateLimit: { store: 'redis', redisUrl: 'redis://localhost:6379' } }).rateLimit).toEqual({
+		store: RateLimitStoreKind.REDIS_RATE_LIMIT_STORE,
+		redisUrl: staticVariable('redis://localhost:6379'),
+		trustedProxies: [],
+	});
+	expect(() => resolveNodeOptions({ rateLimit: { store: 'redis' } })).toThrow();
+});
+
+test('resolveNodeOptions cachePurgeToken', () => {
+	expect(resolveNodeOptions().cachePurgeToken).toBeUndefined();
+	expect(resolveNodeOptions({ cachePurgeToken: 'secret' }).cachePurgeToken).toEqual(staticVariable('secret'));
+});
+
+test('resolveNodeOptions cache', () => {
+	expect(resolveNodeOptions().cache).toBeUndefined();
+
+	expect(resolveNodeOptions({ cache: { kind: 'none' } }).cache).toEqual({
+		kind: ApiCacheKind.NO_CACHE,
+		inMemoryConfig: undefined,
+		redisConfig: undefined,
+	});
+	expect(resolveNodeOptions({ cache: { memory: { maxSize: 1024 } } }).cache?.inMemoryConfig?.maxSize).toEqual(1024);
+	const memory = { compression: 'brotli', minCompressionSize: 512, largeItemSize: 1 << 20 } as const;
+	expect(resolveNodeOptions({ cache: { memory } }).cache?.inMemoryConfig).toEqual({
+		maxSize: 0,
+		maxItemSize: 0,
+		compression: CacheCompression.BROTLI_CACHE_COMPRESSION,
+		minCompressionSize: 512,
+		largeItemSize: 1 << 20,
+		largeItemMinRequests: 0,
+	});
+
+	const cache = resolveNodeOptions({
+		cache: {
+			kind: 'redis',
+			redis: {
+				topology: 'sentinel',
+				addresses: ['sentinel-1:26379', 'sentinel-2:26379'],
+				sentinelMasterName: 'main',
+				password: new EnvironmentVariable('REDIS_PASSWORD'),
+				tls: { enabled: true },
+				localCacheSize: 1000,
+			},
+		},
+	}).cache;
+	expect(cache?.kind).toEqual(ApiCacheKind.REDIS_CACHE);
+	expect(cache?.inMemoryConfig).toBeUndefined();
+	expect(cache?.redisConfig).toMatchObject({
+		redisUrl: undefined,
+		topology: RedisTopology.SENTINEL_REDIS_TOPOLOGY,
+		addresses: [staticVariable('sentinel-1:26379'), staticVariable('sentinel-2:26379')],
+		sentinelMasterName: 'main',
+		password: { kind: ConfigurationVariableKind.ENV_CONFIGURATION_VARIABLE, environmentVariableName: 'REDIS_PASSWORD' },
+		tls: { enabled: true, insecureSkipVerify: false, caCert: undefined, serverName: undefined },
+		localCacheSize: 1000,
+	});
+
+	expect(() => resolveNodeOptions({ cache: { kind: 'redis' } })).toThrow();
+	expect(() => resolveNodeOptions({ cache: { kind: 'redis', redis: { topology: 'cluster' } } })).toThrow();
+	expect(() =>
+		resolveNodeOptions({ cache: { kind: 'redis', redis: { topology: 'sentinel', addresses: ['sentinel-1:26379'] } } })
+	).toThrow();
+});
+
+test('resolveServerOptions hooks', () => {
+	expect(resolveServerOptions(serverOptionsWithDefaults()).hooks).toBeUndefined();
+
+	const hooks = resolveServerOptions(
+		serverOptionsWithDefaults({
+			hooks: {
+				unixSocket: '/tmp/wundergraph-hooks.sock',
+				h2c: true,
+				defaultPolicy: { timeoutSeconds: 5, maxRetries: 1 },
+				policies: [
+					{ hooks: ['mutatingPreResolve', 'mutatingPostResolve'], maxRetries: 0 },
+					{ hooks: ['preResolve'], timeoutSeconds: 1 },
+				],
+			},
+		})
+	).hooks;
+	expect(hooks?.unixSocket).toEqual(staticVariable('/tmp/wundergraph-hooks.sock'));
+	expect(hooks?.h2c).toBe(true);
+	expect(hooks?.defaultPolicy).toMatchObject({ hooks: [], timeoutSeconds: 5, maxRetries: 1 });
+	expect(hooks?.policies).toMatchObject([
+		{ hooks: ['mutatingPreResolve', 'mutatingPostResolve'], timeoutSeconds: 0, maxRetries: 0 },
+		{ hooks: ['preResolve'], timeoutSeconds: 1, maxRetries: 1 },
+	]);
+
+	expect(resolveServerOptions(serverOptionsWithDefaults({ hooks: {} })).hooks?.defaultPolicy?.maxRetries).toEqual(3);
+});
+
+test('resolveServerOptions hook failures', () => {
+	const hooks = resolveServerOptions(
+		serverOptionsWithDefaults({
+			hooks: {
+				defaultPolicy: { onFailure: 'lastGood' },
+				policies: [{ hooks: ['postAuthentication'], onFailure: 'skip' }],
+				circuitBreaker: { failureThreshold: 10 },
+			},
+		})
+	).hooks;
+	expect(hooks?.defaultPolicy?.onFailure).toEqual(HookFailurePolicy.LAST_GOOD_HOOK_FAILURE);
+	expect(hooks?.policies[0].onFailure).toEqual(HookFailurePolicy.SKIP_HOOK_FAILURE);
+	expect(hooks?.circuitBreaker).toEqual({ disabled: false, failureThreshold: 10, openSeconds: 0 });
+
+	const lastGoodOriginRequest = serverOptionsWithDefaults({
+		hooks: { policies: [{ hooks: ['onOriginRequest'], onFailure: 'lastGood' }] },
+	});
+	expect(() => resolveServerOptions(lastGoodOriginRequest)).toThrow();
+});
+
+test('resolveNodeOptions listen tls', () => {
+	const options = resolveNodeOptions();
+	expect(options.listen.tls).toBeUndefined();
+	expect(options.listen.h2c).toBe(false);
+
+	const tlsOptions = resolveNodeOptions({
+		listen: {
+			port: '443',
+			tls: {
+				certFile: '/etc/wundergraph/tls.crt',
+				keyFile: '/etc/wundergraph/tls.key',
+				clientCaFile: '/etc/wundergraph/clients.crt',
+			},
+		},
+	});
+	expect(tlsOptions.listen.tls).toEqual({
+		certFile: staticVariable('/etc/wundergraph/tls.crt'),
+		keyFile: staticVariable('/etc/wundergraph/tls.key'),
+		clientCaFile: staticVariable('/etc/wundergraph/clients.crt'),
+		clientCertOptional: false,
+		disableHttp2: false,
+		acme: undefined,
+	});
+	expect(tlsOptions.nodeUrl.environmentVariableDefaultValue).toEqual('https://localhost:443');
+
+	expect(resolveNodeOptions({ listen: { h2c: true } }).listen.h2c).toBe(true);
+	expect(() => resolveNodeOptions({ listen: { tls: { certFile: '/etc/wundergraph/tls.crt' } } })).toThrow();
+});
+
+test('resolveNodeOptions listen acme', () => {
+	const tls = resolveNodeOptions({
+		listen: {
+			tls: {
+				acme: {
+					enabled: true,
+					email: 'ops@example.com',
+					directoryUrl: 'https://localhost:14000/dir',
+					challenge: 'http',
+					storageDir: '/var/lib/wundergraph/acme',
+				},
+			},
+		},
+	}).listen.tls;
+	expect(tls?.certFile).toBeUndefined();
+	expect(tls?.acme).toEqual({
+		enabled: true,
+		email: staticVariable('ops@example.com'),
+		directoryUrl: staticVariable('https://localhost:14000/dir'),
+		directoryCaFile: undefined,
+		challenge: AcmeChallengeKind.HTTP_ACME_CHALLENGE,
+		httpChallengeAddress: undefined,
+		storage: AcmeStorageKind.DISK_ACME_STORAGE,
+		storageDir: staticVariable('/var/lib/wundergraph/acme'),
+	});
+
+	const redisAcme = { tls: { acme: { enabled: true, storage: 'redis' } } } as const;
+	expect(() => resolveNodeOptions({ listen: redisAcme })).toThrow();
+	const redisCache = { kind: 'redis', redis: { url: 'redis://localhost:6379' } } as const;
+	expect(resolveNodeOptions({ listen: redisAcme, cache: redisCache }).listen.tls?.acme?.storage).toEqual(
+		AcmeStorageKind.REDIS_ACME_STORAGE
+	);
+	expect(() => resolveNodeOptions({ listen: { tls: { acme: { enabled: true } } } })).toThrow();
+});
+
+test('resolveNodeOptions server', () => {
+	expect(resolveNodeOptions().server).toBeUndefined();
+	expect(resolveNodeOptions({ server: { writeTimeoutSeconds: 30, maxConnections: 1000 } }).server).toEqual({
+		readTimeoutSeconds: 0,
+		readHeaderTimeoutSeconds: 0,
+		writeTimeoutSeconds: 30,
+		idleTimeoutSeconds: 0,
+		keepAliveSeconds: 0,
+		maxHeaderBytes: 0,
+		maxConnections: 1000,
+		streamTimeoutSeconds: 0,
+	});
+});
@@ -71,7 +71,18 @@ export { configureWunderGraphServer } from './middleware/server';
 
 export { default as cors } from './cors';
 export { authProviders } from './configure/authentication';
-export type { LoggerLevel } from './configure/options';
+export type {
+	AcmeChallenge,
+	AcmeStorage,
+	CacheCompression,
+	CacheKind,
+	HookFailure,
+	HookName,
+	LoggerLevel,
+	RateLimitStore,
+	RedisTopology,
+	TracingExporter,
+} from './configure/options';
 export { WgEnv } from './configure/options';
 
 export {
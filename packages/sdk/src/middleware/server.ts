@@ -12,6 +12,7 @@ import { InternalClientFactory, internalClientFactory } from './internal-client'
 import { pino } from 'pino';
 import path from 'path';
 import fs from 'fs';
+import net from 'net';
 import {
 	FastifyRequestBody,
 	ServerRunOptions,
@@ -143,12 +144,29 @@ export const startServer = async (opts: ServerRunOptions) => {
 			port: port,
 			host: host,
 		});
+		if (opts.config.api.serverOptions.hooks?.unixSocket) {
+			await listenUnixSocket(fastify, resolveConfigurationVariable(opts.config.api.serverOptions.hooks.unixSocket));
+		}
 	} else {
 		logger.fatal('Could not start the hook server');
 		process.exit(1);
 	}
 };
 
+// listenUnixSocket accepts the hook requests of the node on a Unix domain socket,
+// connections are handed to the HTTP server of fastify
+const listenUnixSocket = async (fastify: FastifyInstance, socketPath: string) => {
+	// a socket left behind by a previous process makes listen fail
+	fs.rmSync(socketPath, { force: true });
+	const socketServer = net.createServer((socket) => fastify.server.emit('connection', socket));
+	await new Promise<void>((resolve, reject) => {
+		socketServer.once('error', reject);
+		socketServer.listen(socketPath, resolve);
+	});
+	fastify.server.once('close', () => socketServer.close());
+	logger.debug(`Hooks listening on ${socketPath}`);
+};
+
 export const createServer = async ({
 	wundergraphDir,
 	serverConfig,
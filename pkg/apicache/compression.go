@@ -0,0 +1,54 @@
+package apicache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+)
+
+// Content codings of compressed cache items, as used in the Content-Encoding header
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+)
+
+func compress(encoding string, data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var writer io.WriteCloser
+	switch encoding {
+	case EncodingGzip:
+		writer = gzip.NewWriter(buf)
+	case EncodingBrotli:
+		writer = brotli.NewWriterLevel(buf, brotli.DefaultCompression)
+	default:
+		return nil, fmt.Errorf("unsupported cache compression: %s", encoding)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(encoding string, data []byte) ([]byte, error) {
+	var reader io.Reader
+	switch encoding {
+	case EncodingGzip:
+		gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case EncodingBrotli:
+		reader = brotli.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported cache compression: %s", encoding)
+	}
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,112 @@
+package apicache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryCache_DeleteByTag(t *testing.T) {
+	cache, err := NewInMemory(1e4 * 50)
+	assert.NoError(t, err)
+
+	cache.SetWithTags("a", []byte("a"), time.Second, []string{"users", "user:1"})
+	cache.SetWithTags("b", []byte("b"), time.Second, []string{"users"})
+	cache.SetWithTags("c", []byte("c"), time.Second, []string{"posts"})
+	time.Sleep(10 * time.Millisecond)
+
+	cache.DeleteByTag(context.Background(), "user:1")
+	time.Sleep(10 * time.Millisecond)
+	_, hit := cache.Get(context.Background(), "a")
+	assert.Equal(t, false, hit)
+	_, hit = cache.Get(context.Background(), "b")
+	assert.Equal(t, true, hit)
+
+	cache.DeleteByTag(context.Background(), "users")
+	time.Sleep(10 * time.Millisecond)
+	_, hit = cache.Get(context.Background(), "b")
+	assert.Equal(t, false, hit)
+	_, hit = cache.Get(context.Background(), "c")
+	assert.Equal(t, true, hit)
+}
+
+func TestInMemoryCache_Compression(t *testing.T) {
+	cache, err := NewInMemoryWithOptions(InMemoryOptions{
+		MaxSize:            1e6,
+		Compression:        EncodingBrotli,
+		MinCompressionSize: 16,
+	})
+	assert.NoError(t, err)
+
+	data := []byte(strings.Repeat(`{"name":"Jens"},`, 100))
+	cache.Set("large", data)
+	cache.Set("small", []byte("bar"))
+	time.Sleep(10 * time.Millisecond)
+
+	item, hit := cache.Get(context.Background(), "large")
+	assert.Equal(t, true, hit)
+	assert.Equal(t, EncodingBrotli, item.Encoding)
+	assert.Less(t, len(item.Data), len(data))
+	decoded, err := item.Decoded()
+	assert.NoError(t, err)
+	assert.Equal(t, data, decoded)
+
+	item, hit = cache.Get(context.Background(), "small")
+	assert.Equal(t, true, hit)
+	assert.Equal(t, "", item.Encoding)
+	assert.Equal(t, "bar", string(item.Data))
+}
+
+func TestInMemoryCache_MaxItemSize(t *testing.T) {
+	cache, err := NewInMemoryWithOptions(InMemoryOptions{
+		MaxSize:     1e6,
+		MaxItemSize: 10,
+	})
+	assert.NoError(t, err)
+
+	cache.SetWithTags("foo", []byte("bar"), time.Second, []string{"tag"})
+	time.Sleep(10 * time.Millisecond)
+	_, hit := cache.Get(context.Background(), "foo")
+	assert.Equal(t, true, hit)
+
+	// replacing an item with one exceeding the limit evicts it
+	cache.SetWithTags("foo", []byte("way too large"), time.Second, []string{"tag"})
+	time.Sleep(10 * time.Millisecond)
+	_, hit = cache.Get(context.Background(), "foo")
+	assert.Equal(t, false, hit)
+}
+
+func TestInMemoryCache_LargeItemAdmission(t *testing.T) {
+	cache, err := NewInMemoryWithOptions(InMemoryOptions{
+		MaxSize:       1e6,
+		LargeItemSize: 10,
+	})
+	assert.NoError(t, err)
+
+	// small items are admitted right away
+	cache.Set("small", []byte("bar"))
+	time.Sleep(10 * time.Millisecond)
+	_, hit := cache.Get(context.Background(), "small")
+	assert.Equal(t, true, hit)
+
+	// large items are admitted on their second request
+	cache.Set("large", []byte("way too large"))
+	time.Sleep(10 * time.Millisecond)
+	_, hit = cache.Get(context.Background(), "large")
+	assert.Equal(t, false, hit)
+
+	cache.Set("large", []byte("way too large"))
+	time.Sleep(10 * time.Millisecond)
+	_, hit = cache.Get(context.Background(), "large")
+	assert.Equal(t, true, hit)
+
+	// updates of admitted items are stored right away
+	cache.Set("large", []byte("even larger item"))
+	time.Sleep(10 * time.Millisecond)
+	item, hit := cache.Get(context.Background(), "large")
+	assert.Equal(t, true, hit)
+	assert.Equal(t, "even larger item", string(item.Data))
+}
@@ -0,0 +1,29 @@
+package apicache
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRedisClient(t *testing.T) {
+	tlsConfig := &tls.Config{}
+	client, err := NewRedisClient(RedisOptions{
+		URL:       "redis://localhost:6380/2",
+		Password:  "secret",
+		TLSConfig: tlsConfig,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	options := client.(*redis.Client).Options()
+	assert.Equal(t, "localhost:6380", options.Addr)
+	assert.Equal(t, "secret", options.Password)
+	assert.Equal(t, 2, options.DB)
+	assert.Equal(t, "localhost", options.TLSConfig.ServerName)
+	// the config of the caller is left untouched
+	assert.Equal(t, "", tlsConfig.ServerName)
+}
@@ -1,28 +1,99 @@
 package apihandler
 
 import (
+	"net"
 	"time"
 
 	"github.com/jensneuse/abstractlogger"
 
+	"github.com/wundergraph/wundergraph/pkg/fixtures"
+	"github.com/wundergraph/wundergraph/pkg/hooks"
+	"github.com/wundergraph/wundergraph/pkg/querylimits"
+	"github.com/wundergraph/wundergraph/pkg/tracing"
 	"github.com/wundergraph/wundergraph/pkg/wgpb"
 )
 
 type Listener struct {
 	Host string
 	Port uint16
+	TLS  ListenerTLS
+	// H2C serves cleartext HTTP/2, it's ignored if TLS is enabled
+	H2C bool
+}
+
+// ListenerTLS terminates TLS on the listener if CertFile and KeyFile are set or ACME is enabled
+type ListenerTLS struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mTLS
+	ClientCAFile       string
+	ClientCertOptional bool
+	DisableHTTP2       bool
+	// ACME obtains the certificates instead of CertFile and KeyFile
+	ACME ListenerACME
+}
+
+func (t ListenerTLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || t.ACME.Enabled
+}
+
+// ListenerACME obtains and renews the certificates of the allowed hosts through ACME
+type ListenerACME struct {
+	Enabled bool
+	Email   string
+	// DirectoryURL defaults to Let's Encrypt
+	DirectoryURL    string
+	DirectoryCAFile string
+	Challenge       wgpb.AcmeChallengeKind
+	// HTTPChallengeAddress serves HTTP-01 challenges, defaults to :80
+	HTTPChallengeAddress string
+	Storage              wgpb.AcmeStorageKind
+	StorageDir           string
 }
 
 type Logging struct {
 	Level abstractlogger.Level
 }
 
+type MetricsOptions struct {
+	Enabled  bool
+	Listener *Listener
+}
+
+type RateLimitOptions struct {
+	// RedisURL shares the rate limits of operations between nodes through Redis.
+	// If empty, each node enforces the limits in memory.
+	RedisURL string
+	// TrustedProxies are the proxies whose Forwarded and X-Forwarded-For headers identify the client IP
+	TrustedProxies []*net.IPNet
+}
+
+type HooksOptions struct {
+	// UnixSocket connects to the hooks server through a Unix domain socket
+	UnixSocket string
+	// H2C multiplexes hook requests over a persistent cleartext HTTP/2 connection
+	H2C bool
+	// DefaultPolicy replaces hooks.DefaultPolicy if set
+	DefaultPolicy *hooks.Policy
+	Policies      map[hooks.MiddlewareHook]hooks.Policy
+	// CircuitBreaker replaces hooks.DefaultCircuitBreakerOptions if set
+	CircuitBreaker *hooks.CircuitBreakerOptions
+}
+
 type Options struct {
 	ServerUrl      string
+	Hooks          HooksOptions
 	PublicNodeUrl  string
 	Listener       *Listener
 	Logging        Logging
 	DefaultTimeout time.Duration
+	Metrics        MetricsOptions
+	Tracing        tracing.Options
+	RateLimit      RateLimitOptions
+	// CachePurgeToken enables the internal cache purge endpoint, requests must present it as bearer token
+	CachePurgeToken string
+	// Fixtures records or replays the responses of all origins
+	Fixtures fixtures.Options
 }
 
 type Api struct {
@@ -32,6 +103,9 @@ type Api struct {
 	EngineConfiguration   *wgpb.EngineConfiguration
 	EnableSingleFlight    bool
 	EnableGraphqlEndpoint bool
+	GraphQLLimits         querylimits.Limits
+	GraphQLHooks          []*wgpb.GraphQLEndpointHooksConfiguration
+	GraphQLHooksOptional  bool
 	Operations            []*wgpb.Operation
 	CorsConfiguration     *wgpb.CorsConfiguration
 	DeploymentId          string
@@ -41,6 +115,10 @@ type Api struct {
 	S3UploadConfiguration []*wgpb.S3UploadConfiguration
 	Webhooks              []*wgpb.WebhookConfiguration
 	Options               *Options
+
+	// GraphQLMaxBatchSize and GraphQLBatchConcurrency limit batched requests, defaults apply if 0
+	GraphQLMaxBatchSize     int
+	GraphQLBatchConcurrency int
 }
 
 func (api *Api) HasCookieAuthEnabled() bool {
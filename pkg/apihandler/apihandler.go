@@ -3,6 +3,8 @@ package apihandler
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +14,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -40,6 +43,7 @@ import (
 	"github.com/wundergraph/graphql-go-tools/pkg/lexer/literal"
 	"github.com/wundergraph/graphql-go-tools/pkg/operationreport"
 
+	"github.com/wundergraph/wundergraph/internal/statusrecorder"
 	"github.com/wundergraph/wundergraph/internal/unsafebytes"
 	"github.com/wundergraph/wundergraph/pkg/apicache"
 	"github.com/wundergraph/wundergraph/pkg/authentication"
@@ -49,9 +53,13 @@ import (
 	"github.com/wundergraph/wundergraph/pkg/inputvariables"
 	"github.com/wundergraph/wundergraph/pkg/interpolate"
 	"github.com/wundergraph/wundergraph/pkg/loadvariable"
+	"github.com/wundergraph/wundergraph/pkg/metrics"
 	"github.com/wundergraph/wundergraph/pkg/pool"
 	"github.com/wundergraph/wundergraph/pkg/postresolvetransform"
+	"github.com/wundergraph/wundergraph/pkg/querylimits"
+	"github.com/wundergraph/wundergraph/pkg/ratelimit"
 	"github.com/wundergraph/wundergraph/pkg/s3uploadclient"
+	"github.com/wundergraph/wundergraph/pkg/tracing"
 	"github.com/wundergraph/wundergraph/pkg/webhookhandler"
 	"github.com/wundergraph/wundergraph/pkg/wgpb"
 )
@@ -81,7 +89,12 @@ type Builder struct {
 
 	cache apicache.Cache
 
+	metrics *metrics.Metrics
+
+	rateLimiter ratelimit.Limiter
+
 	insecureCookies     bool
+	cookiePath          string
 	forceHttpsRedirects bool
 	enableDebugMode     bool
 	enableIntrospection bool
@@ -102,6 +115,10 @@ type BuilderConfig struct {
 	GitHubAuthDemoClientSecret string
 	HookServerURL              string
 	DevMode                    bool
+	Metrics                    *metrics.Metrics
+	RateLimiter                ratelimit.Limiter
+	// CookiePath scopes the auth cookies to the API, they are shared by all APIs on the domain if empty
+	CookiePath string
 }
 
 func NewBuilder(pool *pool.Pool,
@@ -115,6 +132,7 @@ func NewBuilder(pool *pool.Pool,
 		log:                        log,
 		pool:                       pool,
 		insecureCookies:            config.InsecureCookies,
+		cookiePath:                 config.CookiePath,
 		middlewareClient:           hooksClient,
 		hooksServerURL:             config.HookServerURL,
 		forceHttpsRedirects:        config.ForceHttpsRedirects,
@@ -123,6 +141,8 @@ func NewBuilder(pool *pool.Pool,
 		githubAuthDemoClientID:     config.GitHubAuthDemoClientID,
 		githubAuthDemoClientSecret: config.GitHubAuthDemoClientSecret,
 		devMode:                    config.DevMode,
+		metrics:                    config.Metrics,
+		rateLimiter:                config.RateLimiter,
 	}
 }
 
@@ -267,15 +287,29 @@ func (r *Builder) BuildAndMountApiHandler(ctx context.Context, router *mux.Route
 
 	if api.EnableGraphqlEndpoint {
 		graphqlHandler := &GraphQLHandler{
-			planConfig:      r.planConfig,
-			definition:      r.definition,
-			resolver:        r.resolver,
-			log:             r.log,
-			pool:            r.pool,
-			sf:              &singleflight.Group{},
-			prepared:        map[uint64]planWithExtractedVariables{},
-			preparedMux:     &sync.RWMutex{},
-			renameTypeNames: r.renameTypeNames,
+			planConfig:       r.planConfig,
+			definition:       r.definition,
+			resolver:         r.resolver,
+			log:              r.log,
+			pool:             r.pool,
+			sf:               &singleflight.Group{},
+			prepared:         map[uint64]planWithExtractedVariables{},
+			preparedMux:      &sync.RWMutex{},
+			renameTypeNames:  r.renameTypeNames,
+			metrics:          r.metrics,
+			cache:            r.cache,
+			limits:           api.GraphQLLimits,
+			maxBatchSize:     api.GraphQLMaxBatchSize,
+			batchConcurrency: api.GraphQLBatchConcurrency,
+			hooksClient:      r.middlewareClient,
+			hooksConfig:      map[string]*wgpb.GraphQLEndpointHooksConfiguration{},
+			hooksOptional:    api.GraphQLHooksOptional,
+		}
+		for _, config := range api.GraphQLHooks {
+			graphqlHandler.hooksConfig[config.OperationName] = config
+		}
+		if graphqlHandler.cache == nil {
+			graphqlHandler.cache = &apicache.NoOpCache{}
 		}
 		apiPath := "/graphql"
 		r.router.Methods(http.MethodPost, http.MethodOptions).Path(apiPath).Handler(graphqlHandler)
@@ -283,6 +317,12 @@ func (r *Builder) BuildAndMountApiHandler(ctx context.Context, router *mux.Route
 			abstractlogger.String("method", http.MethodPost),
 			abstractlogger.String("path", path.Join(api.PathPrefix, apiPath)),
 		)
+		// GET requests carrying an operation are executed, all others are served the playground
+		r.router.Methods(http.MethodGet).Path(apiPath).MatcherFunc(isGraphQLGetRequest).Handler(graphqlHandler)
+		r.log.Debug("registered GraphQLHandler",
+			abstractlogger.String("method", http.MethodGet),
+			abstractlogger.String("path", path.Join(api.PathPrefix, apiPath)),
+		)
 
 		graphqlPlaygroundHandler := &GraphQLPlaygroundHandler{
 			log:           r.log,
@@ -349,6 +389,11 @@ WithNext:
 	return fields
 }
 
+// Cache returns the cache of the API, it's set once the handlers are mounted
+func (r *Builder) Cache() apicache.Cache {
+	return r.cache
+}
+
 func (r *Builder) createSubRouter(router *mux.Router, pathPrefix string) *mux.Router {
 
 	route := router.NewRoute()
@@ -467,6 +512,7 @@ func (r *Builder) registerOperation(operation *wgpb.Operation) error {
 			postResolveTransformer: postResolveTransformer,
 			renameTypeNames:        r.renameTypeNames,
 			queryParamsAllowList:   queryParamsAllowList,
+			metrics:                r.metrics,
 		}
 
 		if operation.LiveQueryConfig != nil && operation.LiveQueryConfig.Enable {
@@ -477,11 +523,23 @@ func (r *Builder) registerOperation(operation *wgpb.Operation) error {
 		}
 
 		if operation.CacheConfig != nil && operation.CacheConfig.Enable {
+			varyByHeaders := make([]string, len(operation.CacheConfig.VaryByHeaders))
+			for i, name := range operation.CacheConfig.VaryByHeaders {
+				varyByHeaders[i] = http.CanonicalHeaderKey(name)
+			}
+			// responses cached per user must not be stored by shared caches
+			public := operation.CacheConfig.Public && !operation.CacheConfig.VaryByUser && !operation.CacheConfig.VaryByRoles
 			handler.cacheConfig = cacheConfig{
-				enable:               operation.CacheConfig.Enable,
-				maxAge:               operation.CacheConfig.MaxAge,
-				public:               operation.CacheConfig.Public,
-				staleWhileRevalidate: operation.CacheConfig.StaleWhileRevalidate,
+				enable:                operation.CacheConfig.Enable,
+				maxAge:                operation.CacheConfig.MaxAge,
+				public:                public,
+				staleWhileRevalidate:  operation.CacheConfig.StaleWhileRevalidate,
+				staleIfError:          operation.CacheConfig.StaleIfError,
+				tags:                  operation.CacheConfig.Tags,
+				invalidationVariables: cacheInvalidationVariables(r.api.Operations, operation.Name),
+				varyByUser:            operation.CacheConfig.VaryByUser,
+				varyByRoles:           operation.CacheConfig.VaryByRoles,
+				varyByHeaders:         varyByHeaders,
 			}
 		}
 
@@ -489,9 +547,9 @@ func (r *Builder) registerOperation(operation *wgpb.Operation) error {
 
 		route := r.router.Methods(http.MethodGet, http.MethodOptions).Path(apiPath)
 		if operation.AuthenticationConfig != nil && operation.AuthenticationConfig.AuthRequired {
-			route.Handler(authentication.RequiresAuthentication(handler))
+			route.Handler(r.instrumentOperation(operation.Name, "query", r.rateLimitOperation(operation, authentication.RequiresAuthentication(handler))))
 		} else {
-			route.Handler(handler)
+			route.Handler(r.instrumentOperation(operation.Name, "query", r.rateLimitOperation(operation, handler)))
 		}
 
 		operationIsConfigured = true
@@ -503,6 +561,7 @@ func (r *Builder) registerOperation(operation *wgpb.Operation) error {
 			abstractlogger.Bool("cacheEnabled", handler.cacheConfig.enable),
 			abstractlogger.Int("cacheMaxAge", int(handler.cacheConfig.maxAge)),
 			abstractlogger.Int("cacheStaleWhileRevalidate", int(handler.cacheConfig.staleWhileRevalidate)),
+			abstractlogger.Int("cacheStaleIfError", int(handler.cacheConfig.staleIfError)),
 			abstractlogger.Bool("cachePublic", handler.cacheConfig.public),
 			abstractlogger.Bool("authRequired", operation.AuthenticationConfig != nil && operation.AuthenticationConfig.AuthRequired),
 		)
@@ -526,14 +585,16 @@ func (r *Builder) registerOperation(operation *wgpb.Operation) error {
 			jsonStringInterpolator: jsonStringInterpolator,
 			postResolveTransformer: postResolveTransformer,
 			renameTypeNames:        r.renameTypeNames,
+			cache:                  r.cache,
+			configHash:             []byte(r.api.ApiConfigHash),
 		}
 		copy(handler.extractedVariables, shared.Doc.Input.Variables)
 		route := r.router.Methods(http.MethodPost, http.MethodOptions).Path(apiPath)
 
 		if operation.AuthenticationConfig != nil && operation.AuthenticationConfig.AuthRequired {
-			route.Handler(authentication.RequiresAuthentication(handler))
+			route.Handler(r.instrumentOperation(operation.Name, "mutation", r.rateLimitOperation(operation, authentication.RequiresAuthentication(handler))))
 		} else {
-			route.Handler(handler)
+			route.Handler(r.instrumentOperation(operation.Name, "mutation", r.rateLimitOperation(operation, handler)))
 		}
 
 		operationIsConfigured = true
@@ -565,14 +626,15 @@ func (r *Builder) registerOperation(operation *wgpb.Operation) error {
 			queryParamsAllowList:   queryParamsAllowList,
 			hooksClient:            r.middlewareClient,
 			hooksConfig:            buildHooksConfig(operation),
+			metrics:                r.metrics,
 		}
 		copy(handler.extractedVariables, shared.Doc.Input.Variables)
 		route := r.router.Methods(http.MethodGet, http.MethodOptions).Path(apiPath)
 
 		if operation.AuthenticationConfig != nil && operation.AuthenticationConfig.AuthRequired {
-			route.Handler(authentication.RequiresAuthentication(handler))
+			route.Handler(r.instrumentOperation(operation.Name, "subscription", r.rateLimitOperation(operation, authentication.RequiresAuthentication(handler))))
 		} else {
-			route.Handler(handler)
+			route.Handler(r.instrumentOperation(operation.Name, "subscription", r.rateLimitOperation(operation, handler)))
 		}
 
 		operationIsConfigured = true
@@ -593,6 +655,12 @@ func (r *Builder) registerOperation(operation *wgpb.Operation) error {
 	return nil
 }
 
+// instrumentOperation wraps the handler of an operation with metrics and tracing
+func (r *Builder) instrumentOperation(operationName, operationType string, handler http.Handler) http.Handler {
+	handler = r.metrics.InstrumentOperation(operationName, operationType, handler)
+	return tracing.InstrumentOperation(operationName, operationType, handler)
+}
+
 func (r *Builder) generateQueryArgumentsAllowList(schema string) []string {
 	var allowList []string
 	schema = r.cleanupJsonSchema(schema)
@@ -613,30 +681,39 @@ func (r *Builder) configureCache(api *Api) (err error) {
 	config := api.CacheConfig
 	switch config.Kind {
 	case wgpb.ApiCacheKind_IN_MEMORY_CACHE:
+		options := inMemoryCacheOptions(config.InMemoryConfig)
 		r.log.Debug("configureCache",
 			abstractlogger.String("primaryHost", api.PrimaryHost),
 			abstractlogger.String("pathPrefix", api.PathPrefix),
 			abstractlogger.String("deploymentID", api.DeploymentId),
 			abstractlogger.String("cacheKind", config.Kind.String()),
-			abstractlogger.Int("cacheSize", int(config.InMemoryConfig.MaxSize)),
+			abstractlogger.Int("cacheSize", int(options.MaxSize)),
+			abstractlogger.Int("maxItemSize", int(options.MaxItemSize)),
+			abstractlogger.String("compression", config.InMemoryConfig.GetCompression().String()),
 		)
-		r.cache, err = apicache.NewInMemory(config.InMemoryConfig.MaxSize)
+		r.cache, err = apicache.NewInMemoryWithOptions(options)
 		return
 	case wgpb.ApiCacheKind_REDIS_CACHE:
 
-		redisAddr := os.Getenv(config.RedisConfig.RedisUrlEnvVar)
+		options, err := RedisCacheOptions(config.RedisConfig)
+		if err != nil {
+			return err
+		}
 
 		r.log.Debug("configureCache",
 			abstractlogger.String("primaryHost", api.PrimaryHost),
 			abstractlogger.String("pathPrefix", api.PathPrefix),
 			abstractlogger.String("deploymentID", api.DeploymentId),
 			abstractlogger.String("cacheKind", config.Kind.String()),
+			abstractlogger.String("topology", config.RedisConfig.Topology.String()),
 			abstractlogger.String("envVar", config.RedisConfig.RedisUrlEnvVar),
-			abstractlogger.String("redisAddr", redisAddr),
+			abstractlogger.Strings("redisAddrs", options.Addrs),
+			abstractlogger.Bool("tls", options.TLSConfig != nil || strings.HasPrefix(options.URL, "rediss://")),
+			abstractlogger.Int("localCacheSize", options.LocalCacheSize),
 		)
 
-		r.cache, err = apicache.NewRedis(redisAddr, r.log)
-		return
+		r.cache, err = apicache.NewRedisWithOptions(options, r.log)
+		return err
 	default:
 		r.log.Debug("configureCache",
 			abstractlogger.String("primaryHost", api.PrimaryHost),
@@ -649,6 +726,77 @@ func (r *Builder) configureCache(api *Api) (err error) {
 	}
 }
 
+func inMemoryCacheOptions(config *wgpb.InMemoryCacheConfig) apicache.InMemoryOptions {
+	options := apicache.InMemoryOptions{
+		MaxSize:              config.GetMaxSize(),
+		MaxItemSize:          config.GetMaxItemSize(),
+		MinCompressionSize:   config.GetMinCompressionSize(),
+		LargeItemSize:        config.GetLargeItemSize(),
+		LargeItemMinRequests: int(config.GetLargeItemMinRequests()),
+	}
+	switch config.GetCompression() {
+	case wgpb.CacheCompression_GZIP_CACHE_COMPRESSION:
+		options.Compression = apicache.EncodingGzip
+	case wgpb.CacheCompression_BROTLI_CACHE_COMPRESSION:
+		options.Compression = apicache.EncodingBrotli
+	}
+	return options
+}
+
+// RedisCacheOptions maps the Redis config of the API cache, it's shared with other users of the same Redis
+func RedisCacheOptions(config *wgpb.RedisCacheConfig) (apicache.RedisOptions, error) {
+	if config == nil {
+		return apicache.RedisOptions{}, fmt.Errorf("redis cache config missing")
+	}
+	options := apicache.RedisOptions{
+		Username:       loadvariable.String(config.Username),
+		Password:       loadvariable.String(config.Password),
+		DB:             int(config.Db),
+		LocalCacheSize: int(config.LocalCacheSize),
+		LocalCacheTTL:  time.Duration(config.LocalCacheTtlSeconds) * time.Second,
+	}
+
+	switch config.Topology {
+	case wgpb.RedisTopology_STANDALONE_REDIS_TOPOLOGY:
+		options.URL = loadvariable.String(config.RedisUrl)
+		if options.URL == "" {
+			options.URL = os.Getenv(config.RedisUrlEnvVar)
+		}
+		if options.URL == "" {
+			return options, fmt.Errorf("redis cache requires a redisUrl")
+		}
+	case wgpb.RedisTopology_SENTINEL_REDIS_TOPOLOGY:
+		options.Addrs = loadvariable.Strings(config.Addresses)
+		options.MasterName = config.SentinelMasterName
+		options.SentinelPassword = loadvariable.String(config.SentinelPassword)
+		if options.MasterName == "" {
+			return options, fmt.Errorf("redis sentinel requires a sentinelMasterName")
+		}
+	case wgpb.RedisTopology_CLUSTER_REDIS_TOPOLOGY:
+		options.Addrs = loadvariable.Strings(config.Addresses)
+		options.Cluster = true
+	default:
+		return options, fmt.Errorf("unknown redis topology: %s", config.Topology)
+	}
+
+	if config.Tls != nil && config.Tls.Enabled {
+		tlsConfig := &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: config.Tls.InsecureSkipVerify,
+			ServerName:         loadvariable.String(config.Tls.ServerName),
+		}
+		if caCert := loadvariable.String(config.Tls.CaCert); caCert != "" {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM([]byte(caCert)) {
+				return options, fmt.Errorf("redis tls caCert contains no valid PEM certificate")
+			}
+		}
+		options.TLSConfig = tlsConfig
+	}
+
+	return options, nil
+}
+
 type GraphQLPlaygroundHandler struct {
 	log           abstractlogger.Logger
 	html          string
@@ -678,20 +826,58 @@ type GraphQLHandler struct {
 	prepared    map[uint64]planWithExtractedVariables
 	preparedMux *sync.RWMutex
 
+	// cache stores the query text of automatic persisted queries
+	cache apicache.Cache
+
 	renameTypeNames []resolve.RenameTypeName
+
+	metrics *metrics.Metrics
+
+	limits querylimits.Limits
+	// maxBatchSize and batchConcurrency limit batched requests, defaults apply if 0
+	maxBatchSize     int
+	batchConcurrency int
+
+	hooksClient *hooks.Client
+	// hooksConfig by operation name, graphqlHooksCatchAll applies to all other operations
+	hooksConfig map[string]*wgpb.GraphQLEndpointHooksConfiguration
+	// hooksOptional runs operations matching no hooksConfig without hooks.
+	// Otherwise they are rejected if any hooks are configured, so renaming an operation can't skip its hooks.
+	hooksOptional bool
 }
 
+// graphqlHooksCatchAll is the operation name of hooks applying to all operations without hooks of their own
+const graphqlHooksCatchAll = "*"
+
 type planWithExtractedVariables struct {
 	preparedPlan plan.Plan
 	variables    []byte
+	// estimate is nil unless limits are enabled
+	estimate *querylimits.Estimate
 }
 
 var (
 	errInvalid = errors.New("invalid")
 )
 
+// graphqlRequest is a single GraphQL operation, sent either as POST body,
+// as element of a batched POST body or as GET query parameters
+type graphqlRequest struct {
+	query         string
+	operationName []byte
+	variables     []byte
+	extensions    []byte
+	// batched is true if the operation is part of a batched request
+	batched bool
+}
+
 func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
+	if r.Method == http.MethodGet {
+		h.serveRequest(w, r, parseGraphQLGetRequest(r))
+		return
+	}
+
 	buf := pool.GetBytesBuffer()
 	defer pool.PutBytesBuffer(buf)
 	_, err := io.Copy(buf, r.Body)
@@ -700,11 +886,21 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body := buf.Bytes()
+	body := bytes.TrimSpace(buf.Bytes())
 
+	if len(body) != 0 && body[0] == '[' {
+		h.serveBatch(w, r, body)
+		return
+	}
+
+	h.serveRequest(w, r, parseGraphQLRequest(body))
+}
+
+func parseGraphQLRequest(body []byte) graphqlRequest {
 	requestQuery, _ := jsonparser.GetString(body, "query")
 	requestOperationName, parsedOperationNameDataType, _, _ := jsonparser.Get(body, "operationName")
 	requestVariables, _, _, _ := jsonparser.Get(body, "variables")
+	requestExtensions, _, _, _ := jsonparser.Get(body, "extensions")
 
 	// An operationName set to { "operationName": null } will be parsed by 'jsonparser' to "null" string
 	// and this will make the planner unable to find the operation to execute in selectOperation step.
@@ -713,15 +909,235 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		requestOperationName = nil
 	}
 
+	return graphqlRequest{
+		query:         requestQuery,
+		operationName: requestOperationName,
+		variables:     requestVariables,
+		extensions:    requestExtensions,
+	}
+}
+
+func parseGraphQLGetRequest(r *http.Request) graphqlRequest {
+	values := r.URL.Query()
+	request := graphqlRequest{
+		query: values.Get("query"),
+	}
+	if operationName := values.Get("operationName"); operationName != "" {
+		request.operationName = []byte(operationName)
+	}
+	if variables := values.Get("variables"); variables != "" {
+		request.variables = []byte(variables)
+	}
+	if extensions := values.Get("extensions"); extensions != "" {
+		request.extensions = []byte(extensions)
+	}
+	return request
+}
+
+// serveBatch executes the operations of a batched request with up to batchConcurrency at a time
+// and responds with a JSON array of their results in request order
+func (h *GraphQLHandler) serveBatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	maxBatchSize := h.maxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = defaultMaxBatchSize
+	}
+	concurrency := h.batchConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	var requests []graphqlRequest
+	_, err := jsonparser.ArrayEach(body, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		request := parseGraphQLRequest(value)
+		request.batched = true
+		requests = append(requests, request)
+	})
+	if err != nil || len(requests) == 0 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if len(requests) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("batch of %d operations exceeds the maximum of %d", len(requests), maxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	responses := make([]*batchResponseWriter, len(requests))
+	semaphore := make(chan struct{}, concurrency)
+	wg := &sync.WaitGroup{}
+	wg.Add(len(requests))
+	for i := range requests {
+		responses[i] = newBatchResponseWriter()
+		semaphore <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			h.serveRequest(responses[i], r, requests[i])
+		}(i)
+	}
+	wg.Wait()
+
+	out := pool.GetBytesBuffer()
+	defer pool.PutBytesBuffer(out)
+
+	out.WriteByte('[')
+	for i, response := range responses {
+		if i != 0 {
+			out.WriteByte(',')
+		}
+		out.Write(response.result())
+	}
+	out.WriteByte(']')
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = out.WriteTo(w)
+	if err != nil {
+		h.log.Error("respond to client", abstractlogger.Error(err))
+	}
+}
+
+func (h *GraphQLHandler) serveRequest(w http.ResponseWriter, r *http.Request, request graphqlRequest) {
+	// the executed operation is only known once the query is parsed and planned
+	metricsLabel := graphqlEndpointMetricsLabel
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestQuery, err := h.loadPersistedQuery(r.Context(), request.extensions, request.query)
+		if err != nil {
+			var persistedQueryErr *persistedQueryError
+			if errors.As(err, &persistedQueryErr) {
+				writePersistedQueryError(w, persistedQueryErr)
+				return
+			}
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		request.query = requestQuery
+		metricsLabel = h.execute(w, r, request)
+	})
+	operationName := string(request.operationName)
+	start := time.Now()
+	recorder := statusrecorder.New(w)
+	tracing.InstrumentOperation(operationName, "graphql", handler).ServeHTTP(recorder, r)
+	h.metrics.ObserveOperation(metricsLabel, "graphql", recorder.StatusCode(), time.Since(start))
+}
+
+const (
+	defaultMaxBatchSize     = 10
+	defaultBatchConcurrency = 4
+)
+
+// graphqlEndpointMetricsLabel is the operation name in metrics of requests to the GraphQL endpoint
+// executing unnamed or invalid operations, or operations beyond the bound of client chosen names
+const graphqlEndpointMetricsLabel = "graphql"
+
+// batchResponseWriter buffers the response to a single operation of a batched request
+type batchResponseWriter struct {
+	header     http.Header
+	statusCode int
+	buf        *bytes.Buffer
+}
+
+func newBatchResponseWriter() *batchResponseWriter {
+	return &batchResponseWriter{
+		header:     http.Header{},
+		statusCode: http.StatusOK,
+		buf:        &bytes.Buffer{},
+	}
+}
+
+func (b *batchResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *batchResponseWriter) Write(p []byte) (int, error) {
+	return b.buf.Write(p)
+}
+
+func (b *batchResponseWriter) WriteHeader(statusCode int) {
+	b.statusCode = statusCode
+}
+
+// result returns the GraphQL response of the operation.
+// Plain text errors are converted into a GraphQL error response.
+func (b *batchResponseWriter) result() []byte {
+	if strings.HasPrefix(b.header.Get("Content-Type"), "application/json") && b.buf.Len() != 0 {
+		return b.buf.Bytes()
+	}
+	message := strings.TrimSpace(b.buf.String())
+	if message == "" {
+		message = http.StatusText(b.statusCode)
+	}
+	result, _ := json.Marshal(map[string]interface{}{
+		"errors": []map[string]string{
+			{"message": message},
+		},
+	})
+	return result
+}
+
+func isGraphQLGetRequest(r *http.Request, _ *mux.RouteMatch) bool {
+	values := r.URL.Query()
+	return values.Has("query") || values.Has("extensions")
+}
+
+// writeGraphQLError responds with a single GraphQL error, identified by code in its extensions.
+// If responseExtensions is not nil, it's added as extensions of the response.
+func writeGraphQLError(w http.ResponseWriter, statusCode int, message, code string, responseExtensions interface{}) {
+	type graphqlErrorExtensions struct {
+		Code string `json:"code"`
+	}
+	type graphqlError struct {
+		Message    string                 `json:"message"`
+		Extensions graphqlErrorExtensions `json:"extensions"`
+	}
+	response, _ := json.Marshal(struct {
+		Errors     []graphqlError `json:"errors"`
+		Extensions interface{}    `json:"extensions,omitempty"`
+	}{
+		Errors: []graphqlError{
+			{
+				Message:    message,
+				Extensions: graphqlErrorExtensions{Code: code},
+			},
+		},
+		Extensions: responseExtensions,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(response)
+}
+
+func writeLimitError(w http.ResponseWriter, err *querylimits.LimitError) {
+	writeGraphQLError(w, http.StatusBadRequest, err.Error(), "OPERATION_LIMIT_EXCEEDED", map[string]interface{}{
+		"cost": err.Stats,
+	})
+}
+
+// isMutation returns true if the operation selected by operationName is a mutation
+func isMutation(doc *ast.Document, operationName []byte) bool {
+	for i := range doc.OperationDefinitions {
+		if len(operationName) != 0 && !bytes.Equal(doc.OperationDefinitionNameBytes(i), operationName) {
+			continue
+		}
+		return doc.OperationDefinitions[i].OperationType == ast.OperationTypeMutation
+	}
+	return false
+}
+
+// execute runs the operation of request and returns the label of the operation in metrics
+func (h *GraphQLHandler) execute(w http.ResponseWriter, r *http.Request, request graphqlRequest) (metricsLabel string) {
+	metricsLabel = graphqlEndpointMetricsLabel
+	requestOperationName := request.operationName
+
 	shared := h.pool.GetSharedFromRequest(context.Background(), r, h.planConfig, pool.Config{
 		RenameTypeNames: h.renameTypeNames,
 	})
 	defer h.pool.PutShared(shared)
 
-	shared.Ctx.Variables = requestVariables
+	shared.Ctx.Variables = request.variables
 	shared.Ctx.Context = r.Context()
 	shared.Ctx.Request.Header = r.Header
-	shared.Doc.Input.ResetInputString(requestQuery)
+	shared.Doc.Input.ResetInputString(request.query)
 	shared.Parser.Parse(shared.Doc, shared.Report)
 
 	if shared.Report.HasErrors() {
@@ -729,9 +1145,22 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var executedOperationName string
+	if ref, ok := selectedOperation(shared.Doc, requestOperationName); ok {
+		// the name references the pooled document, so it's copied
+		executedOperationName = string(shared.Doc.OperationDefinitionNameBytes(ref))
+	}
+
+	// GET requests might be cached or replayed by intermediaries, so they must not have side effects
+	if r.Method == http.MethodGet && isMutation(shared.Doc, requestOperationName) {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "mutations are only allowed with POST requests", http.StatusMethodNotAllowed)
+		return
+	}
+
 	_, _ = shared.Hash.Write(requestOperationName)
 
-	err = shared.Printer.Print(shared.Doc, h.definition, shared.Hash)
+	err := shared.Printer.Print(shared.Doc, h.definition, shared.Hash)
 	if err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
@@ -749,15 +1178,44 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !exists {
 		prepared, err = h.preparePlan(operationHash, requestOperationName, shared)
 		if err != nil {
+			var limitErr *querylimits.LimitError
+			if errors.As(err, &limitErr) {
+				writeLimitError(w, limitErr)
+				return
+			}
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 	}
 
+	// only valid operations are labeled by name, so invalid ones can't use up the bounded labels
+	metricsLabel = h.metrics.ClientOperationLabel(executedOperationName, graphqlEndpointMetricsLabel)
+
+	// hooks are only called for valid operations, before their variables are merged with the extracted ones
+	hooksConfig := h.lookupHooksConfig(executedOperationName)
+	if hooksConfig == nil && len(h.hooksConfig) != 0 && !h.hooksOptional {
+		writeGraphQLError(w, http.StatusForbidden, "operation has no hooks configured", "OPERATION_NOT_ALLOWED", nil)
+		return
+	}
+	if hooksConfig != nil {
+		if done := h.handlePreResolveHooks(w, r, shared, request, hooksConfig); done {
+			return
+		}
+	}
+
 	if len(prepared.variables) != 0 {
 		shared.Ctx.Variables = MergeJsonRightIntoLeft(shared.Ctx.Variables, prepared.variables)
 	}
 
+	// the cost depends on list sizes passed as variables, so it's checked for every request
+	if prepared.estimate != nil {
+		var limitErr *querylimits.LimitError
+		if err := h.limits.Check(prepared.estimate, shared.Ctx.Variables); errors.As(err, &limitErr) {
+			writeLimitError(w, limitErr)
+			return
+		}
+	}
+
 	switch p := prepared.preparedPlan.(type) {
 	case *plan.SynchronousResponsePlan:
 		w.Header().Set("Content-Type", "application/json")
@@ -774,18 +1232,38 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
+		if hooksConfig.GetMutatingPostResolve() {
+			hookData := graphqlHookData(r, request, shared.Ctx.Variables, executionBuf.Bytes())
+			out, err := h.hooksClient.DoGraphQLRequest(shared.Ctx.Context, hooksConfig.OperationName, hooks.MutatingPostResolve, hookData)
+			operation := graphqlOperation(shared.Doc, requestOperationName)
+			if done := handleOperationErr(h.log, err, w, "mutatingPostResolve hook failed", operation); done {
+				return
+			}
+			if done := handleHookOut(shared.Ctx, w, h.log, out, "mutatingPostResolve hook out is nil", operation); done {
+				return
+			}
+			executionBuf.Reset()
+			executionBuf.Write(out.Response)
+		}
 		_, err = executionBuf.WriteTo(w)
 		if err != nil {
 			h.log.Error("respond to client", abstractlogger.Error(err))
 			return
 		}
 	case *plan.SubscriptionResponsePlan:
+		if request.batched {
+			http.Error(w, "subscriptions are not supported in batched requests", http.StatusBadRequest)
+			return
+		}
 		flushWriter, ok := getFlushWriter(shared.Ctx, r, w)
 		if !ok {
 			http.Error(w, "Connection not flushable", http.StatusBadRequest)
 			return
 		}
 
+		h.metrics.SubscriptionStarted(metricsLabel)
+		defer h.metrics.SubscriptionEnded(metricsLabel)
+
 		err := h.resolver.ResolveGraphQLSubscription(shared.Ctx, p.Response, flushWriter)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
@@ -797,6 +1275,98 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case *plan.StreamingResponsePlan:
 		http.Error(w, "not implemented", http.StatusNotFound)
 	}
+	return
+}
+
+// lookupHooksConfig returns the hooks of the executed operation, anonymous operations only match the catch-all entry.
+// It returns nil if the operation has no hooks.
+func (h *GraphQLHandler) lookupHooksConfig(executedOperationName string) *wgpb.GraphQLEndpointHooksConfiguration {
+	if executedOperationName != "" {
+		if config, ok := h.hooksConfig[executedOperationName]; ok {
+			return config
+		}
+	}
+	return h.hooksConfig[graphqlHooksCatchAll]
+}
+
+// selectedOperation returns the operation definition selected by operationName,
+// which may be omitted if the document contains a single operation
+func selectedOperation(doc *ast.Document, operationName []byte) (ref int, ok bool) {
+	if len(operationName) == 0 {
+		return 0, len(doc.OperationDefinitions) == 1
+	}
+	for i := range doc.OperationDefinitions {
+		if bytes.Equal(doc.OperationDefinitionNameBytes(i), operationName) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// handlePreResolveHooks calls the preResolve and mutatingPreResolve hooks of a request to the GraphQL endpoint,
+// mutatingPreResolve replaces the variables of the request
+func (h *GraphQLHandler) handlePreResolveHooks(w http.ResponseWriter, r *http.Request, shared *pool.Shared, request graphqlRequest, hooksConfig *wgpb.GraphQLEndpointHooksConfiguration) (done bool) {
+	operation := graphqlOperation(shared.Doc, request.operationName)
+
+	if hooksConfig.PreResolve {
+		hookData := graphqlHookData(r, request, shared.Ctx.Variables, nil)
+		out, err := h.hooksClient.DoGraphQLRequest(shared.Ctx.Context, hooksConfig.OperationName, hooks.PreResolve, hookData)
+		if done := handleOperationErr(h.log, err, w, "preResolve hook failed", operation); done {
+			return true
+		}
+		if done := handleHookOut(shared.Ctx, w, h.log, out, "preResolve hook out is nil", operation); done {
+			return true
+		}
+	}
+
+	if hooksConfig.MutatingPreResolve {
+		hookData := graphqlHookData(r, request, shared.Ctx.Variables, nil)
+		out, err := h.hooksClient.DoGraphQLRequest(shared.Ctx.Context, hooksConfig.OperationName, hooks.MutatingPreResolve, hookData)
+		if done := handleOperationErr(h.log, err, w, "mutatingPreResolve hook failed", operation); done {
+			return true
+		}
+		if done := handleHookOut(shared.Ctx, w, h.log, out, "mutatingPreResolve hook out is nil", operation); done {
+			return true
+		}
+		if len(out.Input) != 0 {
+			shared.Ctx.Variables = out.Input
+		}
+	}
+
+	return false
+}
+
+// graphqlHookData is the payload of hooks of requests to the GraphQL endpoint,
+// in addition to the payload of operation hooks it carries the operation name and query
+func graphqlHookData(r *http.Request, request graphqlRequest, variables []byte, response []byte) []byte {
+	hookData := hookBaseData(r, nil, variables, response)
+	if operationName, err := json.Marshal(string(request.operationName)); err == nil {
+		hookData, _ = jsonparser.Set(hookData, operationName, "operationName")
+	}
+	if query, err := json.Marshal(request.query); err == nil {
+		hookData, _ = jsonparser.Set(hookData, query, "query")
+	}
+	return hookData
+}
+
+// graphqlOperation describes the operation selected by operationName for logging
+func graphqlOperation(doc *ast.Document, operationName []byte) *wgpb.Operation {
+	operation := &wgpb.Operation{
+		Name: string(operationName),
+	}
+	for i := range doc.OperationDefinitions {
+		if len(operationName) != 0 && !bytes.Equal(doc.OperationDefinitionNameBytes(i), operationName) {
+			continue
+		}
+		switch doc.OperationDefinitions[i].OperationType {
+		case ast.OperationTypeMutation:
+			operation.OperationType = wgpb.OperationType_MUTATION
+		case ast.OperationTypeSubscription:
+			operation.OperationType = wgpb.OperationType_SUBSCRIPTION
+		}
+		break
+	}
+	return operation
 }
 
 func (h *GraphQLHandler) preparePlan(operationHash uint64, requestOperationName []byte, shared *pool.Shared) (planWithExtractedVariables, error) {
@@ -812,12 +1382,26 @@ func (h *GraphQLHandler) preparePlan(operationHash uint64, requestOperationName
 			return nil, errInvalid
 		}
 
+		var estimate *querylimits.Estimate
+		if h.limits.Enabled() {
+			var err error
+			estimate, err = querylimits.NewEstimate(shared.Doc, h.definition, requestOperationName, h.limits.DefaultListSize)
+			if err != nil {
+				return nil, errInvalid
+			}
+			// operations exceeding the depth or field limits are rejected before planning and never cached
+			if err := h.limits.CheckStatic(estimate); err != nil {
+				return nil, err
+			}
+		}
+
 		preparedPlan := shared.Planner.Plan(shared.Doc, h.definition, unsafebytes.BytesToString(requestOperationName), shared.Report)
 		shared.Postprocess.Process(preparedPlan)
 
 		prepared := planWithExtractedVariables{
 			preparedPlan: preparedPlan,
 			variables:    make([]byte, len(shared.Doc.Input.Variables)),
+			estimate:     estimate,
 		}
 
 		copy(prepared.variables, shared.Doc.Input.Variables)
@@ -905,6 +1489,18 @@ type cacheConfig struct {
 	maxAge               int64
 	public               bool
 	staleWhileRevalidate int64
+	staleIfError         int64
+	tags                 []string
+	// invalidationVariables are the sets of variables mutations match on when invalidating the operation
+	invalidationVariables [][]string
+	varyByUser            bool
+	varyByRoles           bool
+	// varyByHeaders are canonical header names
+	varyByHeaders []string
+}
+
+func (c *cacheConfig) varies() bool {
+	return c.varyByUser || c.varyByRoles || len(c.varyByHeaders) != 0
 }
 
 type QueryResolver interface {
@@ -923,6 +1519,10 @@ type hooksConfig struct {
 	mutatingPreResolve  bool
 	mutatingPostResolve bool
 	customResolve       bool
+
+	onSubscriptionStart         bool
+	onSubscriptionEnd           bool
+	onSubscriptionMessageFilter bool
 }
 
 type mockResolveConfig struct {
@@ -945,6 +1545,10 @@ func buildHooksConfig(operation *wgpb.Operation) hooksConfig {
 		mutatingPreResolve:  config.MutatingPreResolve,
 		mutatingPostResolve: config.MutatingPostResolve,
 		customResolve:       config.CustomResolve,
+
+		onSubscriptionStart:         config.OnSubscriptionStart,
+		onSubscriptionEnd:           config.OnSubscriptionEnd,
+		onSubscriptionMessageFilter: config.OnSubscriptionMessageFilter,
 	}
 }
 
@@ -998,6 +1602,34 @@ type QueryHandler struct {
 	postResolveTransformer *postresolvetransform.Transformer
 	renameTypeNames        []resolve.RenameTypeName
 	queryParamsAllowList   []string
+	metrics                *metrics.Metrics
+	// revalidations deduplicates the background revalidations of stale cache entries by cache key
+	revalidations singleflight.Group
+}
+
+// cacheKey identifies the cached response of the request.
+// If the response varies by user, roles or headers, their values are part of the key,
+// so that each user only ever gets responses cached for them.
+func (h *QueryHandler) cacheKey(r *http.Request) string {
+	key := string(h.configHash) + r.RequestURI
+	if !h.cacheConfig.varies() {
+		return key
+	}
+	vary := url.Values{}
+	user := authentication.UserFromContext(r.Context())
+	if h.cacheConfig.varyByUser && user != nil {
+		vary.Set("user", user.ProviderID+":"+user.UserID)
+	}
+	if h.cacheConfig.varyByRoles && user != nil {
+		roles := make([]string, len(user.Roles))
+		copy(roles, user.Roles)
+		sort.Strings(roles)
+		vary["roles"] = roles
+	}
+	for _, name := range h.cacheConfig.varyByHeaders {
+		vary["header:"+name] = r.Header.Values(name)
+	}
+	return key + "#" + vary.Encode()
 }
 
 func (h *QueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -1010,8 +1642,9 @@ func (h *QueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var (
-		cacheKey     string
-		cacheIsStale = false
+		cacheKey string
+		// staleItem is an expired response, only served if the origin fails
+		staleItem *apicache.CacheItem
 	)
 
 	isLive := h.liveQuery.enabled && r.URL.Query().Get(WG_LIVE) == "true"
@@ -1022,19 +1655,6 @@ func (h *QueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	})
 
 	defer func() {
-
-		if cacheIsStale {
-			buf.Reset()
-			ctx.Context = context.WithValue(context.Background(), "user", authentication.UserFromContext(r.Context()))
-			err := h.resolver.ResolveGraphQLResponse(ctx, h.preparedPlan.Response, nil, buf)
-			if err == nil {
-				bufferedData := buf.Bytes()
-				cacheData := make([]byte, len(bufferedData))
-				copy(cacheData, bufferedData)
-				h.cache.SetWithTTL(cacheKey, cacheData, time.Second*time.Duration(h.cacheConfig.maxAge+h.cacheConfig.staleWhileRevalidate))
-			}
-		}
-
 		pool.PutCtx(ctx)
 		pool.PutBytesBuffer(buf)
 	}()
@@ -1096,119 +1716,51 @@ func (h *QueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if isLive {
+		h.metrics.SubscriptionStarted(h.operation.Name)
+		defer h.metrics.SubscriptionEnded(h.operation.Name)
 		h.handleLiveQuery(r, w, ctx, buf, flusher)
 		return
 	}
 
 	if h.cacheConfig.enable {
-		cacheKey = string(h.configHash) + r.RequestURI
+		cacheKey = h.cacheKey(r)
+		if len(h.cacheConfig.varyByHeaders) != 0 {
+			w.Header().Set("Vary", strings.Join(h.cacheConfig.varyByHeaders, ", "))
+		}
 		item, hit := h.cache.Get(ctx.Context, cacheKey)
+		if hit && item.Age() > h.cacheConfig.maxAge+h.cacheConfig.staleWhileRevalidate {
+			staleItem = &item
+			hit = false
+		}
 		if hit {
-
-			w.Header().Set(WG_CACHE_HEADER, "HIT")
-
-			_, _ = buf.Write(item.Data)
-
-			hash := xxhash.New()
-			_, _ = hash.Write(h.configHash)
-			_, _ = hash.Write(buf.Bytes())
-			ETag := fmt.Sprintf("W/\"%d\"", hash.Sum64())
-
-			w.Header()["ETag"] = []string{ETag}
-
-			if h.cacheConfig.public {
-				w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d", h.cacheConfig.maxAge, h.cacheConfig.staleWhileRevalidate))
-			} else {
-				w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d, stale-while-revalidate=%d", h.cacheConfig.maxAge, h.cacheConfig.staleWhileRevalidate))
-			}
-
-			age := item.Age()
-			w.Header().Set("Age", fmt.Sprintf("%d", age))
-
-			if age > h.cacheConfig.maxAge {
-				cacheIsStale = true
-			}
-
-			ifNoneMatch := r.Header.Get("If-None-Match")
-			if ifNoneMatch == ETag {
-				w.WriteHeader(http.StatusNotModified)
+			h.metrics.CacheHit(h.operation.Name)
+			if item.Age() > h.cacheConfig.maxAge {
+				h.revalidate(r, cacheKey, ctx.Variables)
+				h.writeCacheItem(w, r, item, "STALE")
 				return
 			}
-
-			w.WriteHeader(http.StatusOK)
-			_, _ = buf.WriteTo(w)
+			h.writeCacheItem(w, r, item, "HIT")
 			return
 		}
+		h.metrics.CacheMiss(h.operation.Name)
 		w.Header().Set(WG_CACHE_HEADER, "MISS")
 	}
 
-	if h.hooksConfig.preResolve {
-		hookData := hookBaseData(r, hookBuf.Bytes(), ctx.Variables, nil)
-		out, err := h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.PreResolve, hookData)
-		if done := handleOperationErr(h.log, err, w, "preResolve hook failed", h.operation); done {
-			return
-		}
-		if done := handleHookOut(ctx, w, h.log, out, "preResolve hook out is nil", h.operation); done {
-			return
-		}
-	}
-
-	if h.hooksConfig.mutatingPreResolve {
-		hookData := hookBaseData(r, hookBuf.Bytes(), ctx.Variables, nil)
-		out, err := h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.MutatingPreResolve, hookData)
-		if done := handleOperationErr(h.log, err, w, "mutatingPreResolve hook failed", h.operation); done {
-			return
-		}
-		if done := handleHookOut(ctx, w, h.log, out, "mutatingPreResolve hook out is nil", h.operation); done {
-			return
-		}
-	}
-
-	if h.hooksConfig.customResolve {
-		hookData := hookBaseData(r, hookBuf.Bytes(), ctx.Variables, nil)
-		out, err := h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.CustomResolve, hookData)
-		if done := handleOperationErr(h.log, err, w, "customResolve hook failed", h.operation); done {
-			return
-		}
-		if done := handleHookOut(ctx, w, h.log, out, "customResolve hook out is nil", h.operation); done {
-			return
-		}
-		// the customResolve hook can indicate to "skip" by responding with "null"
-		// so, we only write the response if it's not null
-		if !bytes.Equal(out.Response, literal.NULL) {
-			_, _ = w.Write(out.Response)
+	// stale responses are only served if the origin fails, so responses with errors are rejected then
+	transformed, err := h.resolveQuery(ctx, r, buf, hookBuf, staleItem != nil)
+	var stepErr *resolveStepError
+	if errors.As(err, &stepErr) {
+		if stepErr.origin && staleItem != nil {
+			h.log.Debug("serving stale response because the origin failed",
+				abstractlogger.String("operation", h.operation.Name),
+				abstractlogger.Error(stepErr.err),
+			)
+			h.writeCacheItem(w, r, *staleItem, "STALE-IF-ERROR")
 			return
 		}
-	}
-
-	err = h.resolver.ResolveGraphQLResponse(ctx, h.preparedPlan.Response, nil, buf)
-	if done := handleOperationErr(h.log, err, w, "ResolveGraphQLResponse failed", h.operation); done {
-		return
-	}
-	transformed, err := h.postResolveTransformer.Transform(buf.Bytes())
-	if done := handleOperationErr(h.log, err, w, "postResolveTransformer failed", h.operation); done {
+		handleOperationErr(h.log, stepErr.err, w, stepErr.step, h.operation)
 		return
 	}
-	if h.hooksConfig.postResolve {
-		hookData := hookBaseData(r, hookBuf.Bytes(), ctx.Variables, transformed)
-		_, err = h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.PostResolve, hookData)
-		if done := handleOperationErr(h.log, err, w, "postResolve hook failed", h.operation); done {
-			return
-		}
-	}
-	if h.hooksConfig.mutatingPostResolve {
-		hookData := hookBaseData(r, hookBuf.Bytes(), ctx.Variables, transformed)
-		out, err := h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.MutatingPostResolve, hookData)
-		if done := handleOperationErr(h.log, err, w, "mutatingPostResolve hook failed", h.operation); done {
-			return
-		}
-		if out == nil {
-			h.log.Error("MutatingPostResolve query hook response is empty")
-			http.Error(w, "MutatingPostResolve query hook response is empty", http.StatusInternalServerError)
-			return
-		}
-		transformed = out.Response
-	}
 
 	hash := xxhash.New()
 	_, _ = hash.Write(h.configHash)
@@ -1218,18 +1770,13 @@ func (h *QueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header()["ETag"] = []string{ETag}
 
 	if h.cacheConfig.enable {
-		if h.cacheConfig.public {
-			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d", h.cacheConfig.maxAge, h.cacheConfig.staleWhileRevalidate))
-		} else {
-			w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d, stale-while-revalidate=%d", h.cacheConfig.maxAge, h.cacheConfig.staleWhileRevalidate))
-		}
-
+		w.Header().Set("Cache-Control", h.cacheConfig.cacheControl())
 		w.Header().Set("Age", "0")
 
 		cacheData := make([]byte, len(transformed))
 		copy(cacheData, transformed)
 
-		h.cache.SetWithTTL(cacheKey, cacheData, time.Second*time.Duration(h.cacheConfig.maxAge+h.cacheConfig.staleWhileRevalidate))
+		h.cache.SetWithTags(cacheKey, cacheData, h.cacheConfig.ttl(), h.cacheTags(ctx.Variables))
 	}
 
 	ifNoneMatch := r.Header.Get("If-None-Match")
@@ -1420,6 +1967,8 @@ type MutationHandler struct {
 	jsonStringInterpolator *interpolate.StringInterpolator
 	postResolveTransformer *postresolvetransform.Transformer
 	renameTypeNames        []resolve.RenameTypeName
+	cache                  apicache.Cache
+	configHash             []byte
 }
 
 func (h *MutationHandler) parseFormVariables(r *http.Request) []byte {
@@ -1583,6 +2132,10 @@ func (h *MutationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		transformed = out.Response
 	}
 
+	if h.cache != nil {
+		h.invalidateCache(ctx.Context, ctx.Variables)
+	}
+
 	reader := bytes.NewReader(transformed)
 	_, err = reader.WriteTo(w)
 	if done := handleOperationErr(h.log, err, w, "writing response failed", h.operation); done {
@@ -1606,6 +2159,7 @@ type SubscriptionHandler struct {
 	queryParamsAllowList   []string
 	hooksClient            *hooks.Client
 	hooksConfig            hooksConfig
+	metrics                *metrics.Metrics
 }
 
 func (h *SubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -1647,6 +2201,22 @@ func (h *SubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 
 	ctx.Variables = postProcessVariables(h.operation, r, ctx.Variables)
 
+	// all lifecycle hooks of a subscription share its id, so the hooks server can correlate them
+	var subscriptionID string
+	if h.hooksConfig.onSubscriptionStart || h.hooksConfig.onSubscriptionEnd || h.hooksConfig.onSubscriptionMessageFilter {
+		subscriptionID, _ = uuid.GenerateUUID()
+	}
+
+	// the subscription can only be rejected before the response headers are flushed
+	if h.hooksConfig.onSubscriptionStart {
+		if done := h.handleOnSubscriptionStart(ctx, w, r, subscriptionID); done {
+			return
+		}
+	}
+	if h.hooksConfig.onSubscriptionEnd {
+		defer h.handleOnSubscriptionEnd(ctx, r, subscriptionID)
+	}
+
 	flushWriter, ok := getFlushWriter(ctx, r, w)
 	if !ok {
 		http.Error(w, "Connection not flushable", http.StatusBadRequest)
@@ -1711,6 +2281,14 @@ func (h *SubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		flushWriter.mutatingPostResolveCallback = &callback
 	}
 
+	if h.hooksConfig.onSubscriptionMessageFilter {
+		callback := h.messageFilterCallback(r, subscriptionID)
+		flushWriter.messageFilterCallback = &callback
+	}
+
+	h.metrics.SubscriptionStarted(h.operation.Name)
+	defer h.metrics.SubscriptionEnded(h.operation.Name)
+
 	err = h.resolver.ResolveGraphQLSubscription(ctx, h.preparedPlan.Response, flushWriter)
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
@@ -1724,6 +2302,85 @@ func (h *SubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// messageFilterCallback calls the onSubscriptionMessageFilter hook for every message of the subscription
+func (h *SubscriptionHandler) messageFilterCallback(r *http.Request, subscriptionID string) flushWriterMessageFilterCallback {
+	return func(ctx *resolve.Context, resp []byte) (drop bool) {
+		hookData := subscriptionHookData(r, ctx.Variables, subscriptionID, resp)
+		out, err := h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.OnSubscriptionMessageFilter, hookData)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				// messages might only be meant for some users, so they are dropped if they can't be filtered
+				h.log.Error("onSubscriptionMessageFilter hook failed", abstractlogger.Error(err))
+			}
+			return true
+		}
+		if out == nil || len(out.Response) == 0 {
+			return false
+		}
+		var response hooks.OnSubscriptionMessageFilterHookResponse
+		if err := json.Unmarshal(out.Response, &response); err != nil {
+			h.log.Error("onSubscriptionMessageFilter hook response is invalid", abstractlogger.Error(err))
+			return true
+		}
+		return response.Drop
+	}
+}
+
+// handleOnSubscriptionStart calls the onSubscriptionStart hook and responds to rejected subscriptions
+func (h *SubscriptionHandler) handleOnSubscriptionStart(ctx *resolve.Context, w http.ResponseWriter, r *http.Request, subscriptionID string) (done bool) {
+	hookData := subscriptionHookData(r, ctx.Variables, subscriptionID, nil)
+	out, err := h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.OnSubscriptionStart, hookData)
+	if done := handleOperationErr(h.log, err, w, "onSubscriptionStart hook failed", h.operation); done {
+		return true
+	}
+	if done := handleHookOut(ctx, w, h.log, out, "onSubscriptionStart hook out is nil", h.operation); done {
+		return true
+	}
+	var response hooks.OnSubscriptionStartHookResponse
+	if len(out.Response) != 0 {
+		if err := json.Unmarshal(out.Response, &response); err != nil {
+			h.log.Error("onSubscriptionStart hook response is invalid", abstractlogger.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return true
+		}
+	}
+	if !response.Reject {
+		return false
+	}
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusForbidden
+	}
+	message := response.Message
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	http.Error(w, message, statusCode)
+	return true
+}
+
+// handleOnSubscriptionEnd calls the onSubscriptionEnd hook, also if the subscription ended because the client went away
+func (h *SubscriptionHandler) handleOnSubscriptionEnd(ctx *resolve.Context, r *http.Request, subscriptionID string) {
+	hookData := subscriptionHookData(r, ctx.Variables, subscriptionID, nil)
+	_, err := h.hooksClient.DoOperationRequest(detachedContext{ctx.Context}, h.operation.Name, hooks.OnSubscriptionEnd, hookData)
+	if err != nil {
+		h.log.Error("onSubscriptionEnd hook failed",
+			abstractlogger.String("operationName", h.operation.Name),
+			abstractlogger.Error(err),
+		)
+	}
+}
+
+// subscriptionHookData is the payload of the subscription lifecycle hooks,
+// in addition to the payload of operation hooks it carries the id of the subscription
+func subscriptionHookData(r *http.Request, variables []byte, subscriptionID string, response []byte) []byte {
+	hookData := hookBaseData(r, nil, variables, response)
+	if id, err := json.Marshal(subscriptionID); err == nil {
+		hookData, _ = jsonparser.Set(hookData, id, "subscriptionId")
+	}
+	return hookData
+}
+
 func getOperationType(operation, definition *ast.Document, operationName string) ast.OperationType {
 
 	walker := astvisitor.NewWalker(8)
@@ -1763,6 +2420,7 @@ func (o *operationKindVisitor) EnterOperationDefinition(ref int) {
 
 type flushWriterMutatingPostResolveCallback func(ctx *resolve.Context, resp []byte) ([]byte, error)
 type flushWriterPostResolveCallback func(ctx *resolve.Context, resp []byte)
+type flushWriterMessageFilterCallback func(ctx *resolve.Context, resp []byte) (drop bool)
 
 type httpFlushWriter struct {
 	writer                      io.Writer
@@ -1774,6 +2432,7 @@ type httpFlushWriter struct {
 	buf                         *bytes.Buffer
 	mutatingPostResolveCallback *flushWriterMutatingPostResolveCallback
 	postResolveCallback         *flushWriterPostResolveCallback
+	messageFilterCallback       *flushWriterMessageFilterCallback
 	ctx                         *resolve.Context
 }
 
@@ -1792,6 +2451,10 @@ func (f *httpFlushWriter) Flush() {
 	resp := f.buf.Bytes()
 	f.buf.Reset()
 
+	if f.messageFilterCallback != nil && (*f.messageFilterCallback)(f.ctx, resp) {
+		return
+	}
+
 	if f.postResolveCallback != nil {
 		(*f.postResolveCallback)(f.ctx, resp)
 	}
@@ -1882,6 +2545,7 @@ func (r *Builder) registerAuth(pathPrefix string, insecureCookies bool) {
 	r.router.Use(authentication.NewCSRFMw(authentication.CSRFConfig{
 		Path:            pathPrefix,
 		InsecureCookies: insecureCookies,
+		CookiePath:      r.cookiePath,
 		Secret:          csrfSecret,
 	}))
 
@@ -1896,6 +2560,7 @@ func (r *Builder) registerAuth(pathPrefix string, insecureCookies bool) {
 		MWClient:          r.middlewareClient,
 		Log:               r.log,
 		InsecureCookies:   insecureCookies,
+		CookiePath:        r.cookiePath,
 		Cookie:            cookie,
 	})
 	cookieBasedAuth.Path("/csrf").Methods(http.MethodGet, http.MethodOptions).Handler(&authentication.CSRFTokenHandler{})
@@ -1907,6 +2572,7 @@ func (r *Builder) registerCookieAuthHandlers(router *mux.Router, cookie *securec
 
 	router.Path("/user/logout").Methods(http.MethodGet, http.MethodOptions).Handler(&authentication.UserLogoutHandler{
 		InsecureCookies:                  r.insecureCookies,
+		CookiePath:                       r.cookiePath,
 		OpenIDConnectIssuersToLogoutURLs: r.configureOpenIDConnectIssuerLogoutURLs(),
 		Hooks:                            authHooks,
 	})
@@ -2049,6 +2715,7 @@ func (r *Builder) configureCookieProvider(router *mux.Router, provider *wgpb.Aut
 			PathPrefix:         pathPrefix,
 			InsecureCookies:    r.insecureCookies,
 			ForceRedirectHttps: r.forceHttpsRedirects,
+			CookiePath:         r.cookiePath,
 			Cookie:             cookie,
 		}, authentication.Hooks{
 			Client:                     r.middlewareClient,
@@ -2085,6 +2752,7 @@ func (r *Builder) configureCookieProvider(router *mux.Router, provider *wgpb.Aut
 			PathPrefix:         pathPrefix,
 			InsecureCookies:    r.insecureCookies,
 			ForceRedirectHttps: r.forceHttpsRedirects,
+			CookiePath:         r.cookiePath,
 			Cookie:             cookie,
 		}, authentication.Hooks{
 			Client:                     r.middlewareClient,
@@ -2254,6 +2922,16 @@ func handleOperationErr(log abstractlogger.Logger, err error, w http.ResponseWri
 		w.WriteHeader(http.StatusGatewayTimeout)
 		return true
 	}
+	if errors.Is(err, hooks.ErrCircuitOpen) {
+		// the hooks server is known to be down, so the request isn't even sent
+		log.Error(errorMessage,
+			abstractlogger.String("operationName", operation.Name),
+			abstractlogger.String("operationType", operation.OperationType.String()),
+			abstractlogger.Error(err),
+		)
+		http.Error(w, errorMessage, http.StatusServiceUnavailable)
+		return true
+	}
 	log.Error(errorMessage,
 		abstractlogger.String("operationName", operation.Name),
 		abstractlogger.String("operationType", operation.OperationType.String()),
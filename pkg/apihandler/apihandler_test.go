@@ -2,25 +2,47 @@ package apihandler
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/gavv/httpexpect/v2"
 	"github.com/jensneuse/abstractlogger"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/sync/singleflight"
 
+	"github.com/wundergraph/graphql-go-tools/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/pkg/asttransform"
 	"github.com/wundergraph/graphql-go-tools/pkg/engine/plan"
 	"github.com/wundergraph/graphql-go-tools/pkg/engine/resolve"
 
 	"github.com/wundergraph/wundergraph/pkg/apicache"
 	"github.com/wundergraph/wundergraph/pkg/authentication"
+	"github.com/wundergraph/wundergraph/pkg/hooks"
 	"github.com/wundergraph/wundergraph/pkg/inputvariables"
 	"github.com/wundergraph/wundergraph/pkg/interpolate"
+	"github.com/wundergraph/wundergraph/pkg/metrics"
 	"github.com/wundergraph/wundergraph/pkg/pool"
 	"github.com/wundergraph/wundergraph/pkg/postresolvetransform"
+	"github.com/wundergraph/wundergraph/pkg/querylimits"
+	"github.com/wundergraph/wundergraph/pkg/ratelimit"
 	"github.com/wundergraph/wundergraph/pkg/wgpb"
 )
 
@@ -39,6 +61,46 @@ func (f *FakeResolver) ResolveGraphQLResponse(ctx *resolve.Context, response *re
 	return
 }
 
+// syncResolver is safe for concurrent use, e.g. by background revalidations.
+// If release is set, resolving blocks until it's closed.
+type syncResolver struct {
+	mu          sync.Mutex
+	invocations int
+	response    []byte
+	err         error
+	release     chan struct{}
+}
+
+func (s *syncResolver) ResolveGraphQLResponse(ctx *resolve.Context, response *resolve.GraphQLResponse, data []byte, writer io.Writer) error {
+	s.mu.Lock()
+	release := s.release
+	s.mu.Unlock()
+	if release != nil {
+		<-release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invocations++
+	if s.err != nil {
+		return s.err
+	}
+	_, err := writer.Write(s.response)
+	return err
+}
+
+func (s *syncResolver) set(response []byte, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.response = response
+	s.err = err
+}
+
+func (s *syncResolver) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.invocations
+}
+
 func TestQueryHandler_VariablesIgnore(t *testing.T) {
 
 	interpoalteNothing, err := interpolate.NewStringInterpolator(`{}`)
@@ -302,3 +364,1040 @@ func TestLogMiddleware_Debug(t *testing.T) {
 
 	})
 }
+
+type mapCache map[string][]byte
+
+func (m mapCache) SetWithTTL(key string, data []byte, ttl time.Duration) {
+	m[key] = data
+}
+
+func (m mapCache) Set(key string, data []byte) {
+	m[key] = data
+}
+
+func (m mapCache) Get(ctx context.Context, key string) (apicache.CacheItem, bool) {
+	data, ok := m[key]
+	return apicache.CacheItem{Data: data}, ok
+}
+
+func (m mapCache) SetWithTags(key string, data []byte, ttl time.Duration, tags []string) {
+	m[key] = data
+}
+
+func (m mapCache) Delete(ctx context.Context, key string) {
+	delete(m, key)
+}
+
+func (m mapCache) DeleteByTag(ctx context.Context, tag string) {}
+
+// tagRecordingCache records the tags passed to DeleteByTag
+type tagRecordingCache struct {
+	mapCache
+	deletedTags []string
+}
+
+func (c *tagRecordingCache) DeleteByTag(ctx context.Context, tag string) {
+	c.deletedTags = append(c.deletedTags, tag)
+}
+
+func TestGraphQLHandler_PersistedQuery(t *testing.T) {
+	const (
+		query     = `query Weather { weather { temperature } }`
+		wrongHash = "4ff2ac2d4ab2bfa3c2d1e5e6d1ac37bf0fe0b1b1a4b3bbf70d3a5e0fdb2a5de8"
+	)
+	sum := sha256.Sum256([]byte(query))
+	queryHash := hex.EncodeToString(sum[:])
+	extensions := func(hash string) []byte {
+		return []byte(`{"persistedQuery":{"version":1,"sha256Hash":"` + hash + `"}}`)
+	}
+
+	cache := mapCache{}
+	handler := &GraphQLHandler{
+		log:   &abstractlogger.Noop{},
+		cache: cache,
+	}
+	ctx := context.Background()
+
+	t.Run("without persisted query", func(t *testing.T) {
+		requestQuery, err := handler.loadPersistedQuery(ctx, nil, query)
+		assert.NoError(t, err)
+		assert.Equal(t, query, requestQuery)
+		assert.Empty(t, cache)
+	})
+	t.Run("unknown hash", func(t *testing.T) {
+		_, err := handler.loadPersistedQuery(ctx, extensions(queryHash), "")
+		assert.Equal(t, errPersistedQueryNotFound, err)
+	})
+	t.Run("hash mismatch", func(t *testing.T) {
+		_, err := handler.loadPersistedQuery(ctx, extensions(wrongHash), query)
+		assert.Equal(t, errPersistedQueryHashMismatch, err)
+		assert.Empty(t, cache)
+	})
+	t.Run("unsupported version", func(t *testing.T) {
+		_, err := handler.loadPersistedQuery(ctx, []byte(`{"persistedQuery":{"version":2,"sha256Hash":"`+queryHash+`"}}`), "")
+		assert.Equal(t, errPersistedQueryVersion, err)
+	})
+	t.Run("register and load", func(t *testing.T) {
+		requestQuery, err := handler.loadPersistedQuery(ctx, extensions(queryHash), query)
+		assert.NoError(t, err)
+		assert.Equal(t, query, requestQuery)
+
+		requestQuery, err = handler.loadPersistedQuery(ctx, extensions(strings.ToUpper(queryHash)), "")
+		assert.NoError(t, err)
+		assert.Equal(t, query, requestQuery)
+	})
+	t.Run("not found response", func(t *testing.T) {
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		e := httpexpect.WithConfig(httpexpect.Config{
+			BaseURL:  srv.URL,
+			Reporter: httpexpect.NewAssertReporter(t),
+		})
+
+		e.POST("/graphql").
+			WithBytes([]byte(`{"operationName":"Weather","extensions":` + string(extensions(wrongHash)) + `}`)).
+			Expect().
+			Status(http.StatusOK).
+			Body().Equal(`{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`)
+	})
+}
+
+func TestGraphQLHandler_Batch(t *testing.T) {
+	handler := &GraphQLHandler{
+		log:   &abstractlogger.Noop{},
+		pool:  pool.New(),
+		cache: mapCache{},
+	}
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	e.POST("/graphql").
+		WithBytes([]byte(`[
+			{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"4ff2ac2d4ab2bfa3c2d1e5e6d1ac37bf0fe0b1b1a4b3bbf70d3a5e0fdb2a5de8"}}},
+			{"query":"query Broken {"}
+		]`)).
+		Expect().
+		Status(http.StatusOK).
+		ContentType("application/json").
+		Body().Equal(`[{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]},{"errors":[{"message":"bad request"}]}]`)
+
+	e.POST("/graphql").
+		WithBytes([]byte(`[]`)).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// batches are limited to defaultMaxBatchSize operations
+	batch := "[" + strings.TrimSuffix(strings.Repeat(`{"query":"{__typename}"},`, defaultMaxBatchSize+1), ",") + "]"
+	e.POST("/graphql").
+		WithBytes([]byte(batch)).
+		Expect().
+		Status(http.StatusBadRequest).
+		Body().Contains("exceeds the maximum of 10")
+}
+
+func TestGraphQLHandler_Get(t *testing.T) {
+	handler := &GraphQLHandler{
+		log:   &abstractlogger.Noop{},
+		pool:  pool.New(),
+		cache: mapCache{},
+	}
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	e.GET("/graphql").
+		WithQuery("query", `query Weather { weather } mutation SetWeather { setWeather }`).
+		WithQuery("operationName", "SetWeather").
+		Expect().
+		Status(http.StatusMethodNotAllowed).
+		Header("Allow").Equal(http.MethodPost)
+
+	req := httptest.NewRequest(http.MethodGet, `/graphql?query=query+Weather($city:String){weather(city:$city)}&operationName=Weather&variables={"city":"Berlin"}`, nil)
+	request := parseGraphQLGetRequest(req)
+	assert.Equal(t, `query Weather($city:String){weather(city:$city)}`, request.query)
+	assert.Equal(t, "Weather", string(request.operationName))
+	assert.Equal(t, `{"city":"Berlin"}`, string(request.variables))
+	assert.Nil(t, request.extensions)
+}
+
+func TestGraphQLHandler_Limits(t *testing.T) {
+	definition, report := astparser.ParseGraphqlDocumentString(`
+		type Query { users(first: Int): [User!]! }
+		type User { id: ID! friends(first: Int): [User!]! }
+	`)
+	assert.False(t, report.HasErrors())
+	assert.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+	handler := &GraphQLHandler{
+		definition:  &definition,
+		log:         &abstractlogger.Noop{},
+		pool:        pool.New(),
+		sf:          &singleflight.Group{},
+		prepared:    map[uint64]planWithExtractedVariables{},
+		preparedMux: &sync.RWMutex{},
+		cache:       mapCache{},
+		limits: querylimits.Limits{
+			MaxDepth: 2,
+			MaxCost:  50,
+		},
+	}
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"{ users { friends { id } } }"}`)).
+		Expect().
+		Status(http.StatusBadRequest).
+		Body().Equal(`{"errors":[{"message":"operation depth 3 exceeds the maximum depth of 2","extensions":{"code":"OPERATION_LIMIT_EXCEEDED"}}],"extensions":{"cost":{"depth":3,"fields":3,"cost":3}}}`)
+
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"query Users($first: Int) { users(first: $first) { id } }","variables":{"first":100}}`)).
+		Expect().
+		Status(http.StatusBadRequest).
+		Body().Equal(`{"errors":[{"message":"operation cost 101 exceeds the maximum cost of 50","extensions":{"code":"OPERATION_LIMIT_EXCEEDED"}}],"extensions":{"cost":{"depth":2,"fields":2,"cost":101}}}`)
+}
+
+func TestGraphQLHandler_Metrics(t *testing.T) {
+	definition, report := astparser.ParseGraphqlDocumentString(`
+		type Query { users(first: Int): [User!]! }
+		type User { id: ID! }
+	`)
+	assert.False(t, report.HasErrors())
+	assert.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+	m := metrics.New()
+	handler := &GraphQLHandler{
+		definition:  &definition,
+		log:         &abstractlogger.Noop{},
+		pool:        pool.New(),
+		sf:          &singleflight.Group{},
+		prepared:    map[uint64]planWithExtractedVariables{},
+		preparedMux: &sync.RWMutex{},
+		cache:       mapCache{},
+		metrics:     m,
+		limits: querylimits.Limits{
+			MaxCost: 50,
+		},
+	}
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	// requests are labeled by the executed operation, not by the operationName sent
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"query Users($first: Int) { users(first: $first) { id } }","variables":{"first":100}}`)).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/graphql").
+		WithBytes([]byte(`{"operationName":"Unknown","query":"query Users { users { id } }"}`)).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"query Broken {"}`)).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `wundergraph_node_operation_duration_seconds_count{operation="Users",status="400",tenant="",type="graphql"} 1`)
+	assert.Contains(t, rec.Body.String(), `wundergraph_node_operation_duration_seconds_count{operation="graphql",status="400",tenant="",type="graphql"} 2`)
+	assert.NotContains(t, rec.Body.String(), `operation="Unknown"`)
+}
+
+func TestGraphQLHandler_Hooks(t *testing.T) {
+	definition, report := astparser.ParseGraphqlDocumentString(`
+		type Query { users(first: Int): [User!]! }
+		type User { id: ID! }
+	`)
+	assert.False(t, report.HasErrors())
+	assert.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+	var hookRequests []string
+	hooksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hookRequests = append(hookRequests, r.URL.Path+" "+string(body))
+		switch r.URL.Path {
+		case "/global/graphql/Users/mutatingPreResolve":
+			_, _ = w.Write([]byte(`{"input":{"first":100}}`))
+		case "/global/graphql/Failing/preResolve":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer hooksServer.Close()
+
+	handler := &GraphQLHandler{
+		definition:  &definition,
+		log:         &abstractlogger.Noop{},
+		pool:        pool.New(),
+		sf:          &singleflight.Group{},
+		prepared:    map[uint64]planWithExtractedVariables{},
+		preparedMux: &sync.RWMutex{},
+		cache:       mapCache{},
+		limits: querylimits.Limits{
+			MaxCost: 50,
+		},
+		hooksClient: hooks.NewClient(hooksServer.URL, abstractlogger.NoopLogger,
+			hooks.WithDefaultPolicy(hooks.Policy{Timeout: time.Second}),
+		),
+		hooksConfig: map[string]*wgpb.GraphQLEndpointHooksConfiguration{
+			"Users":   {OperationName: "Users", PreResolve: true, MutatingPreResolve: true},
+			"Failing": {OperationName: "Failing", PreResolve: true},
+		},
+	}
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	// the limits apply to the variables returned by mutatingPreResolve
+	e.POST("/graphql").
+		WithBytes([]byte(`{"operationName":"Users","query":"query Users($first: Int) { users(first: $first) { id } }","variables":{"first":1}}`)).
+		Expect().
+		Status(http.StatusBadRequest).
+		Body().Contains("operation cost 101 exceeds the maximum cost of 50")
+	assert.Equal(t, []string{
+		`/global/graphql/Users/preResolve {"__wg":{},"input":{"first":1},"operationName":"Users","query":"query Users($first: Int) { users(first: $first) { id } }"}`,
+		`/global/graphql/Users/mutatingPreResolve {"__wg":{},"input":{"first":1},"operationName":"Users","query":"query Users($first: Int) { users(first: $first) { id } }"}`,
+	}, hookRequests)
+
+	e.POST("/graphql").
+		WithBytes([]byte(`{"operationName":"Failing","query":"query Failing { users { id } }"}`)).
+		Expect().
+		Status(http.StatusInternalServerError).
+		Body().Contains("preResolve hook failed")
+
+	// hooks are looked up by the executed operation, renamed operations match no hooks and are rejected
+	hookRequests = nil
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"query Other($first: Int) { users(first: $first) { id } }","variables":{"first":100}}`)).
+		Expect().
+		Status(http.StatusForbidden).
+		Body().Contains("OPERATION_NOT_ALLOWED")
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"{ users(first: 100) { id } }"}`)).
+		Expect().
+		Status(http.StatusForbidden)
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"query Failing { users { id } }"}`)).
+		Expect().
+		Status(http.StatusInternalServerError)
+	assert.Equal(t, []string{
+		`/global/graphql/Failing/preResolve {"__wg":{},"operationName":"","query":"query Failing { users { id } }"}`,
+	}, hookRequests)
+
+	// hooks aren't called for invalid operations
+	hookRequests = nil
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"query Failing { unknown }"}`)).
+		Expect().
+		Status(http.StatusBadRequest)
+	assert.Empty(t, hookRequests)
+
+	// operations without hooks only run without them if explicitly allowed
+	handler.hooksOptional = true
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"query Other($first: Int) { users(first: $first) { id } }","variables":{"first":100}}`)).
+		Expect().
+		Status(http.StatusBadRequest).
+		Body().Contains("OPERATION_LIMIT_EXCEEDED")
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"{ users(first: 100) { id } }"}`)).
+		Expect().
+		Status(http.StatusBadRequest).
+		Body().Contains("OPERATION_LIMIT_EXCEEDED")
+	assert.Empty(t, hookRequests)
+	handler.hooksOptional = false
+
+	// the catch-all entry applies to all other operations
+	handler.hooksConfig["*"] = &wgpb.GraphQLEndpointHooksConfiguration{OperationName: "*", PreResolve: true}
+	hookRequests = nil
+	e.POST("/graphql").
+		WithBytes([]byte(`{"query":"query Other($first: Int) { users(first: $first) { id } }","variables":{"first":100}}`)).
+		Expect().
+		Status(http.StatusBadRequest).
+		Body().Contains("OPERATION_LIMIT_EXCEEDED")
+	assert.Equal(t, []string{
+		`/global/graphql/*/preResolve {"__wg":{},"input":{"first":100},"operationName":"","query":"query Other($first: Int) { users(first: $first) { id } }"}`,
+	}, hookRequests)
+}
+
+func TestSubscriptionHandler_LifecycleHooks(t *testing.T) {
+	var hookRequests []string
+	hooksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hookRequests = append(hookRequests, r.URL.Path+" "+string(body))
+		switch r.URL.Path {
+		case "/operation/Limited/onSubscriptionStart":
+			_, _ = w.Write([]byte(`{"response":{"reject":true,"statusCode":429,"message":"too many subscriptions"}}`))
+		case "/operation/Messages/onSubscriptionMessageFilter":
+			if bytes.Contains(body, []byte(`"secret"`)) {
+				_, _ = w.Write([]byte(`{"response":{"drop":true}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"response":{}}`))
+		default:
+			_, _ = w.Write([]byte(`{"response":{}}`))
+		}
+	}))
+	defer hooksServer.Close()
+
+	hooksClient := hooks.NewClient(hooksServer.URL, abstractlogger.NoopLogger)
+	handler := func(operationName string) *SubscriptionHandler {
+		return &SubscriptionHandler{
+			log:         &abstractlogger.Noop{},
+			operation:   &wgpb.Operation{Name: operationName, OperationType: wgpb.OperationType_SUBSCRIPTION},
+			hooksClient: hooksClient,
+		}
+	}
+	r := httptest.NewRequest(http.MethodGet, "/operations/Messages", nil)
+
+	t.Run("start", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		done := handler("Limited").handleOnSubscriptionStart(resolve.NewContext(context.Background()), rec, r, "1")
+		assert.True(t, done)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "too many subscriptions\n", rec.Body.String())
+
+		rec = httptest.NewRecorder()
+		done = handler("Messages").handleOnSubscriptionStart(resolve.NewContext(context.Background()), rec, r, "2")
+		assert.False(t, done)
+	})
+	t.Run("end", func(t *testing.T) {
+		hookRequests = nil
+		// the hook is also called once the client went away
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		handler("Messages").handleOnSubscriptionEnd(resolve.NewContext(ctx), r, "2")
+		assert.Equal(t, []string{`/operation/Messages/onSubscriptionEnd {"__wg":{},"subscriptionId":"2"}`}, hookRequests)
+	})
+	t.Run("message filter", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		callback := handler("Messages").messageFilterCallback(r, "2")
+		flushWriter := &httpFlushWriter{
+			writer:                rec,
+			flusher:               rec,
+			buf:                   &bytes.Buffer{},
+			ctx:                   resolve.NewContext(context.Background()),
+			messageFilterCallback: &callback,
+		}
+		for _, message := range []string{`{"data":{"message":"public"}}`, `{"data":{"message":"secret"}}`} {
+			_, _ = flushWriter.Write([]byte(message))
+			flushWriter.Flush()
+		}
+		assert.Equal(t, "{\"data\":{\"message\":\"public\"}}\n\n", rec.Body.String())
+	})
+}
+
+func TestBuilder_RateLimitOperation(t *testing.T) {
+	builder := &Builder{
+		api:         &Api{PathPrefix: "api/main"},
+		log:         &abstractlogger.Noop{},
+		rateLimiter: ratelimit.NewInMemory(),
+	}
+	operation := &wgpb.Operation{
+		Name: "Weather",
+		RateLimitConfig: &wgpb.OperationRateLimitConfig{
+			Enable:     true,
+			Requests:   1,
+			PerSecond:  60,
+			Key:        wgpb.RateLimitKeyKind_HEADER_RATE_LIMIT_KEY,
+			HeaderName: "X-Api-Key",
+		},
+	}
+	handler := builder.rateLimitOperation(operation, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	res := e.GET("/operations/Weather").WithHeader("X-Api-Key", "a").Expect()
+	res.Status(http.StatusOK)
+	res.Header("RateLimit-Limit").Equal("1")
+	res.Header("RateLimit-Remaining").Equal("0")
+	res.Header("RateLimit-Reset").Equal("60")
+
+	res = e.GET("/operations/Weather").WithHeader("X-Api-Key", "a").Expect()
+	res.Status(http.StatusTooManyRequests)
+	res.Header("Retry-After").Equal("60")
+
+	// other clients have their own budget, requests without the header are limited by IP
+	e.GET("/operations/Weather").WithHeader("X-Api-Key", "b").Expect().Status(http.StatusOK)
+	e.GET("/operations/Weather").Expect().Status(http.StatusOK)
+	e.GET("/operations/Weather").Expect().Status(http.StatusTooManyRequests)
+}
+
+func TestClientIP(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	trustedProxies := []*net.IPNet{proxies}
+
+	request := func(remoteAddr string, header http.Header) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/operations/Weather", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header = header
+		return r
+	}
+
+	// forwarded headers are ignored without trusted proxies and from untrusted peers
+	assert.Equal(t, "10.0.0.1", clientIP(request("10.0.0.1:1234", http.Header{"X-Forwarded-For": {"1.2.3.4"}}), nil))
+	assert.Equal(t, "5.6.7.8", clientIP(request("5.6.7.8:1234", http.Header{"X-Forwarded-For": {"1.2.3.4"}}), trustedProxies))
+
+	// the first forwarded IP which isn't a trusted proxy is the client, IPs added by the client are ignored
+	assert.Equal(t, "1.2.3.4", clientIP(request("10.0.0.1:1234", http.Header{"X-Forwarded-For": {"9.9.9.9, 1.2.3.4", "10.0.0.2"}}), trustedProxies))
+	assert.Equal(t, "2001:db8::17", clientIP(request("10.0.0.1:1234", http.Header{
+		"Forwarded":       {`for=9.9.9.9, for="[2001:db8::17]:4711";proto=https`},
+		"X-Forwarded-For": {"1.2.3.4"},
+	}), trustedProxies))
+
+	// requests from trusted proxies without forwarded headers are attributed to the proxy
+	assert.Equal(t, "10.0.0.1", clientIP(request("10.0.0.1:1234", http.Header{}), trustedProxies))
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	operations := []*wgpb.Operation{
+		{
+			Name:          "User",
+			OperationType: wgpb.OperationType_QUERY,
+		},
+		{
+			Name:          "UpdateUser",
+			OperationType: wgpb.OperationType_MUTATION,
+			CacheInvalidations: []*wgpb.OperationCacheInvalidation{
+				{OperationName: "User", Variables: map[string]string{"id": "input.userId"}},
+				{OperationName: "Users"},
+			},
+		},
+	}
+
+	query := &QueryHandler{
+		configHash: []byte("hash"),
+		operation:  operations[0],
+		cacheConfig: cacheConfig{
+			tags:                  []string{"users"},
+			invalidationVariables: cacheInvalidationVariables(operations, "User"),
+		},
+	}
+	assert.Equal(t, [][]string{{"id"}}, query.cacheConfig.invalidationVariables)
+	assert.Equal(t, []string{"hash:operation:User", "hash:tag:users", "hash:operation:User:id=1"}, query.cacheTags([]byte(`{"id":"1"}`)))
+	assert.Equal(t, []string{"hash:operation:User", "hash:tag:users"}, query.cacheTags([]byte(`{}`)))
+
+	cache := &tagRecordingCache{mapCache: mapCache{}}
+	mutation := &MutationHandler{
+		log:        &abstractlogger.Noop{},
+		configHash: []byte("hash"),
+		operation:  operations[1],
+		cache:      cache,
+	}
+
+	mutation.invalidateCache(context.Background(), []byte(`{"input":{"userId":"1"}}`))
+	assert.Equal(t, []string{"hash:operation:User:id=1", "hash:operation:Users"}, cache.deletedTags)
+
+	// without the variable, all responses of the operation are evicted
+	cache.deletedTags = nil
+	mutation.invalidateCache(context.Background(), []byte(`{}`))
+	assert.Equal(t, []string{"hash:operation:User", "hash:operation:Users"}, cache.deletedTags)
+}
+
+func TestCachePurgeHandler(t *testing.T) {
+	cache := &tagRecordingCache{mapCache: mapCache{}}
+	handler := &CachePurgeHandler{
+		cache:      cache,
+		configHash: "hash",
+		token:      "secret",
+		log:        &abstractlogger.Noop{},
+	}
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	e.POST("/internal/api/main/cache/purge").
+		WithJSON(map[string]string{"operationName": "User"}).
+		Expect().Status(http.StatusUnauthorized)
+	e.POST("/internal/api/main/cache/purge").
+		WithHeader("Authorization", "Bearer wrong").
+		WithJSON(map[string]string{"operationName": "User"}).
+		Expect().Status(http.StatusUnauthorized)
+	e.POST("/internal/api/main/cache/purge").
+		WithHeader("Authorization", "Bearer secret").
+		WithJSON(map[string]string{}).
+		Expect().Status(http.StatusBadRequest)
+	assert.Empty(t, cache.deletedTags)
+
+	e.POST("/internal/api/main/cache/purge").
+		WithHeader("Authorization", "Bearer secret").
+		WithJSON(map[string]string{"operationName": "User"}).
+		Expect().Status(http.StatusNoContent)
+	e.POST("/internal/api/main/cache/purge").
+		WithHeader("Authorization", "Bearer secret").
+		WithJSON(map[string]string{"tag": "users"}).
+		Expect().Status(http.StatusNoContent)
+	assert.Equal(t, []string{"hash:operation:User", "hash:tag:users"}, cache.deletedTags)
+}
+
+func TestQueryHandler_CacheKey(t *testing.T) {
+	handler := &QueryHandler{
+		configHash: []byte("hash"),
+		cacheConfig: cacheConfig{
+			varyByUser:    true,
+			varyByRoles:   true,
+			varyByHeaders: []string{"X-Tenant"},
+		},
+	}
+	request := func(user *authentication.User, tenant string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/main/operations/Me", nil)
+		if tenant != "" {
+			r.Header.Set("X-Tenant", tenant)
+		}
+		if user != nil {
+			r = r.WithContext(context.WithValue(r.Context(), "user", user))
+		}
+		return r
+	}
+
+	alice := &authentication.User{ProviderID: "github", UserID: "alice", Roles: []string{"user", "admin"}}
+	bob := &authentication.User{ProviderID: "github", UserID: "bob", Roles: []string{"admin", "user"}}
+
+	assert.Equal(t, "hash/api/main/operations/Me#header%3AX-Tenant=a&roles=admin&roles=user&user=github%3Aalice", handler.cacheKey(request(alice, "a")))
+	assert.NotEqual(t, handler.cacheKey(request(alice, "a")), handler.cacheKey(request(bob, "a")))
+	assert.NotEqual(t, handler.cacheKey(request(alice, "a")), handler.cacheKey(request(alice, "b")))
+	assert.NotEqual(t, handler.cacheKey(request(alice, "a")), handler.cacheKey(request(nil, "a")))
+
+	// users sharing the same roles share the cached responses
+	handler.cacheConfig.varyByUser = false
+	assert.Equal(t, handler.cacheKey(request(alice, "a")), handler.cacheKey(request(bob, "a")))
+
+	handler.cacheConfig = cacheConfig{}
+	assert.Equal(t, "hash/api/main/operations/Me", handler.cacheKey(request(alice, "a")))
+}
+
+func newCachingQueryHandler(t *testing.T, resolver QueryResolver, config cacheConfig) *QueryHandler {
+	interpolateNothing, err := interpolate.NewStringInterpolator(`{}`)
+	assert.NoError(t, err)
+	validateNothing, err := inputvariables.NewValidator(`{}`, true)
+	assert.NoError(t, err)
+	cache, err := apicache.NewInMemory(1e4)
+	assert.NoError(t, err)
+
+	return &QueryHandler{
+		resolver: resolver,
+		log:      &abstractlogger.Noop{},
+		preparedPlan: &plan.SynchronousResponsePlan{
+			Response: &resolve.GraphQLResponse{},
+		},
+		pool:        pool.New(),
+		cacheConfig: config,
+		cache:       cache,
+		operation: &wgpb.Operation{
+			Name:          "test",
+			OperationType: wgpb.OperationType_QUERY,
+		},
+		rbacEnforcer:           &authentication.RBACEnforcer{},
+		stringInterpolator:     interpolateNothing,
+		jsonStringInterpolator: interpolateNothing,
+		variablesValidator:     validateNothing,
+		postResolveTransformer: &postresolvetransform.Transformer{},
+	}
+}
+
+func TestQueryHandler_StaleWhileRevalidate(t *testing.T) {
+	resolver := &syncResolver{response: []byte(`{"data":{"me":{"name":"Jens"}}}`)}
+	handler := newCachingQueryHandler(t, resolver, cacheConfig{
+		enable:               true,
+		maxAge:               0,
+		staleWhileRevalidate: 60,
+	})
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	res := e.GET("/api/main").Expect()
+	res.Status(http.StatusOK)
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"MISS"})
+
+	time.Sleep(time.Millisecond * 1100)
+
+	// stale responses are served right away while concurrent requests share one revalidation
+	resolver.set([]byte(`{"data":{"me":{"name":"Jannik"}}}`), nil)
+	release := make(chan struct{})
+	resolver.mu.Lock()
+	resolver.release = release
+	resolver.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := e.GET("/api/main").Expect()
+			res.Status(http.StatusOK)
+			res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"STALE"})
+			res.Header("Cache-Control").Equal("private, max-age=0, stale-while-revalidate=60")
+			res.Body().Equal(`{"data":{"me":{"name":"Jens"}}}`)
+		}()
+	}
+	wg.Wait()
+	// ages are counted in seconds, revalidate at the start of a second so the entry is still fresh below
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		return resolver.count() == 2
+	}, time.Second, time.Millisecond*10)
+
+	assert.Eventually(t, func() bool {
+		res := e.GET("/api/main").Expect()
+		return res.Raw().Header.Get(WG_CACHE_HEADER) == "HIT" &&
+			res.Body().Raw() == `{"data":{"me":{"name":"Jannik"}}}`
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, 2, resolver.count())
+}
+
+func TestQueryHandler_RevalidateMutatingPostResolve(t *testing.T) {
+	hooksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		name := gjson.GetBytes(body, "response.data.me.name").String()
+		_, _ = w.Write([]byte(`{"response":{"data":{"me":{"name":"` + strings.ToUpper(name) + `"}}}}`))
+	}))
+	defer hooksServer.Close()
+
+	resolver := &syncResolver{response: []byte(`{"data":{"me":{"name":"Jens"}}}`)}
+	handler := newCachingQueryHandler(t, resolver, cacheConfig{
+		enable:               true,
+		maxAge:               0,
+		staleWhileRevalidate: 60,
+	})
+	handler.hooksClient = hooks.NewClient(hooksServer.URL, abstractlogger.NoopLogger)
+	handler.hooksConfig.mutatingPostResolve = true
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	e.GET("/api/main").Expect().Status(http.StatusOK).Body().Equal(`{"data":{"me":{"name":"JENS"}}}`)
+
+	time.Sleep(time.Millisecond * 1100)
+	resolver.set([]byte(`{"data":{"me":{"name":"Jannik"}}}`), nil)
+
+	res := e.GET("/api/main").Expect()
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"STALE"})
+	res.Body().Equal(`{"data":{"me":{"name":"JENS"}}}`)
+
+	// the background revalidation caches the response as mutated by the hook
+	assert.Eventually(t, func() bool {
+		return resolver.count() == 2
+	}, time.Second, time.Millisecond*10)
+	assert.Eventually(t, func() bool {
+		return e.GET("/api/main").Expect().Body().Raw() == `{"data":{"me":{"name":"JANNIK"}}}`
+	}, 2*time.Second, time.Millisecond*10)
+}
+
+func TestQueryHandler_RevalidateCustomResolve(t *testing.T) {
+	var calls int32
+	hooksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := atomic.AddInt32(&calls, 1)
+		_, _ = fmt.Fprintf(w, `{"response":{"data":{"me":{"name":"custom %d"}}}}`, call)
+	}))
+	defer hooksServer.Close()
+
+	resolver := &syncResolver{response: []byte(`{"data":{"me":{"name":"Jens"}}}`)}
+	handler := newCachingQueryHandler(t, resolver, cacheConfig{
+		enable:               true,
+		maxAge:               0,
+		staleWhileRevalidate: 60,
+	})
+	handler.hooksClient = hooks.NewClient(hooksServer.URL, abstractlogger.NoopLogger)
+	handler.hooksConfig.customResolve = true
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	res := e.GET("/api/main").Expect()
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"MISS"})
+	res.Body().Equal(`{"data":{"me":{"name":"custom 1"}}}`)
+
+	time.Sleep(time.Millisecond * 1100)
+
+	res = e.GET("/api/main").Expect()
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"STALE"})
+	res.Body().Equal(`{"data":{"me":{"name":"custom 1"}}}`)
+
+	// the background revalidation caches the response of the customResolve hook, not the one of the origin
+	assert.Eventually(t, func() bool {
+		return e.GET("/api/main").Expect().Body().Raw() == `{"data":{"me":{"name":"custom 2"}}}`
+	}, 2*time.Second, time.Millisecond*10)
+	assert.Equal(t, 0, resolver.count())
+}
+
+func TestQueryHandler_StaleIfError(t *testing.T) {
+	resolver := &syncResolver{response: []byte(`{"data":{"me":{"name":"Jens"}}}`)}
+	handler := newCachingQueryHandler(t, resolver, cacheConfig{
+		enable:       true,
+		maxAge:       0,
+		staleIfError: 60,
+	})
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	res := e.GET("/api/main").Expect()
+	res.Status(http.StatusOK)
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"MISS"})
+	res.Header("Cache-Control").Equal("private, max-age=0, stale-while-revalidate=0, stale-if-error=60")
+
+	time.Sleep(time.Millisecond * 1100)
+
+	resolver.set([]byte(`{"errors":[{"message":"origin unavailable"}],"data":null}`), nil)
+	res = e.GET("/api/main").Expect()
+	res.Status(http.StatusOK)
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"STALE-IF-ERROR"})
+	res.Body().Equal(`{"data":{"me":{"name":"Jens"}}}`)
+
+	resolver.set(nil, errors.New("connection refused"))
+	res = e.GET("/api/main").Expect()
+	res.Status(http.StatusOK)
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"STALE-IF-ERROR"})
+	res.Body().Equal(`{"data":{"me":{"name":"Jens"}}}`)
+
+	resolver.set([]byte(`{"data":{"me":{"name":"Jannik"}}}`), nil)
+	res = e.GET("/api/main").Expect()
+	res.Status(http.StatusOK)
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"MISS"})
+	res.Body().Equal(`{"data":{"me":{"name":"Jannik"}}}`)
+}
+
+func TestRedisCacheOptions(t *testing.T) {
+	static := func(value string) *wgpb.ConfigurationVariable {
+		return &wgpb.ConfigurationVariable{
+			Kind:                  wgpb.ConfigurationVariableKind_STATIC_CONFIGURATION_VARIABLE,
+			StaticVariableContent: value,
+		}
+	}
+
+	t.Run("standalone", func(t *testing.T) {
+		options, err := RedisCacheOptions(&wgpb.RedisCacheConfig{
+			RedisUrl: static("rediss://cache:6380/2"),
+			Db:       3,
+			Password: static("secret"),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "rediss://cache:6380/2", options.URL)
+		assert.Equal(t, 3, options.DB)
+		assert.Equal(t, "secret", options.Password)
+		assert.Nil(t, options.TLSConfig)
+	})
+	t.Run("standalone from env var", func(t *testing.T) {
+		t.Setenv("WG_TEST_REDIS_URL", "redis://localhost:6379")
+		options, err := RedisCacheOptions(&wgpb.RedisCacheConfig{
+			RedisUrlEnvVar: "WG_TEST_REDIS_URL",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "redis://localhost:6379", options.URL)
+	})
+	t.Run("standalone without url", func(t *testing.T) {
+		_, err := RedisCacheOptions(&wgpb.RedisCacheConfig{})
+		assert.Error(t, err)
+	})
+	t.Run("sentinel", func(t *testing.T) {
+		options, err := RedisCacheOptions(&wgpb.RedisCacheConfig{
+			Topology:           wgpb.RedisTopology_SENTINEL_REDIS_TOPOLOGY,
+			Addresses:          []*wgpb.ConfigurationVariable{static("sentinel-1:26379"), static("sentinel-2:26379")},
+			SentinelMasterName: "mymaster",
+			SentinelPassword:   static("sentinel-secret"),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"sentinel-1:26379", "sentinel-2:26379"}, options.Addrs)
+		assert.Equal(t, "mymaster", options.MasterName)
+		assert.Equal(t, "sentinel-secret", options.SentinelPassword)
+		assert.False(t, options.Cluster)
+	})
+	t.Run("sentinel without master name", func(t *testing.T) {
+		_, err := RedisCacheOptions(&wgpb.RedisCacheConfig{
+			Topology:  wgpb.RedisTopology_SENTINEL_REDIS_TOPOLOGY,
+			Addresses: []*wgpb.ConfigurationVariable{static("sentinel-1:26379")},
+		})
+		assert.Error(t, err)
+	})
+	t.Run("cluster with tls", func(t *testing.T) {
+		options, err := RedisCacheOptions(&wgpb.RedisCacheConfig{
+			Topology:  wgpb.RedisTopology_CLUSTER_REDIS_TOPOLOGY,
+			Addresses: []*wgpb.ConfigurationVariable{static("node-1:6379"), static("node-2:6379")},
+			Tls: &wgpb.RedisTLSConfig{
+				Enabled:    true,
+				ServerName: static("cache.internal"),
+			},
+			LocalCacheSize:       1000,
+			LocalCacheTtlSeconds: 5,
+		})
+		assert.NoError(t, err)
+		assert.True(t, options.Cluster)
+		assert.Equal(t, []string{"node-1:6379", "node-2:6379"}, options.Addrs)
+		assert.NotNil(t, options.TLSConfig)
+		assert.Equal(t, "cache.internal", options.TLSConfig.ServerName)
+		assert.Equal(t, 1000, options.LocalCacheSize)
+		assert.Equal(t, 5*time.Second, options.LocalCacheTTL)
+	})
+	t.Run("invalid ca cert", func(t *testing.T) {
+		_, err := RedisCacheOptions(&wgpb.RedisCacheConfig{
+			RedisUrl: static("redis://localhost:6379"),
+			Tls: &wgpb.RedisTLSConfig{
+				Enabled: true,
+				CaCert:  static("not a certificate"),
+			},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestQueryHandler_CompressedCache(t *testing.T) {
+	response := `{"data":{"users":[` + strings.Repeat(`{"name":"Jens"},`, 100) + `{"name":"Jannik"}]}}`
+	resolver := &syncResolver{response: []byte(response)}
+	handler := newCachingQueryHandler(t, resolver, cacheConfig{
+		enable: true,
+		maxAge: 60,
+	})
+	cache, err := apicache.NewInMemoryWithOptions(apicache.InMemoryOptions{
+		MaxSize:     1e6,
+		Compression: apicache.EncodingBrotli,
+	})
+	assert.NoError(t, err)
+	handler.cache = cache
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  srv.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	res := e.GET("/api/main").WithHeader("Accept-Encoding", "br").Expect()
+	res.Status(http.StatusOK)
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"MISS"})
+	res.Body().Equal(response)
+	time.Sleep(time.Millisecond)
+
+	res = e.GET("/api/main").WithHeader("Accept-Encoding", "gzip, br").Expect()
+	res.Status(http.StatusOK)
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"HIT"})
+	res.Header("Content-Encoding").Equal("br")
+	res.Header("Vary").Equal("Accept-Encoding")
+	decoded, err := io.ReadAll(brotli.NewReader(strings.NewReader(res.Body().Raw())))
+	assert.NoError(t, err)
+	assert.Equal(t, response, string(decoded))
+	compressedETag := res.Header("ETag").Raw()
+
+	res = e.GET("/api/main").WithHeader("Accept-Encoding", "gzip, br;q=0").Expect()
+	res.Status(http.StatusOK)
+	res.Headers().ValueEqual(WG_CACHE_HEADER, []string{"HIT"})
+	res.Headers().NotContainsKey("Content-Encoding")
+	res.Body().Equal(response)
+	assert.NotEqual(t, compressedETag, res.Header("ETag").Raw())
+
+	assert.Equal(t, 1, resolver.count())
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	for header, expected := range map[string]bool{
+		"":                 false,
+		"br":               true,
+		"gzip, deflate":    false,
+		"gzip, BR;q=0.5":   true,
+		"br;q=0":           false,
+		"br; q=0.0":        false,
+		"*":                true,
+		"*;q=0":            false,
+		"*, br;q=0":        false,
+		"identity;q=1, *":  true,
+		"gzip;q=1.0, br ;": true,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", header)
+		assert.Equal(t, expected, acceptsEncoding(r, apicache.EncodingBrotli), header)
+	}
+}
+
+func TestApiTransport_TraceHeaders(t *testing.T) {
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	var traceParent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("Traceparent")
+	}))
+	defer srv.Close()
+
+	transport := NewApiTransport(http.DefaultTransport, &Api{}, nil, nil, false, false)
+	req := httptest.NewRequest(http.MethodGet, srv.URL, nil)
+	req.RequestURI = ""
+	res, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+
+	// the trace context is propagated upstream without modifying the request of the caller
+	assert.NotEmpty(t, traceParent)
+	assert.Empty(t, req.Header.Get("Traceparent"))
+}
@@ -14,13 +14,18 @@ import (
 	"github.com/buger/jsonparser"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/websocket"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/wundergraph/graphql-go-tools/pkg/pool"
 
 	"github.com/wundergraph/wundergraph/pkg/authentication"
+	"github.com/wundergraph/wundergraph/pkg/fixtures"
 	"github.com/wundergraph/wundergraph/pkg/hooks"
 	"github.com/wundergraph/wundergraph/pkg/loadvariable"
+	"github.com/wundergraph/wundergraph/pkg/metrics"
 	pool2 "github.com/wundergraph/wundergraph/pkg/pool"
+	"github.com/wundergraph/wundergraph/pkg/tracing"
 	"github.com/wundergraph/wundergraph/pkg/wgpb"
 )
 
@@ -32,11 +37,12 @@ type ApiTransportFactory interface {
 type apiTransportFactory struct {
 	api             *Api
 	hooksClient     *hooks.Client
+	metrics         *metrics.Metrics
 	enableDebugMode bool
 }
 
 func (f *apiTransportFactory) RoundTripper(tripper http.RoundTripper, enableStreamingMode bool) http.RoundTripper {
-	return NewApiTransport(tripper, f.api, f.hooksClient, f.enableDebugMode, enableStreamingMode)
+	return NewApiTransport(tripper, f.api, f.hooksClient, f.metrics, f.enableDebugMode, enableStreamingMode)
 }
 
 func (f *apiTransportFactory) DefaultTransportTimeout() time.Duration {
@@ -51,18 +57,28 @@ type ApiTransport struct {
 	onRequestHook              map[string]struct{}
 	onResponseHook             map[string]struct{}
 	hooksClient                *hooks.Client
+	metrics                    *metrics.Metrics
 	enableStreamingMode        bool
 }
 
-func NewApiTransportFactory(api *Api, hooksClient *hooks.Client, enableDebugMode bool) ApiTransportFactory {
+func NewApiTransportFactory(api *Api, hooksClient *hooks.Client, metrics *metrics.Metrics, enableDebugMode bool) ApiTransportFactory {
 	return &apiTransportFactory{
 		api:             api,
 		hooksClient:     hooksClient,
+		metrics:         metrics,
 		enableDebugMode: enableDebugMode,
 	}
 }
 
-func NewApiTransport(tripper http.RoundTripper, api *Api, hooksClient *hooks.Client, enableDebugMode bool, enableStreamingMode bool) http.RoundTripper {
+func NewApiTransport(tripper http.RoundTripper, api *Api, hooksClient *hooks.Client, metrics *metrics.Metrics, enableDebugMode bool, enableStreamingMode bool) http.RoundTripper {
+	if api.Options != nil {
+		if enableStreamingMode {
+			tripper = fixtures.NewStreamingTransport(api.Options.Fixtures, tripper)
+		} else {
+			tripper = fixtures.NewTransport(api.Options.Fixtures, tripper)
+		}
+	}
+
 	transport := &ApiTransport{
 		roundTripper:               tripper,
 		debugMode:                  enableDebugMode,
@@ -71,6 +87,7 @@ func NewApiTransport(tripper http.RoundTripper, api *Api, hooksClient *hooks.Cli
 		onResponseHook:             map[string]struct{}{},
 		onRequestHook:              map[string]struct{}{},
 		hooksClient:                hooksClient,
+		metrics:                    metrics,
 		enableStreamingMode:        enableStreamingMode,
 	}
 
@@ -115,7 +132,32 @@ type Claims struct {
 	jwt.StandardClaims
 }
 
-func (t *ApiTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+func (t *ApiTransport) RoundTrip(request *http.Request) (res *http.Response, err error) {
+
+	ctx, span := tracing.Tracer().Start(request.Context(), fmt.Sprintf("%s %s", request.Method, request.URL.Host),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(request.Method),
+			semconv.HTTPTargetKey.String(request.URL.Path),
+			semconv.NetPeerNameKey.String(request.URL.Host),
+		),
+	)
+	defer func() {
+		if res != nil {
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(res.StatusCode))
+		}
+		tracing.EndSpan(span, err)
+	}()
+	if metaData := getOperationMetaData(request); metaData != nil {
+		span.SetAttributes(
+			tracing.OperationNameKey.String(metaData.OperationName),
+			tracing.OperationTypeKey.String(metaData.GetOperationTypeString()),
+		)
+	}
+
+	// a RoundTripper must not modify the request, the trace headers are injected into a copy
+	request = request.Clone(ctx)
+	tracing.InjectHeaders(ctx, request.Header)
 
 	if request.Header.Get("X-WG-Internal-GraphQL-API") == "true" {
 		return t.internalGraphQLRoundTrip(request)
@@ -123,7 +165,7 @@ func (t *ApiTransport) RoundTrip(request *http.Request) (*http.Response, error)
 
 	upstreamAuth, ok := t.upstreamAuthConfigurations[request.Host]
 	if ok {
-		err := t.handleUpstreamAuthentication(request, upstreamAuth)
+		err = t.handleUpstreamAuthentication(request, upstreamAuth)
 		if err != nil {
 			return nil, err
 		}
@@ -162,10 +204,13 @@ func (t *ApiTransport) roundTrip(request *http.Request) (res *http.Response, err
 
 	start := time.Now()
 	res, err = t.roundTripper.RoundTrip(request)
-	duration := time.Since(start).Milliseconds()
+	elapsed := time.Since(start)
+	duration := elapsed.Milliseconds()
 	if err != nil {
+		t.metrics.ObserveDataSource(request.URL.Host, 0, err, elapsed)
 		return nil, err
 	}
+	t.metrics.ObserveDataSource(request.URL.Host, res.StatusCode, nil, elapsed)
 
 	// in case of http Upgrade requests, we must not dump the response
 	// otherwise, the upgrade will fail
@@ -256,7 +301,14 @@ func (t *ApiTransport) internalGraphQLRoundTrip(request *http.Request) (res *htt
 
 	req.Header = request.Header.Clone()
 
-	return t.roundTripper.RoundTrip(req)
+	start := time.Now()
+	res, err = t.roundTripper.RoundTrip(req)
+	if err != nil {
+		t.metrics.ObserveDataSource(req.URL.Host, 0, err, time.Since(start))
+		return nil, err
+	}
+	t.metrics.ObserveDataSource(req.URL.Host, res.StatusCode, nil, time.Since(start))
+	return res, nil
 }
 
 func (t *ApiTransport) handleOnRequestHook(r *http.Request, metaData *OperationMetaData) (*http.Request, error) {
@@ -0,0 +1,197 @@
+package apihandler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/buger/jsonparser"
+	"github.com/gorilla/mux"
+	"github.com/jensneuse/abstractlogger"
+
+	"github.com/wundergraph/wundergraph/pkg/apicache"
+	"github.com/wundergraph/wundergraph/pkg/wgpb"
+)
+
+// Cached query responses are tagged with the name of their operation, the tags of the
+// operation cache config and, for each set of variables mutations match on, the values
+// of these variables. All tags are scoped to the config hash, just like the cache keys.
+
+func operationCacheTag(configHash, operationName string) string {
+	return configHash + ":operation:" + operationName
+}
+
+func customCacheTag(configHash, tag string) string {
+	return configHash + ":tag:" + tag
+}
+
+// variablesCacheTag identifies the responses of an operation for the given variable values
+func variablesCacheTag(configHash, operationName string, values url.Values) string {
+	return operationCacheTag(configHash, operationName) + ":" + values.Encode()
+}
+
+// variableValue looks up a variable, nested fields are addressed with dots, e.g. "input.id"
+func variableValue(variables []byte, name string) (string, bool) {
+	value, _, _, err := jsonparser.Get(variables, strings.Split(name, ".")...)
+	if err != nil {
+		return "", false
+	}
+	return string(value), true
+}
+
+// cacheInvalidationVariables collects the variable names mutations match on when invalidating
+// the given query operation. Each set is sorted and only returned once.
+func cacheInvalidationVariables(operations []*wgpb.Operation, operationName string) [][]string {
+	var (
+		sets [][]string
+		seen = map[string]struct{}{}
+	)
+	for _, operation := range operations {
+		for _, invalidation := range operation.CacheInvalidations {
+			if invalidation.OperationName != operationName || len(invalidation.Variables) == 0 {
+				continue
+			}
+			names := make([]string, 0, len(invalidation.Variables))
+			for name := range invalidation.Variables {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			key := strings.Join(names, ",")
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			sets = append(sets, names)
+		}
+	}
+	return sets
+}
+
+// cacheTags returns the tags of a cached response of the operation for the given variables
+func (h *QueryHandler) cacheTags(variables []byte) []string {
+	configHash := string(h.configHash)
+	tags := []string{operationCacheTag(configHash, h.operation.Name)}
+	for _, tag := range h.cacheConfig.tags {
+		tags = append(tags, customCacheTag(configHash, tag))
+	}
+	for _, names := range h.cacheConfig.invalidationVariables {
+		// if a variable is missing, no mutation matching on this set can refer to the response
+		if values, ok := variableValues(variables, names); ok {
+			tags = append(tags, variablesCacheTag(configHash, h.operation.Name, values))
+		}
+	}
+	return tags
+}
+
+func variableValues(variables []byte, names []string) (url.Values, bool) {
+	values := url.Values{}
+	for _, name := range names {
+		value, ok := variableValue(variables, name)
+		if !ok {
+			return nil, false
+		}
+		values.Set(name, value)
+	}
+	return values, true
+}
+
+// invalidateCache evicts the cached responses of the query operations the mutation invalidates.
+// If a variable the invalidation matches on is missing, all responses of the operation are evicted.
+func (h *MutationHandler) invalidateCache(ctx context.Context, variables []byte) {
+	configHash := string(h.configHash)
+	for _, invalidation := range h.operation.CacheInvalidations {
+		tag := operationCacheTag(configHash, invalidation.OperationName)
+		if len(invalidation.Variables) != 0 {
+			values := url.Values{}
+			for queryVariable, mutationVariable := range invalidation.Variables {
+				value, ok := variableValue(variables, mutationVariable)
+				if !ok {
+					values = nil
+					break
+				}
+				values.Set(queryVariable, value)
+			}
+			if values != nil {
+				tag = variablesCacheTag(configHash, invalidation.OperationName, values)
+			}
+		}
+		h.log.Debug("invalidating cache",
+			abstractlogger.String("mutation", h.operation.Name),
+			abstractlogger.String("tag", tag),
+		)
+		h.cache.DeleteByTag(ctx, tag)
+	}
+}
+
+type cachePurgeRequest struct {
+	OperationName string `json:"operationName"`
+	Tag           string `json:"tag"`
+}
+
+// CachePurgeHandler evicts cached query responses by operation name or tag.
+// Requests must present the configured token as bearer token.
+type CachePurgeHandler struct {
+	cache      apicache.Cache
+	configHash string
+	token      string
+	log        abstractlogger.Logger
+}
+
+// MountCachePurgeHandler registers the cache purge endpoint on the internal router.
+// The endpoint is only available if a purge token is configured.
+func (r *Builder) MountCachePurgeHandler(router *mux.Router, api *Api) {
+	if r.cache == nil || api.Options == nil || api.Options.CachePurgeToken == "" {
+		return
+	}
+	purgePath := fmt.Sprintf("/%s/cache/purge", api.PathPrefix)
+	router.Methods(http.MethodPost).Path(purgePath).Handler(&CachePurgeHandler{
+		cache:      r.cache,
+		configHash: api.ApiConfigHash,
+		token:      api.Options.CachePurgeToken,
+		log:        r.log,
+	})
+	r.log.Debug("registered CachePurgeHandler",
+		abstractlogger.String("method", http.MethodPost),
+		abstractlogger.String("path", purgePath),
+	)
+}
+
+func (h *CachePurgeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request cachePurgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var tag string
+	switch {
+	case request.OperationName != "" && request.Tag != "":
+		http.Error(w, "either operationName or tag must be set, not both", http.StatusBadRequest)
+		return
+	case request.OperationName != "":
+		tag = operationCacheTag(h.configHash, request.OperationName)
+	case request.Tag != "":
+		tag = customCacheTag(h.configHash, request.Tag)
+	default:
+		http.Error(w, "operationName or tag is required", http.StatusBadRequest)
+		return
+	}
+
+	h.cache.DeleteByTag(r.Context(), tag)
+	h.log.Debug("purged cache",
+		abstractlogger.String("operationName", request.OperationName),
+		abstractlogger.String("tag", request.Tag),
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,98 @@
+package apihandler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/buger/jsonparser"
+)
+
+const (
+	persistedQueryVersion        = 1
+	persistedQueryCacheKeyPrefix = "persisted_query:"
+)
+
+// persistedQueryError is answered to the client in the format Apollo clients expect,
+// e.g. a PersistedQueryNotFound error makes them retry the request including the query
+type persistedQueryError struct {
+	statusCode int
+	message    string
+	code       string
+}
+
+func (e *persistedQueryError) Error() string {
+	return e.message
+}
+
+var (
+	errPersistedQueryNotFound = &persistedQueryError{
+		statusCode: http.StatusOK,
+		message:    "PersistedQueryNotFound",
+		code:       "PERSISTED_QUERY_NOT_FOUND",
+	}
+	errPersistedQueryVersion = &persistedQueryError{
+		statusCode: http.StatusBadRequest,
+		message:    "Unsupported persisted query version",
+		code:       "BAD_USER_INPUT",
+	}
+	errPersistedQueryHashMissing = &persistedQueryError{
+		statusCode: http.StatusBadRequest,
+		message:    "persistedQuery.sha256Hash is missing",
+		code:       "BAD_USER_INPUT",
+	}
+	errPersistedQueryHashMismatch = &persistedQueryError{
+		statusCode: http.StatusBadRequest,
+		message:    "provided sha does not match query",
+		code:       "BAD_USER_INPUT",
+	}
+)
+
+// loadPersistedQuery implements automatic persisted queries (APQ).
+// If the request extensions contain a persistedQuery, a request without a query
+// is answered from the cache, while a request with a query stores it in the cache
+// under its sha256 hash. Requests without a persistedQuery are returned unchanged.
+func (h *GraphQLHandler) loadPersistedQuery(ctx context.Context, requestExtensions []byte, requestQuery string) (string, error) {
+	persistedQuery, dataType, _, err := jsonparser.Get(requestExtensions, "persistedQuery")
+	if err != nil || dataType != jsonparser.Object {
+		return requestQuery, nil
+	}
+
+	version, err := jsonparser.GetInt(persistedQuery, "version")
+	if err != nil || version != persistedQueryVersion {
+		return "", errPersistedQueryVersion
+	}
+
+	hash, err := jsonparser.GetString(persistedQuery, "sha256Hash")
+	if err != nil || hash == "" {
+		return "", errPersistedQueryHashMissing
+	}
+	hash = strings.ToLower(hash)
+	cacheKey := persistedQueryCacheKeyPrefix + hash
+
+	if requestQuery == "" {
+		item, hit := h.cache.Get(ctx, cacheKey)
+		if !hit {
+			return "", errPersistedQueryNotFound
+		}
+		query, err := item.Decoded()
+		if err != nil {
+			return "", errPersistedQueryNotFound
+		}
+		return string(query), nil
+	}
+
+	sum := sha256.Sum256([]byte(requestQuery))
+	if hex.EncodeToString(sum[:]) != hash {
+		return "", errPersistedQueryHashMismatch
+	}
+
+	h.cache.Set(cacheKey, []byte(requestQuery))
+	return requestQuery, nil
+}
+
+func writePersistedQueryError(w http.ResponseWriter, err *persistedQueryError) {
+	writeGraphQLError(w, err.statusCode, err.message, err.code, nil)
+}
@@ -0,0 +1,282 @@
+package apihandler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/cespare/xxhash"
+	"github.com/jensneuse/abstractlogger"
+
+	"github.com/wundergraph/graphql-go-tools/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/pkg/lexer/literal"
+
+	"github.com/wundergraph/wundergraph/pkg/apicache"
+	"github.com/wundergraph/wundergraph/pkg/hooks"
+	"github.com/wundergraph/wundergraph/pkg/pool"
+)
+
+func (c *cacheConfig) cacheControl() string {
+	visibility := "private"
+	if c.public {
+		visibility = "public"
+	}
+	if c.staleIfError > 0 {
+		return fmt.Sprintf("%s, max-age=%d, stale-while-revalidate=%d, stale-if-error=%d", visibility, c.maxAge, c.staleWhileRevalidate, c.staleIfError)
+	}
+	return fmt.Sprintf("%s, max-age=%d, stale-while-revalidate=%d", visibility, c.maxAge, c.staleWhileRevalidate)
+}
+
+// ttl keeps responses in the cache as long as they might be served, fresh or stale
+func (c *cacheConfig) ttl() time.Duration {
+	return time.Second * time.Duration(c.maxAge+c.staleWhileRevalidate+c.staleIfError)
+}
+
+// writeCacheItem answers the request from the cache, status is reported through the X-Wg-Cache header.
+// Compressed items are sent as they are to clients accepting their encoding, and decompressed for all others.
+func (h *QueryHandler) writeCacheItem(w http.ResponseWriter, r *http.Request, item apicache.CacheItem, status string) {
+	data := item.Data
+	if item.Encoding != "" {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsEncoding(r, item.Encoding) {
+			w.Header().Set("Content-Encoding", item.Encoding)
+		} else {
+			decoded, err := item.Decoded()
+			if done := handleOperationErr(h.log, err, w, "decompressing cache item failed", h.operation); done {
+				return
+			}
+			data = decoded
+		}
+	}
+
+	w.Header().Set(WG_CACHE_HEADER, status)
+
+	// the ETag differs between encodings, as they are different representations of the response
+	hash := xxhash.New()
+	_, _ = hash.Write(h.configHash)
+	_, _ = hash.Write(data)
+	ETag := fmt.Sprintf("W/\"%d\"", hash.Sum64())
+
+	w.Header()["ETag"] = []string{ETag}
+	w.Header().Set("Cache-Control", h.cacheConfig.cacheControl())
+	w.Header().Set("Age", fmt.Sprintf("%d", item.Age()))
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == ETag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header of the request allows the content coding.
+// An explicit entry for the coding takes precedence over the "*" wildcard, q=0 refuses a coding.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	acceptedByWildcard := false
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(header, ",") {
+			coding, params, _ := strings.Cut(part, ";")
+			coding = strings.TrimSpace(coding)
+			switch {
+			case strings.EqualFold(coding, encoding):
+				return qualityValue(params) > 0
+			case coding == "*":
+				acceptedByWildcard = qualityValue(params) > 0
+			}
+		}
+	}
+	return acceptedByWildcard
+}
+
+func qualityValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(name, "q") {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return 0
+			}
+			return q
+		}
+	}
+	return 1
+}
+
+// revalidationTimeout bounds background revalidations, as no client waits for them to be canceled
+const revalidationTimeout = 30 * time.Second
+
+// revalidate refreshes a stale cache entry in the background.
+// Concurrent requests hitting the same stale entry share a single revalidation.
+// Failed revalidations, including failed post-resolve hooks, keep the stale entry, so it can still be served.
+func (h *QueryHandler) revalidate(r *http.Request, cacheKey string, variables []byte) {
+	// the revalidation outlives the request, so it must not be canceled together with it
+	revalidationRequest := r.Clone(detachedContext{r.Context()})
+	revalidationVariables := make([]byte, len(variables))
+	copy(revalidationVariables, variables)
+
+	h.revalidations.DoChan(cacheKey, func() (interface{}, error) {
+		timeoutCtx, cancel := context.WithTimeout(revalidationRequest.Context(), revalidationTimeout)
+		defer cancel()
+
+		err := h.refreshCacheEntry(revalidationRequest.WithContext(timeoutCtx), cacheKey, revalidationVariables)
+		if err != nil {
+			h.log.Debug("revalidating stale cache entry failed",
+				abstractlogger.String("operation", h.operation.Name),
+				abstractlogger.Error(err),
+			)
+		}
+		return nil, err
+	})
+}
+
+// refreshCacheEntry resolves the operation and caches the response.
+// It shares resolveQuery with ServeHTTP, so the refreshed entry equals the one cached by ServeHTTP.
+func (h *QueryHandler) refreshCacheEntry(r *http.Request, cacheKey string, variables []byte) error {
+	buf := pool.GetBytesBuffer()
+	defer pool.PutBytesBuffer(buf)
+	hookBuf := pool.GetBytesBuffer()
+	defer pool.PutBytesBuffer(hookBuf)
+
+	ctx := pool.GetCtx(r, r, pool.Config{
+		RenameTypeNames: h.renameTypeNames,
+	})
+	defer pool.PutCtx(ctx)
+	ctx.Variables = variables
+
+	// responses with errors would replace an entry which can still be served
+	transformed, err := h.resolveQuery(ctx, r, buf, hookBuf, true)
+	if err != nil {
+		return err
+	}
+
+	cacheData := make([]byte, len(transformed))
+	copy(cacheData, transformed)
+	h.cache.SetWithTags(cacheKey, cacheData, h.cacheConfig.ttl(), h.cacheTags(ctx.Variables))
+	return nil
+}
+
+// resolveStepError is returned by resolveQuery, step describes the step which failed
+type resolveStepError struct {
+	step string
+	// origin reports whether the origin failed, a stale response may be served instead
+	origin bool
+	err    error
+}
+
+func (e *resolveStepError) Error() string {
+	return e.step + ": " + e.err.Error()
+}
+
+func (e *resolveStepError) Unwrap() error {
+	return e.err
+}
+
+// resolveQuery resolves the operation with the customResolve hook or the origin, surrounded by the pre- and post-resolve hooks.
+// ServeHTTP and the revalidation of stale cache entries share it, so both cache the same response.
+// If rejectErrors is set, responses of the origin with GraphQL errors fail like the origin.
+func (h *QueryHandler) resolveQuery(ctx *resolve.Context, r *http.Request, buf, hookBuf *bytes.Buffer, rejectErrors bool) ([]byte, error) {
+	if h.hooksConfig.preResolve {
+		hookData := hookBaseData(r, hookBuf.Bytes(), ctx.Variables, nil)
+		out, err := h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.PreResolve, hookData)
+		if err = preResolveHookOut(ctx, "preResolve", out, err); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.hooksConfig.mutatingPreResolve {
+		hookData := hookBaseData(r, hookBuf.Bytes(), ctx.Variables, nil)
+		out, err := h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.MutatingPreResolve, hookData)
+		if err = preResolveHookOut(ctx, "mutatingPreResolve", out, err); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.hooksConfig.customResolve {
+		hookData := hookBaseData(r, hookBuf.Bytes(), ctx.Variables, nil)
+		out, err := h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.CustomResolve, hookData)
+		if err = preResolveHookOut(ctx, "customResolve", out, err); err != nil {
+			return nil, err
+		}
+		// the customResolve hook can indicate to "skip" by responding with "null"
+		if !bytes.Equal(out.Response, literal.NULL) {
+			return out.Response, nil
+		}
+	}
+
+	err := h.resolver.ResolveGraphQLResponse(ctx, h.preparedPlan.Response, nil, buf)
+	if err != nil {
+		return nil, &resolveStepError{step: "ResolveGraphQLResponse failed", origin: true, err: err}
+	}
+	if rejectErrors && hasGraphQLErrors(buf.Bytes()) {
+		return nil, &resolveStepError{step: "ResolveGraphQLResponse failed", origin: true, err: errors.New("response has errors")}
+	}
+	transformed, err := h.postResolveTransformer.Transform(buf.Bytes())
+	if err != nil {
+		return nil, &resolveStepError{step: "postResolveTransformer failed", err: err}
+	}
+	if h.hooksConfig.postResolve {
+		hookData := hookBaseData(r, hookBuf.Bytes(), ctx.Variables, transformed)
+		_, err = h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.PostResolve, hookData)
+		if err != nil {
+			return nil, &resolveStepError{step: "postResolve hook failed", err: err}
+		}
+	}
+	if h.hooksConfig.mutatingPostResolve {
+		hookData := hookBaseData(r, hookBuf.Bytes(), ctx.Variables, transformed)
+		out, err := h.hooksClient.DoOperationRequest(ctx.Context, h.operation.Name, hooks.MutatingPostResolve, hookData)
+		if err != nil {
+			return nil, &resolveStepError{step: "mutatingPostResolve hook failed", err: err}
+		}
+		if out == nil {
+			return nil, &resolveStepError{step: "mutatingPostResolve hook failed", err: errors.New("hook response is nil")}
+		}
+		transformed = out.Response
+	}
+	return transformed, nil
+}
+
+// preResolveHookOut applies the headers set by a hook called before resolving
+func preResolveHookOut(ctx *resolve.Context, hook string, out *hooks.MiddlewareHookResponse, err error) error {
+	if err != nil {
+		return &resolveStepError{step: hook + " hook failed", err: err}
+	}
+	if out == nil {
+		return &resolveStepError{step: hook + " hook failed", err: errors.New("hook response is nil")}
+	}
+	updateContextHeaders(ctx, out.SetClientRequestHeaders)
+	return nil
+}
+
+// hasGraphQLErrors reports whether the origin failed to resolve the response
+func hasGraphQLErrors(response []byte) bool {
+	hasErrors := false
+	_, _ = jsonparser.ArrayEach(response, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		hasErrors = true
+	}, "errors")
+	return hasErrors
+}
+
+// detachedContext keeps the values of its parent, e.g. the user, but is never canceled
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,148 @@
+package apihandler
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jensneuse/abstractlogger"
+
+	"github.com/wundergraph/wundergraph/pkg/authentication"
+	"github.com/wundergraph/wundergraph/pkg/wgpb"
+)
+
+// rateLimitOperation enforces the rate limit configured for the operation, if any.
+// Every response carries the RateLimit-* headers, rejected requests additionally get a Retry-After header.
+// Requests to the GraphQL endpoint don't execute a configured operation, so they aren't rate limited.
+func (r *Builder) rateLimitOperation(operation *wgpb.Operation, handler http.Handler) http.Handler {
+	config := operation.RateLimitConfig
+	if r.rateLimiter == nil || config == nil || !config.Enable || config.Requests <= 0 || config.PerSecond <= 0 {
+		return handler
+	}
+
+	var trustedProxies []*net.IPNet
+	if r.api.Options != nil {
+		trustedProxies = r.api.Options.RateLimit.TrustedProxies
+	}
+	limiter := r.rateLimiter
+	log := r.log
+	limit := int(config.Requests)
+	window := time.Duration(config.PerSecond) * time.Second
+	keyPrefix := r.api.PathPrefix + ":" + operation.Name + ":"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := keyPrefix + rateLimitKey(config, req, trustedProxies)
+		result, err := limiter.Allow(req.Context(), key, limit, window)
+		if err != nil {
+			// don't take the API down with the rate limit store
+			log.Error("rate limit",
+				abstractlogger.String("operation", operation.Name),
+				abstractlogger.Error(err),
+			)
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		reset := strconv.Itoa(int(math.Ceil(result.Reset.Seconds())))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", reset)
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", reset)
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// rateLimitKey identifies the client, falling back to its IP if the configured key is missing
+func rateLimitKey(config *wgpb.OperationRateLimitConfig, r *http.Request, trustedProxies []*net.IPNet) string {
+	switch config.Key {
+	case wgpb.RateLimitKeyKind_USER_RATE_LIMIT_KEY:
+		if user := authentication.UserFromContext(r.Context()); user != nil && user.UserID != "" {
+			return "user:" + user.UserID
+		}
+	case wgpb.RateLimitKeyKind_HEADER_RATE_LIMIT_KEY:
+		if value := r.Header.Get(config.HeaderName); value != "" {
+			return "header:" + value
+		}
+	}
+	return "ip:" + clientIP(r, trustedProxies)
+}
+
+// clientIP returns the IP of the client. Requests from trusted proxies are attributed to the IP
+// the proxies forwarded them for: the forwarded IPs are walked starting with the one added by the
+// closest proxy and the first IP which isn't a trusted proxy is the client's.
+// Headers of requests from other peers are ignored, as they can be spoofed by the client.
+func clientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !isTrustedProxy(ip, trustedProxies) {
+		return ip
+	}
+	forwarded := forwardedFor(r.Header)
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip = forwarded[i]
+		if !isTrustedProxy(ip, trustedProxies) {
+			return ip
+		}
+	}
+	return ip
+}
+
+func isTrustedProxy(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the IPs the request was forwarded for, from the client to the closest proxy.
+// The Forwarded header takes precedence over X-Forwarded-For.
+func forwardedFor(header http.Header) []string {
+	var ips []string
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, node, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+				ips = append(ips, forwardedNodeIP(strings.Trim(node, `"`)))
+			}
+		}
+	}
+	if len(ips) != 0 {
+		return ips
+	}
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(value, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// forwardedNodeIP strips the port and the brackets of IPv6 addresses from a node of the Forwarded header
+func forwardedNodeIP(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+}
@@ -149,7 +149,8 @@ func (u *User) RemoveInternalFields() {
 	u.RawIDToken = ""
 }
 
-func (u *User) Save(s *securecookie.SecureCookie, w http.ResponseWriter, r *http.Request, domain string, insecureCookies bool) error {
+// Save stores the user in cookies scoped to cookiePath, "/" if empty
+func (u *User) Save(s *securecookie.SecureCookie, w http.ResponseWriter, r *http.Request, domain string, insecureCookies bool, cookiePath string) error {
 
 	rawIdToken := u.RawIDToken
 
@@ -175,7 +176,7 @@ func (u *User) Save(s *securecookie.SecureCookie, w http.ResponseWriter, r *http
 	cookie := &http.Cookie{
 		Name:     "user",
 		Value:    encoded,
-		Path:     "/",
+		Path:     cookiePathOrRoot(cookiePath),
 		Domain:   removeSubdomain(sanitizeDomain(domain)),
 		MaxAge:   int((time.Hour * 24 * 30).Seconds()),
 		Secure:   !insecureCookies,
@@ -193,7 +194,7 @@ func (u *User) Save(s *securecookie.SecureCookie, w http.ResponseWriter, r *http
 	cookie = &http.Cookie{
 		Name:     "id",
 		Value:    encoded,
-		Path:     "/",
+		Path:     cookiePathOrRoot(cookiePath),
 		Domain:   removeSubdomain(sanitizeDomain(domain)),
 		MaxAge:   int((time.Hour * 24 * 30).Seconds()),
 		Secure:   !insecureCookies,
@@ -683,6 +684,7 @@ type CookieUserHandler struct {
 	Log               abstractlogger.Logger
 	Host              string
 	InsecureCookies   bool
+	CookiePath        string
 	Cookie            *securecookie.SecureCookie
 }
 
@@ -728,7 +730,7 @@ func (u *CookieUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		res.User.AccessToken = user.AccessToken
 		res.User.IdToken = user.IdToken
 		user = &res.User
-		err = user.Save(u.Cookie, w, r, u.Host, u.InsecureCookies)
+		err = user.Save(u.Cookie, w, r, u.Host, u.InsecureCookies, u.CookiePath)
 		if err != nil {
 			u.Log.Error("RevalidateAuthentication could not save cookie", abstractlogger.Error(err))
 			http.NotFound(w, r)
@@ -764,12 +766,13 @@ func (_ *CSRFTokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 type UserLogoutHandler struct {
 	InsecureCookies                  bool
+	CookiePath                       string
 	OpenIDConnectIssuersToLogoutURLs map[string]string
 	Hooks                            Hooks
 }
 
 func (u *UserLogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	resetUserCookies(w, r, !u.InsecureCookies)
+	resetUserCookies(w, r, !u.InsecureCookies, u.CookiePath)
 	user := UserFromContext(r.Context())
 	if user == nil {
 		return
@@ -810,13 +813,23 @@ func (u *UserLogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 type CSRFErrorHandler struct {
 	InsecureCookies bool
+	CookiePath      string
 }
 
 func (u *CSRFErrorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	resetUserCookies(w, r, !u.InsecureCookies)
+	resetUserCookies(w, r, !u.InsecureCookies, u.CookiePath)
 	http.Error(w, "forbidden", http.StatusForbidden)
 }
 
+// cookiePathOrRoot scopes cookies to the whole domain unless the API restricts them to its path,
+// e.g. when a node serves multiple APIs on the same domain
+func cookiePathOrRoot(cookiePath string) string {
+	if cookiePath == "" {
+		return "/"
+	}
+	return cookiePath
+}
+
 // sanitizeDomain cleans up the host so that it can work on localhost
 func sanitizeDomain(domain string) string {
 	if !strings.Contains(domain, ":") {
@@ -837,7 +850,9 @@ func removeSubdomain(domain string) string {
 type CSRFConfig struct {
 	Path            string
 	InsecureCookies bool
-	Secret          []byte
+	// CookiePath scopes the CSRF cookie, defaults to "/"
+	CookiePath string
+	Secret     []byte
 }
 
 func NewCSRFMw(config CSRFConfig) func(handler http.Handler) http.Handler {
@@ -846,7 +861,7 @@ func NewCSRFMw(config CSRFConfig) func(handler http.Handler) http.Handler {
 			if user := UserFromContext(r.Context()); user != nil && user.FromCookie {
 				domain := removeSubdomain(sanitizeDomain(r.Host))
 				csrfMiddleware := csrf.Protect(config.Secret,
-					csrf.Path("/"),
+					csrf.Path(cookiePathOrRoot(config.CookiePath)),
 					csrf.Domain(domain),
 					csrf.CookieName("csrf"),
 					csrf.RequestHeader("X-CSRF-Token"),
@@ -855,6 +870,7 @@ func NewCSRFMw(config CSRFConfig) func(handler http.Handler) http.Handler {
 					csrf.SameSite(csrf.SameSiteStrictMode),
 					csrf.ErrorHandler(&CSRFErrorHandler{
 						InsecureCookies: config.InsecureCookies,
+						CookiePath:      config.CookiePath,
 					}),
 				)
 				csrfMiddleware(unprotected).ServeHTTP(w, r)
@@ -876,12 +892,12 @@ func RequiresAuthentication(handler http.Handler) http.Handler {
 	})
 }
 
-func resetUserCookies(w http.ResponseWriter, r *http.Request, secure bool) {
+func resetUserCookies(w http.ResponseWriter, r *http.Request, secure bool, cookiePath string) {
 	for _, name := range []string{"user", "id"} {
 		userCookie := &http.Cookie{
 			Name:     name,
 			Value:    "",
-			Path:     "/",
+			Path:     cookiePathOrRoot(cookiePath),
 			Domain:   removeSubdomain(sanitizeDomain(r.Host)),
 			MaxAge:   -1,
 			HttpOnly: true,
@@ -32,7 +32,9 @@ type GithubConfig struct {
 	PathPrefix         string
 	InsecureCookies    bool
 	ForceRedirectHttps bool
-	Cookie             *securecookie.SecureCookie
+	// CookiePath scopes the user cookies, defaults to "/"
+	CookiePath string
+	Cookie     *securecookie.SecureCookie
 }
 
 type GithubUserInfo struct {
@@ -300,7 +302,7 @@ func (g *GithubCookieHandler) Register(authorizeRouter, callbackRouter *mux.Rout
 		hooks.handlePostAuthentication(r.Context(), user)
 		proceed, _, user := hooks.handleMutatingPostAuthentication(r.Context(), user)
 		if proceed {
-			err = user.Save(config.Cookie, w, r, r.Host, config.InsecureCookies)
+			err = user.Save(config.Cookie, w, r, r.Host, config.InsecureCookies, config.CookiePath)
 			if err != nil {
 				g.log.Error("GithubCookieHandler.user.Save",
 					abstractlogger.Error(err),
@@ -41,7 +41,9 @@ type OpenIDConnectConfig struct {
 	PathPrefix         string
 	InsecureCookies    bool
 	ForceRedirectHttps bool
-	Cookie             *securecookie.SecureCookie
+	// CookiePath scopes the user cookies, defaults to "/"
+	CookiePath string
+	Cookie     *securecookie.SecureCookie
 }
 
 type ClaimsInfo struct {
@@ -264,7 +266,7 @@ func (h *OpenIDConnectCookieHandler) Register(authorizeRouter, callbackRouter *m
 		hooks.handlePostAuthentication(r.Context(), user)
 		proceed, _, user := hooks.handleMutatingPostAuthentication(r.Context(), user)
 		if proceed {
-			err = user.Save(config.Cookie, w, r, r.Host, config.InsecureCookies)
+			err = user.Save(config.Cookie, w, r, r.Host, config.InsecureCookies, config.CookiePath)
 			if err != nil {
 				h.log.Error("OpenIDConnectCookieHandler.user.Save",
 					abstractlogger.Error(err),
@@ -0,0 +1,180 @@
+// Package autotls obtains and renews TLS certificates through ACME, e.g. from Let's Encrypt
+package autotls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jensneuse/abstractlogger"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// DefaultHTTPChallengeAddress is where HTTP-01 challenges are validated by ACME servers
+const DefaultHTTPChallengeAddress = ":80"
+
+type Options struct {
+	// Email is the contact of the ACME account
+	Email string
+	// DirectoryURL defaults to Let's Encrypt
+	DirectoryURL string
+	// DirectoryCAFile is trusted in addition to the system roots, e.g. the CA of a local Pebble
+	DirectoryCAFile string
+	// Hosts returns the hosts certificates are requested for, it's called on every new host so they can change over time
+	Hosts func() []string
+	// Cache stores the account key and the certificates
+	Cache autocert.Cache
+}
+
+// NewManager returns a manager requesting certificates for the allowed hosts on their first TLS handshake
+// and renewing them before they expire
+func NewManager(options Options) (*autocert.Manager, error) {
+	if options.Cache == nil {
+		return nil, errors.New("ACME requires a cache for its certificates")
+	}
+	if options.Hosts == nil {
+		return nil, errors.New("ACME requires the hosts to request certificates for")
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Email:      options.Email,
+		Cache:      options.Cache,
+		HostPolicy: HostPolicy(options.Hosts),
+	}
+	if options.DirectoryURL != "" || options.DirectoryCAFile != "" {
+		client := &acme.Client{
+			DirectoryURL: options.DirectoryURL,
+		}
+		if options.DirectoryCAFile != "" {
+			data, err := ioutil.ReadFile(options.DirectoryCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read ACME directory CA file: %w", err)
+			}
+			roots, err := x509.SystemCertPool()
+			if err != nil {
+				roots = x509.NewCertPool()
+			}
+			if !roots.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("ACME directory CA file %s contains no PEM encoded certificates", options.DirectoryCAFile)
+			}
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+			client.HTTPClient = &http.Client{Transport: transport}
+		}
+		manager.Client = client
+	}
+	return manager, nil
+}
+
+// HostPolicy allows the hosts returned by hosts, ports of the hosts are ignored
+func HostPolicy(hosts func() []string) autocert.HostPolicy {
+	return func(_ context.Context, host string) error {
+		for _, allowed := range hosts() {
+			if h, _, err := net.SplitHostPort(allowed); err == nil {
+				allowed = h
+			}
+			if strings.EqualFold(allowed, host) {
+				return nil
+			}
+		}
+		return fmt.Errorf("host %q is not allowed to request a certificate", host)
+	}
+}
+
+// ChallengeSolver proves to the ACME server that the node controls the hosts
+type ChallengeSolver interface {
+	// Start answers the challenges of manager until ctx is done
+	Start(ctx context.Context, manager *autocert.Manager) error
+	// NextProtos are offered by the TLS listener in addition to HTTP
+	NextProtos() []string
+}
+
+// TLSALPNSolver answers TLS-ALPN-01 challenges on the TLS listener of the node, which must be reachable on port 443
+type TLSALPNSolver struct{}
+
+func (TLSALPNSolver) Start(context.Context, *autocert.Manager) error {
+	// the manager answers the challenges in GetCertificate
+	return nil
+}
+
+func (TLSALPNSolver) NextProtos() []string {
+	return []string{acme.ALPNProto}
+}
+
+// HTTPSolver answers HTTP-01 challenges on a cleartext listener and redirects all other requests to HTTPS
+type HTTPSolver struct {
+	// Addr defaults to DefaultHTTPChallengeAddress
+	Addr string
+	Log  abstractlogger.Logger
+}
+
+func (s HTTPSolver) Start(ctx context.Context, manager *autocert.Manager) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultHTTPChallengeAddress
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("could not listen for ACME HTTP challenges: %w", err)
+	}
+	server := &http.Server{
+		Handler:           manager.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	go func() {
+		if err := server.Serve(l); err != nil && err != http.ErrServerClosed && s.Log != nil {
+			s.Log.Error("ACME HTTP challenge listener stopped",
+				abstractlogger.String("addr", addr),
+				abstractlogger.Error(err),
+			)
+		}
+	}()
+	return nil
+}
+
+func (HTTPSolver) NextProtos() []string {
+	return nil
+}
+
+// RedisCache stores certificates in Redis, so nodes behind the same hosts share them
+type RedisCache struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisCache stores the entries below prefix
+func NewRedisCache(client redis.UniversalClient, prefix string) *RedisCache {
+	return &RedisCache{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (c *RedisCache) Get(ctx context.Context, name string) ([]byte, error) {
+	data, err := c.client.Get(ctx, c.prefix+name).Bytes()
+	if err == redis.Nil {
+		return nil, autocert.ErrCacheMiss
+	}
+	return data, err
+}
+
+func (c *RedisCache) Put(ctx context.Context, name string, data []byte) error {
+	return c.client.Set(ctx, c.prefix+name, data, 0).Err()
+}
+
+func (c *RedisCache) Delete(ctx context.Context, name string) error {
+	return c.client.Del(ctx, c.prefix+name).Err()
+}
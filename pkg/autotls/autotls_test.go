@@ -0,0 +1,88 @@
+package autotls
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jensneuse/abstractlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func TestHostPolicy(t *testing.T) {
+	hosts := []string{"api.example.com", "Other.example.com:8443"}
+	policy := HostPolicy(func() []string { return hosts })
+
+	assert.NoError(t, policy(context.Background(), "api.example.com"))
+	assert.NoError(t, policy(context.Background(), "other.example.com"))
+	assert.Error(t, policy(context.Background(), "evil.example.com"))
+
+	// reloaded configs change the allowed hosts
+	hosts = []string{"evil.example.com"}
+	assert.NoError(t, policy(context.Background(), "evil.example.com"))
+	assert.Error(t, policy(context.Background(), "api.example.com"))
+}
+
+func TestNewManager(t *testing.T) {
+	hosts := func() []string { return []string{"api.example.com"} }
+
+	_, err := NewManager(Options{Hosts: hosts})
+	assert.Error(t, err)
+
+	manager, err := NewManager(Options{
+		Hosts:        hosts,
+		Cache:        autocert.DirCache(t.TempDir()),
+		DirectoryURL: "https://localhost:14000/dir",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://localhost:14000/dir", manager.Client.DirectoryURL)
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, []byte("no certificate"), 0600))
+	_, err = NewManager(Options{
+		Hosts:           hosts,
+		Cache:           autocert.DirCache(t.TempDir()),
+		DirectoryCAFile: caFile,
+	})
+	assert.ErrorContains(t, err, "no PEM encoded certificates")
+}
+
+func TestHTTPSolver(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	manager, err := NewManager(Options{
+		Hosts: func() []string { return []string{"localhost"} },
+		Cache: autocert.DirCache(t.TempDir()),
+	})
+	require.NoError(t, err)
+	solver := HTTPSolver{Addr: addr, Log: abstractlogger.NoopLogger}
+	require.NoError(t, solver.Start(ctx, manager))
+	assert.Empty(t, solver.NextProtos())
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	res, err := client.Get("http://" + addr + "/operations/Users")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+	assert.Equal(t, "https://127.0.0.1:443/operations/Users", res.Header.Get("Location"))
+
+	// challenges are only answered for the allowed hosts
+	res, err = client.Get("http://" + addr + "/.well-known/acme-challenge/token")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
@@ -896,6 +896,11 @@ func (f *Factory) Planner(ctx context.Context) plan.DataSourcePlanner {
 	}
 }
 
+// Engine returns the engine of the database configured by config, the same one its planners use
+func (f *Factory) Engine(config Configuration) *LazyEngine {
+	return f.engineFactory.Engine(config.PrismaSchema, config.WunderGraphDir, config.CloseTimeoutSeconds)
+}
+
 type LazyEngineFactory struct {
 	closer  <-chan struct{}
 	engines map[string]*LazyEngine
@@ -994,15 +999,66 @@ func (e *LazyEngine) Execute(ctx context.Context, request []byte, out io.Writer)
 	return err
 }
 
-func (e *LazyEngine) initEngineAndExecute(ctx context.Context, request []byte, out io.Writer) error {
+// Ping starts a stopped engine and waits until it accepts requests.
+// Probes don't keep the engine running, it's stopped again once it was idle for closeTimeoutSeconds.
+func (e *LazyEngine) Ping(ctx context.Context) error {
+	engine, started, err := e.startEngine()
+	if err != nil {
+		return err
+	}
+	if started {
+		// arms the idle timeout, which is otherwise only armed by requests
+		select {
+		case e.requestWasProcessed <- struct{}{}:
+		case <-ctx.Done():
+		}
+	}
+	// the lock isn't held while waiting, so requests aren't blocked for the probe timeout
+	return engine.WaitUntilReady(ctx)
+}
+
+// startEngine returns the running engine, a stopped engine is started
+func (e *LazyEngine) startEngine() (engine HybridEngine, started bool, err error) {
+	e.m.RLock()
+	engine, closed := e.engine, e.closed
+	e.m.RUnlock()
+	if closed {
+		return nil, false, fmt.Errorf("engine closed")
+	}
+	if engine != nil {
+		return engine, false, nil
+	}
 	e.m.Lock()
 	defer e.m.Unlock()
-	var err error
-	e.engine, err = NewHybridEngine(e.prismaSchema, e.wundergraphDir, abstractlogger.NoopLogger)
+	if e.closed {
+		return nil, false, fmt.Errorf("engine closed")
+	}
+	if e.engine != nil {
+		return e.engine, false, nil
+	}
+	engine, err = NewHybridEngine(e.prismaSchema, e.wundergraphDir, abstractlogger.NoopLogger)
 	if err != nil {
-		return err
+		return nil, false, err
+	}
+	e.engine = engine
+	return engine, true, nil
+}
+
+func (e *LazyEngine) initEngineAndExecute(ctx context.Context, request []byte, out io.Writer) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+	if e.closed {
+		return fmt.Errorf("engine closed")
+	}
+	// a probe might have started the engine in the meantime
+	if e.engine == nil {
+		engine, err := NewHybridEngine(e.prismaSchema, e.wundergraphDir, abstractlogger.NoopLogger)
+		if err != nil {
+			return err
+		}
+		e.engine = engine
 	}
-	err = e.engine.WaitUntilReady(ctx)
+	err := e.engine.WaitUntilReady(ctx)
 	if err != nil {
 		return err
 	}
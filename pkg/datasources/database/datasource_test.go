@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitingEngine is ready once ready is closed
+type waitingEngine struct {
+	ready chan struct{}
+}
+
+func (e *waitingEngine) Close() {}
+
+func (e *waitingEngine) Execute(ctx context.Context, request []byte, w io.Writer) error {
+	return nil
+}
+
+func (e *waitingEngine) WaitUntilReady(ctx context.Context) error {
+	select {
+	case <-e.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestLazyEngine_Ping(t *testing.T) {
+	closer := make(chan struct{})
+	factory := &LazyEngineFactory{closer: closer}
+	engine := factory.Engine("", "", 1)
+
+	running := &waitingEngine{ready: make(chan struct{})}
+	engine.m.Lock()
+	engine.engine = running
+	engine.m.Unlock()
+
+	pinged := make(chan error)
+	go func() {
+		pinged <- engine.Ping(context.Background())
+	}()
+
+	// waiting for the engine doesn't block requests starting or stopping it
+	locked := make(chan struct{})
+	go func() {
+		engine.m.Lock()
+		engine.m.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("Ping holds the lock while waiting for the engine")
+	}
+
+	close(running.ready)
+	assert.NoError(t, <-pinged)
+
+	close(closer)
+	assert.Eventually(t, func() bool {
+		return engine.Ping(context.Background()) != nil
+	}, time.Second, 10*time.Millisecond)
+}
@@ -330,12 +330,7 @@ func (l *EngineConfigLoader) Load(engineConfig wgpb.EngineConfiguration) (*plan.
 		}
 		switch in.Kind {
 		case wgpb.DataSourceKind_REST:
-			header := http.Header{}
-			for s, httpHeader := range in.CustomRest.Fetch.Header {
-				for _, value := range httpHeader.Values {
-					header.Add(s, loadvariable.String(value))
-				}
-			}
+			header := fetchHeader(in.CustomRest.Fetch)
 			var query []oas_datasource.QueryConfiguration
 			for _, configuration := range in.CustomRest.Fetch.Query {
 				query = append(query, oas_datasource.QueryConfiguration{
@@ -378,12 +373,7 @@ func (l *EngineConfigLoader) Load(engineConfig wgpb.EngineConfiguration) (*plan.
 				Data: loadvariable.String(in.CustomStatic.Data),
 			})
 		case wgpb.DataSourceKind_GRAPHQL:
-			header := http.Header{}
-			for s, httpHeader := range in.CustomGraphql.Fetch.Header {
-				for _, value := range httpHeader.Values {
-					header.Add(s, loadvariable.String(value))
-				}
-			}
+			header := fetchHeader(in.CustomGraphql.Fetch)
 
 			fetchUrl := buildFetchUrl(
 				loadvariable.String(in.CustomGraphql.Fetch.GetUrl()),
@@ -507,6 +497,17 @@ func (l *EngineConfigLoader) addDataSourceToPrismaSchema(schema, databaseURL str
 	return dataSource + schema
 }
 
+// fetchHeader returns the static headers sent with every request of fetch
+func fetchHeader(fetch *wgpb.FetchConfiguration) http.Header {
+	header := http.Header{}
+	for s, httpHeader := range fetch.GetHeader() {
+		for _, value := range httpHeader.Values {
+			header.Add(s, loadvariable.String(value))
+		}
+	}
+	return header
+}
+
 func buildFetchUrl(url, baseUrl, path string) string {
 	if url != "" {
 		return url
@@ -0,0 +1,84 @@
+package engineconfigloader
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wundergraph/graphql-go-tools/pkg/engine/datasource/graphql_datasource"
+
+	"github.com/wundergraph/wundergraph/pkg/datasources/database"
+	oas_datasource "github.com/wundergraph/wundergraph/pkg/datasources/oas"
+	"github.com/wundergraph/wundergraph/pkg/healthcheck"
+	"github.com/wundergraph/wundergraph/pkg/loadvariable"
+	"github.com/wundergraph/wundergraph/pkg/wgpb"
+)
+
+// Probes returns a readiness probe for every datasource of engineConfig that depends on an upstream,
+// they use the same clients and engines as the datasources. Probes time out after the request timeout of their datasource if it's set.
+// Load must have been called with the same config before, so databases share their engines with the planners.
+func (l *EngineConfigLoader) Probes(engineConfig *wgpb.EngineConfiguration) ([]healthcheck.Probe, error) {
+	var probes []healthcheck.Probe
+	for _, in := range engineConfig.GetDatasourceConfigurations() {
+		factory, err := l.resolveFactory(in)
+		if err != nil {
+			return nil, err
+		}
+		var probe healthcheck.Probe
+		switch in.Kind {
+		case wgpb.DataSourceKind_GRAPHQL:
+			graphqlFactory, ok := factory.(*graphql_datasource.Factory)
+			if !ok {
+				continue
+			}
+			fetch := in.CustomGraphql.GetFetch()
+			probe = healthcheck.GraphQL(in.Id, fetchURL(fetch), fetchHeader(fetch), graphqlFactory.HTTPClient)
+		case wgpb.DataSourceKind_REST:
+			restFactory, ok := factory.(*oas_datasource.Factory)
+			if !ok {
+				continue
+			}
+			fetch := in.CustomRest.GetFetch()
+			probe = healthcheck.REST(in.Id, fetchURL(fetch), fetchHeader(fetch), restFactory.Client)
+		case wgpb.DataSourceKind_POSTGRESQL,
+			wgpb.DataSourceKind_MYSQL,
+			wgpb.DataSourceKind_SQLSERVER,
+			wgpb.DataSourceKind_MONGODB,
+			wgpb.DataSourceKind_SQLITE:
+			databaseFactory, ok := factory.(*database.Factory)
+			if !ok || in.CustomDatabase == nil {
+				continue
+			}
+			databaseURL := loadvariable.String(in.CustomDatabase.DatabaseURL)
+			closeTimeoutSeconds := in.CustomDatabase.CloseTimeoutSeconds
+			if closeTimeoutSeconds == 0 {
+				closeTimeoutSeconds = 30
+			}
+			engine := databaseFactory.Engine(database.Configuration{
+				PrismaSchema:        l.addDataSourceToPrismaSchema(in.CustomDatabase.PrismaSchema, databaseURL, in.Kind),
+				CloseTimeoutSeconds: closeTimeoutSeconds,
+				WunderGraphDir:      l.wundergraphDir,
+			})
+			probe = healthcheck.Probe{
+				Name:  in.Id,
+				Kind:  healthcheck.KindDatabase,
+				Check: engine.Ping,
+			}
+		default:
+			continue
+		}
+		if probe.Name == "" {
+			probe.Name = fmt.Sprintf("%s-%d", probe.Kind, len(probes))
+		}
+		probe.Timeout = time.Duration(in.RequestTimeoutSeconds) * time.Second
+		probes = append(probes, probe)
+	}
+	return probes, nil
+}
+
+func fetchURL(fetch *wgpb.FetchConfiguration) string {
+	return buildFetchUrl(
+		loadvariable.String(fetch.GetUrl()),
+		loadvariable.String(fetch.GetBaseUrl()),
+		loadvariable.String(fetch.GetPath()),
+	)
+}
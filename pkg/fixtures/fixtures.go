@@ -0,0 +1,349 @@
+// Package fixtures records the responses of origins to files and replays them,
+// so the node can serve operations without network access.
+package fixtures
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrNotFound is returned in replay mode for requests without a recorded fixture
+var ErrNotFound = errors.New("fixture not found")
+
+type Mode string
+
+const (
+	// Disabled sends all requests to the origins
+	Disabled Mode = ""
+	// Record sends requests to the origins and stores their responses as fixtures
+	Record Mode = "record"
+	// Replay serves the recorded fixtures without sending requests to the origins
+	Replay Mode = "replay"
+)
+
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(mode) {
+	case Disabled, Record, Replay:
+		return Mode(mode), nil
+	default:
+		return Disabled, fmt.Errorf("unknown fixtures mode %q, must be %q or %q", mode, Record, Replay)
+	}
+}
+
+type Options struct {
+	Mode Mode
+	// Dir stores one file per fixture, named by the hash of the request
+	Dir string
+	// Headers are the names of the request headers identifying a fixture in addition to the method, URL and body,
+	// e.g. to record responses depending on the Authorization header
+	Headers []string
+}
+
+// Fixture is a recorded response of an origin
+type Fixture struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Header contains the headers included in the key only
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body"`
+}
+
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       Body        `json:"body"`
+}
+
+// Body is stored as string, so fixtures can be read and edited, unless it's binary
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(struct {
+		Base64 string `json:"base64"`
+	}{
+		Base64: base64.StdEncoding.EncodeToString(b),
+	})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+		return nil
+	}
+	var binary struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &binary); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(binary.Base64)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Key identifies the fixture of a request by its method, URL, header and body.
+// Only the headers selected by Options.Headers should be passed, most carry values changing with every request,
+// e.g. trace ids.
+func Key(method, url string, header http.Header, body []byte) string {
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, method)
+	_, _ = hash.Write([]byte{0})
+	_, _ = io.WriteString(hash, url)
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write(body)
+	// without headers the key stays the same as before headers could be selected, so recorded fixtures remain valid
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, _ = hash.Write([]byte{0})
+		_, _ = io.WriteString(hash, name)
+		for _, value := range header[name] {
+			_, _ = hash.Write([]byte{0})
+			_, _ = io.WriteString(hash, value)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// NewTransport records the responses of next or replays them, depending on the mode.
+// It returns next if fixtures are disabled. Connection upgrades, e.g. WebSockets, aren't recorded.
+func NewTransport(options Options, next http.RoundTripper) http.RoundTripper {
+	if options.Mode == Disabled {
+		return next
+	}
+	return &transport{
+		options: options,
+		next:    next,
+	}
+}
+
+// NewStreamingTransport is NewTransport for streamed responses, e.g. of subscriptions, which might never end.
+// Their body is recorded as it's read and stored once it's closed, replays serve the recorded part of the stream.
+func NewStreamingTransport(options Options, next http.RoundTripper) http.RoundTripper {
+	if options.Mode == Disabled {
+		return next
+	}
+	return &transport{
+		options:   options,
+		next:      next,
+		streaming: true,
+	}
+}
+
+type transport struct {
+	options   Options
+	next      http.RoundTripper
+	streaming bool
+}
+
+// keyHeader returns the headers of req selected by Options.Headers
+func (t *transport) keyHeader(req *http.Request) http.Header {
+	var header http.Header
+	for _, name := range t.options.Headers {
+		values := req.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if header == nil {
+			header = http.Header{}
+		}
+		header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	return header
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the response body of upgraded connections is the connection, it can't be recorded
+	if req.Header.Get("Upgrade") != "" {
+		if t.options.Mode == Replay {
+			return nil, fmt.Errorf("%s %s: connection upgrades can't be replayed: %w", req.Method, req.URL.String(), ErrNotFound)
+		}
+		return t.next.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	header := t.keyHeader(req)
+	key := Key(req.Method, req.URL.String(), header, body)
+	path := filepath.Join(t.options.Dir, key+".json")
+
+	if t.options.Mode == Replay {
+		fixture, err := load(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.String(), ErrNotFound)
+			}
+			return nil, err
+		}
+		return fixture.Response.httpResponse(req), nil
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	record := func(responseBody []byte) error {
+		fixture := &Fixture{
+			Request: Request{
+				Method: req.Method,
+				URL:    req.URL.String(),
+				Header: header,
+				Body:   body,
+			},
+			Response: Response{
+				StatusCode: res.StatusCode,
+				Header:     res.Header,
+				Body:       responseBody,
+			},
+		}
+		if err := store(t.options.Dir, path, fixture); err != nil {
+			return fmt.Errorf("recording fixture of %s %s: %w", req.Method, req.URL.String(), err)
+		}
+		return nil
+	}
+
+	if t.streaming {
+		res.Body = &recordingBody{
+			body:   res.Body,
+			record: record,
+		}
+		return res, nil
+	}
+
+	responseBody, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(responseBody))
+	if err := record(responseBody); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// recordingBody records a streamed response body as it's read, it's stored once the body is closed
+type recordingBody struct {
+	body   io.ReadCloser
+	record func(body []byte) error
+
+	mux      sync.Mutex
+	recorded bytes.Buffer
+	once     sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.mux.Lock()
+	b.recorded.Write(p[:n])
+	b.mux.Unlock()
+	return n, err
+}
+
+// Close might be called concurrently to Read to end the stream
+func (b *recordingBody) Close() error {
+	err := b.body.Close()
+	b.once.Do(func() {
+		b.mux.Lock()
+		recorded := append([]byte(nil), b.recorded.Bytes()...)
+		b.mux.Unlock()
+		if recordErr := b.record(recorded); recordErr != nil && err == nil {
+			err = recordErr
+		}
+	})
+	return err
+}
+
+func (r *Response) httpResponse(req *http.Request) *http.Response {
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	// the body is stored as received, so its length might differ from the recorded header
+	header.Set("Content-Length", strconv.Itoa(len(r.Body)))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+func load(path string) (*Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// store writes the fixture to a temporary file first, so concurrent replays never read partial fixtures
+func store(dir, path string, fixture *Fixture) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, ".fixture-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
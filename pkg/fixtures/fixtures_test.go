@@ -0,0 +1,167 @@
+package fixtures
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport_RecordReplay(t *testing.T) {
+	var requests int
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Origin", "yes")
+		if r.URL.Path == "/binary" {
+			_, _ = w.Write([]byte{0xff, 0x00, 0xfe})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"echo":` + string(body) + `}`))
+	}))
+
+	dir := t.TempDir()
+	do := func(transport http.RoundTripper, path, body string) (*http.Response, []byte, error) {
+		req, err := http.NewRequest(http.MethodPost, origin.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		res, err := transport.RoundTrip(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer res.Body.Close()
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, data, nil
+	}
+
+	recorder := NewTransport(Options{Mode: Record, Dir: dir}, http.DefaultTransport)
+	res, body, err := do(recorder, "/graphql", `{"id":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, `{"echo":{"id":1}}`, string(body))
+	_, body, err = do(recorder, "/binary", "")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0x00, 0xfe}, body)
+	assert.Equal(t, 2, requests)
+
+	// replays work without the origin
+	origin.Close()
+	replayer := NewTransport(Options{Mode: Replay, Dir: dir}, http.DefaultTransport)
+	res, body, err = do(replayer, "/graphql", `{"id":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "yes", res.Header.Get("X-Origin"))
+	assert.Equal(t, `{"echo":{"id":1}}`, string(body))
+	_, body, err = do(replayer, "/binary", "")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0x00, 0xfe}, body)
+	assert.Equal(t, 2, requests)
+
+	// requests are keyed by their body
+	_, _, err = do(replayer, "/graphql", `{"id":2}`)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestTransport_Headers(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+
+	dir := t.TempDir()
+	do := func(transport http.RoundTripper, authorization string) (string, error) {
+		req, err := http.NewRequest(http.MethodGet, origin.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", authorization)
+		req.Header.Set("X-Request-Id", authorization+"-id")
+		res, err := transport.RoundTrip(req)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return string(data), nil
+	}
+
+	options := Options{Mode: Record, Dir: dir, Headers: []string{"authorization"}}
+	recorder := NewTransport(options, http.DefaultTransport)
+	for _, authorization := range []string{"Bearer a", "Bearer b"} {
+		body, err := do(recorder, authorization)
+		require.NoError(t, err)
+		assert.Equal(t, authorization, body)
+	}
+
+	// requests are keyed by the selected headers only
+	origin.Close()
+	options.Mode = Replay
+	replayer := NewTransport(options, http.DefaultTransport)
+	for _, authorization := range []string{"Bearer a", "Bearer b"} {
+		body, err := do(replayer, authorization)
+		require.NoError(t, err)
+		assert.Equal(t, authorization, body)
+	}
+	_, err := do(replayer, "Bearer c")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// without headers, the key doesn't change
+	assert.Equal(t, Key(http.MethodGet, "/", nil, nil), Key(http.MethodGet, "/", http.Header{}, nil))
+	assert.NotEqual(t, Key(http.MethodGet, "/", nil, nil), Key(http.MethodGet, "/", http.Header{"Authorization": {""}}, nil))
+}
+
+func TestStreamingTransport_RecordReplay(t *testing.T) {
+	var requests int
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, message := range []string{"data: 1\n\n", "data: 2\n\n"} {
+			_, _ = w.Write([]byte(message))
+			w.(http.Flusher).Flush()
+		}
+		// the stream doesn't end until the client goes away
+		<-r.Context().Done()
+	}))
+
+	dir := t.TempDir()
+	subscribe := func(transport http.RoundTripper) (string, error) {
+		req, err := http.NewRequest(http.MethodGet, origin.URL+"/subscription", nil)
+		require.NoError(t, err)
+		res, err := transport.RoundTrip(req)
+		if err != nil {
+			return "", err
+		}
+		data := make([]byte, len("data: 1\n\ndata: 2\n\n"))
+		_, err = io.ReadFull(res.Body, data)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return string(data), nil
+	}
+
+	recorder := NewStreamingTransport(Options{Mode: Record, Dir: dir}, http.DefaultTransport)
+	data, err := subscribe(recorder)
+	require.NoError(t, err)
+	assert.Equal(t, "data: 1\n\ndata: 2\n\n", data)
+	assert.Equal(t, 1, requests)
+
+	// replays serve the recorded messages without calling the origin
+	replayer := NewStreamingTransport(Options{Mode: Replay, Dir: dir}, http.DefaultTransport)
+	data, err = subscribe(replayer)
+	require.NoError(t, err)
+	assert.Equal(t, "data: 1\n\ndata: 2\n\n", data)
+	assert.Equal(t, 1, requests)
+	origin.Close()
+}
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range []Mode{Disabled, Record, Replay} {
+		parsed, err := ParseMode(string(mode))
+		assert.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+	_, err := ParseMode("mock")
+	assert.Error(t, err)
+}
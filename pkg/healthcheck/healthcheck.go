@@ -0,0 +1,210 @@
+// Package healthcheck probes the dependencies of an API, e.g. to report its readiness to Kubernetes
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	StatusReady    = "READY"
+	StatusNotReady = "NOT_READY"
+)
+
+const (
+	KindGraphQL  = "graphql"
+	KindREST     = "rest"
+	KindDatabase = "database"
+	KindRedis    = "redis"
+	KindHooks    = "hooks"
+)
+
+// Probe checks a single dependency
+type Probe struct {
+	// Name identifies the dependency, e.g. the id of a datasource
+	Name string
+	Kind string
+	// Timeout overrides the default timeout of Run for this probe
+	Timeout time.Duration
+	Check   func(ctx context.Context) error
+}
+
+type Result struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	// Error isn't part of the report, as it might reveal internals of the dependency
+	Error      string `json:"-"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Run runs all probes concurrently, each with its own timeout.
+// The report is ready if all probes succeeded.
+func Run(ctx context.Context, probes []Probe, defaultTimeout time.Duration) (*Report, bool) {
+	report := &Report{
+		Status: StatusReady,
+		Checks: make([]Result, len(probes)),
+	}
+	var wg sync.WaitGroup
+	for i := range probes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Checks[i] = run(ctx, probes[i], defaultTimeout)
+		}(i)
+	}
+	wg.Wait()
+	for _, result := range report.Checks {
+		if result.Status != StatusReady {
+			report.Status = StatusNotReady
+		}
+	}
+	return report, report.Status == StatusReady
+}
+
+// Cached runs probes at most once per TTL, concurrent callers share the same run.
+// It protects dependencies from callers probing the API more often than the dependencies can handle.
+type Cached struct {
+	probes         []Probe
+	defaultTimeout time.Duration
+	ttl            time.Duration
+	runs           singleflight.Group
+
+	mu      sync.Mutex
+	report  *Report
+	ready   bool
+	expires time.Time
+}
+
+type cachedReport struct {
+	report *Report
+	ready  bool
+}
+
+func NewCached(probes []Probe, defaultTimeout, ttl time.Duration) *Cached {
+	return &Cached{
+		probes:         probes,
+		defaultTimeout: defaultTimeout,
+		ttl:            ttl,
+	}
+}
+
+// Run returns the cached report if it's younger than the TTL, otherwise it runs the probes.
+// The probes don't run with ctx but only with their timeouts, so a caller giving up can't fail
+// the report cached for all others. The caller gets a report which isn't ready in that case.
+func (c *Cached) Run(ctx context.Context) (*Report, bool) {
+	c.mu.Lock()
+	if c.report != nil && time.Now().Before(c.expires) {
+		report, ready := c.report, c.ready
+		c.mu.Unlock()
+		return report, ready
+	}
+	c.mu.Unlock()
+
+	run := c.runs.DoChan("", func() (interface{}, error) {
+		report, ready := Run(context.Background(), c.probes, c.defaultTimeout)
+		c.mu.Lock()
+		c.report, c.ready, c.expires = report, ready, time.Now().Add(c.ttl)
+		c.mu.Unlock()
+		return cachedReport{report: report, ready: ready}, nil
+	})
+	select {
+	case result := <-run:
+		cached := result.Val.(cachedReport)
+		return cached.report, cached.ready
+	case <-ctx.Done():
+		return &Report{Status: StatusNotReady, Checks: []Result{}}, false
+	}
+}
+
+func run(ctx context.Context, probe Probe, defaultTimeout time.Duration) Result {
+	timeout := probe.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := probe.Check(ctx)
+	result := Result{
+		Name:       probe.Name,
+		Kind:       probe.Kind,
+		Status:     StatusReady,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = StatusNotReady
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// GraphQL queries __typename from the GraphQL server at url
+func GraphQL(name, url string, header http.Header, client *http.Client) Probe {
+	return Probe{
+		Name: name,
+		Kind: KindGraphQL,
+		Check: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString(`{"query":"{__typename}"}`))
+			if err != nil {
+				return err
+			}
+			for key, values := range header {
+				req.Header[key] = values
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json")
+			return do(client, req)
+		},
+	}
+}
+
+// REST sends a HEAD request to url, any response below 500 proves the API is reachable
+func REST(name, url string, header http.Header, client *http.Client) Probe {
+	return Probe{
+		Name: name,
+		Kind: KindREST,
+		Check: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+			if err != nil {
+				return err
+			}
+			for key, values := range header {
+				req.Header[key] = values
+			}
+			return do(client, req)
+		},
+	}
+}
+
+func do(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package healthcheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	graphql := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"query":"{__typename}"}`, string(body))
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		_, _ = w.Write([]byte(`{"data":{"__typename":"Query"}}`))
+	}))
+	defer graphql.Close()
+	rest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer rest.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	report, ready := Run(context.Background(), []Probe{
+		GraphQL("countries", graphql.URL, http.Header{"X-Api-Key": {"secret"}}, nil),
+		REST("weather", rest.URL, nil, nil),
+	}, time.Second)
+	assert.True(t, ready)
+	assert.Equal(t, StatusReady, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, Result{Name: "countries", Kind: KindGraphQL, Status: StatusReady, DurationMs: report.Checks[0].DurationMs}, report.Checks[0])
+
+	report, ready = Run(context.Background(), []Probe{
+		REST("weather", rest.URL, nil, nil),
+		REST("broken", broken.URL, nil, nil),
+	}, time.Second)
+	assert.False(t, ready)
+	assert.Equal(t, StatusNotReady, report.Status)
+	assert.Equal(t, StatusReady, report.Checks[0].Status)
+	assert.Equal(t, StatusNotReady, report.Checks[1].Status)
+	assert.Contains(t, report.Checks[1].Error, "502")
+}
+
+func TestRunTimeouts(t *testing.T) {
+	block := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	start := time.Now()
+	report, ready := Run(context.Background(), []Probe{
+		{Name: "default", Kind: KindDatabase, Check: block},
+		{Name: "own", Kind: KindDatabase, Check: block, Timeout: 50 * time.Millisecond},
+	}, 100*time.Millisecond)
+	assert.False(t, ready)
+	assert.Less(t, time.Since(start), time.Second, "probes run concurrently")
+	assert.GreaterOrEqual(t, report.Checks[0].DurationMs, int64(100))
+	assert.Less(t, report.Checks[1].DurationMs, int64(100))
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[1].Error)
+}
+
+func TestCached(t *testing.T) {
+	var calls int32
+	probes := []Probe{
+		{
+			Name: "counter",
+			Check: func(ctx context.Context) error {
+				atomic.AddInt32(&calls, 1)
+				return nil
+			},
+		},
+	}
+	cached := NewCached(probes, time.Second, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		_, ready := cached.Run(context.Background())
+		assert.True(t, ready)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	time.Sleep(60 * time.Millisecond)
+	_, ready := cached.Run(context.Background())
+	assert.True(t, ready)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCached_CallerCanceled(t *testing.T) {
+	release := make(chan struct{})
+	probes := []Probe{
+		{
+			Name: "slow",
+			Check: func(ctx context.Context) error {
+				select {
+				case <-release:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+		},
+	}
+	cached := NewCached(probes, time.Second, time.Minute)
+
+	// a caller giving up doesn't fail the run shared with others
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan bool)
+	go func() {
+		_, ready := cached.Run(ctx)
+		canceled <- ready
+	}()
+	waiting := make(chan bool)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, ready := cached.Run(context.Background())
+		waiting <- ready
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.False(t, <-canceled)
+
+	close(release)
+	assert.True(t, <-waiting)
+	report, ready := cached.Run(context.Background())
+	assert.True(t, ready)
+	assert.Equal(t, StatusReady, report.Status)
+}
@@ -0,0 +1,160 @@
+package hooks
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for hooks which aren't called because their circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "CLOSED"
+	CircuitOpen     CircuitState = "OPEN"
+	CircuitHalfOpen CircuitState = "HALF_OPEN"
+)
+
+// CircuitBreakerOptions configure the circuit breakers tracked per hook and operation
+type CircuitBreakerOptions struct {
+	Disabled bool
+	// FailureThreshold is the number of consecutive failures opening the circuit
+	FailureThreshold int
+	// OpenTimeout is the time the circuit stays open before a single request probes the hooks server again
+	OpenTimeout time.Duration
+}
+
+var DefaultCircuitBreakerOptions = CircuitBreakerOptions{
+	FailureThreshold: 5,
+	OpenTimeout:      time.Second * 30,
+}
+
+// WithCircuitBreaker replaces DefaultCircuitBreakerOptions
+func WithCircuitBreaker(options CircuitBreakerOptions) Option {
+	return func(client *Client) {
+		client.breakerOptions = options
+	}
+}
+
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+}
+
+type circuitBreakers struct {
+	options CircuitBreakerOptions
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newCircuitBreakers(options CircuitBreakerOptions) *circuitBreakers {
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = DefaultCircuitBreakerOptions.FailureThreshold
+	}
+	if options.OpenTimeout <= 0 {
+		options.OpenTimeout = DefaultCircuitBreakerOptions.OpenTimeout
+	}
+	return &circuitBreakers{
+		options:  options,
+		breakers: map[string]*circuitBreaker{},
+	}
+}
+
+func (c *circuitBreakers) get(key string) *circuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	breaker, ok := c.breakers[key]
+	if !ok {
+		breaker = &circuitBreaker{state: CircuitClosed}
+		c.breakers[key] = breaker
+	}
+	return breaker
+}
+
+// allow reports whether a request may be sent.
+// Once the open timeout elapsed, a single request is let through to probe the hooks server.
+func (c *circuitBreakers) allow(key string) bool {
+	if c.options.Disabled {
+		return true
+	}
+	breaker := c.get(key)
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	switch breaker.state {
+	case CircuitOpen:
+		if time.Since(breaker.openedAt) < c.options.OpenTimeout {
+			return false
+		}
+		breaker.state = CircuitHalfOpen
+		breaker.openedAt = time.Now()
+		return true
+	case CircuitHalfOpen:
+		// the probe might never have finished, e.g. because the client went away
+		if time.Since(breaker.openedAt) < c.options.OpenTimeout {
+			return false
+		}
+		breaker.openedAt = time.Now()
+		return true
+	default:
+		return true
+	}
+}
+
+// record tracks the outcome of a request and reports whether it opened the circuit
+func (c *circuitBreakers) record(key string, success bool) (opened bool) {
+	if c.options.Disabled {
+		return false
+	}
+	breaker := c.get(key)
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	if success {
+		breaker.state = CircuitClosed
+		breaker.failures = 0
+		return false
+	}
+	breaker.failures++
+	if breaker.state == CircuitOpen {
+		return false
+	}
+	if breaker.state == CircuitHalfOpen || breaker.failures >= c.options.FailureThreshold {
+		breaker.state = CircuitOpen
+		breaker.openedAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// CircuitBreakerStatus is the state of the circuit breaker of a hook
+type CircuitBreakerStatus struct {
+	Hook     string       `json:"hook"`
+	State    CircuitState `json:"state"`
+	Failures int          `json:"failures"`
+}
+
+// CircuitBreakers returns the state of all circuit breakers which saw a request, sorted by hook
+func (c *Client) CircuitBreakers() []CircuitBreakerStatus {
+	c.breakers.mu.Lock()
+	statuses := make([]CircuitBreakerStatus, 0, len(c.breakers.breakers))
+	for key, breaker := range c.breakers.breakers {
+		breaker.mu.Lock()
+		statuses = append(statuses, CircuitBreakerStatus{
+			Hook:     key,
+			State:    breaker.state,
+			Failures: breaker.failures,
+		})
+		breaker.mu.Unlock()
+	}
+	c.breakers.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Hook < statuses[j].Hook
+	})
+	return statuses
+}
@@ -0,0 +1,141 @@
+package hooks
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/buger/jsonparser"
+	"github.com/cespare/xxhash"
+)
+
+// FailurePolicy decides how a failed hook request is handled.
+// Hooks which aren't called because their circuit breaker is open count as failed.
+type FailurePolicy int
+
+const (
+	// FailClosed returns the error, which fails the request calling the hook
+	FailClosed FailurePolicy = iota
+	// FailSkip continues as if the hook wasn't configured.
+	// mockResolve and hooks deciding about access can't be skipped and fail closed, see ValidatePolicy.
+	FailSkip
+	// FailLastGood reuses the last successful response of the hook for the same operation and input,
+	// including the user. It fails closed if there is none.
+	// Hooks depending on the authentication or the origin request fail closed instead, see ValidatePolicy.
+	FailLastGood
+)
+
+// requestScopedHooks act on the user or the origin request of a single client request,
+// replaying their responses would hand one request's result to another
+var requestScopedHooks = map[MiddlewareHook]bool{
+	MutatingPostResolve:         true,
+	PostAuthentication:          true,
+	PostLogout:                  true,
+	MutatingPostAuthentication:  true,
+	RevalidateAuthentication:    true,
+	HttpTransportOnRequest:      true,
+	HttpTransportOnResponse:     true,
+	WsTransportOnConnectionInit: true,
+}
+
+// accessHooks decide whether a client is authenticated or may subscribe,
+// skipping them on failure would let the client in
+var accessHooks = map[MiddlewareHook]bool{
+	PostAuthentication:         true,
+	MutatingPostAuthentication: true,
+	RevalidateAuthentication:   true,
+	OnSubscriptionStart:        true,
+}
+
+// maxLastGood bounds the stored responses, they are dropped once exceeded
+const maxLastGood = 1000
+
+// ValidatePolicy rejects policies of unknown hooks and failure policies which can't apply to hook
+func ValidatePolicy(hook MiddlewareHook, policy Policy) error {
+	if !middlewareHooks[hook] {
+		return fmt.Errorf("unknown hook %q", hook)
+	}
+	if policy.OnFailure == FailLastGood && requestScopedHooks[hook] {
+		return fmt.Errorf("hook %s can't reuse its last good response, as it depends on the client request", hook)
+	}
+	if policy.OnFailure == FailSkip && accessHooks[hook] {
+		return fmt.Errorf("hook %s can't be skipped, as it decides whether the client is allowed in", hook)
+	}
+	return nil
+}
+
+func (c *Client) failurePolicy(hook MiddlewareHook) FailurePolicy {
+	onFailure := c.policy(hook).OnFailure
+	// the default policy may apply to them
+	if onFailure == FailLastGood && requestScopedHooks[hook] {
+		return FailClosed
+	}
+	if onFailure == FailSkip && accessHooks[hook] {
+		return FailClosed
+	}
+	return onFailure
+}
+
+func (c *Client) fallback(key string, hook MiddlewareHook, jsonData []byte, err error) (*MiddlewareHookResponse, error) {
+	switch c.failurePolicy(hook) {
+	case FailSkip:
+		if hookRes := skipResponse(hook, jsonData); hookRes != nil {
+			return hookRes, nil
+		}
+	case FailLastGood:
+		c.lastGoodMu.RLock()
+		hookRes, ok := c.lastGood[lastGoodKey(key, jsonData)]
+		c.lastGoodMu.RUnlock()
+		if ok {
+			return hookRes, nil
+		}
+	}
+	return nil, err
+}
+
+func (c *Client) storeLastGood(key string, hook MiddlewareHook, jsonData []byte, hookRes *MiddlewareHookResponse) {
+	if c.failurePolicy(hook) != FailLastGood {
+		return
+	}
+	c.lastGoodMu.Lock()
+	if len(c.lastGood) >= maxLastGood {
+		c.lastGood = map[string]*MiddlewareHookResponse{}
+	}
+	c.lastGood[lastGoodKey(key, jsonData)] = hookRes
+	c.lastGoodMu.Unlock()
+}
+
+// lastGoodKey scopes responses to the hook input, which contains the user
+func lastGoodKey(key string, jsonData []byte) string {
+	return key + "/" + strconv.FormatUint(xxhash.Sum64(jsonData), 16)
+}
+
+// skipResponse is the response of a hook leaving the request and response as they are,
+// it returns nil for hooks which can't be skipped
+func skipResponse(hook MiddlewareHook, jsonData []byte) *MiddlewareHookResponse {
+	hookRes := &MiddlewareHookResponse{
+		Hook:     string(hook),
+		Response: []byte(`{}`),
+	}
+	switch hook {
+	case MockResolve:
+		return nil
+	case CustomResolve:
+		// null makes the node resolve the operation itself
+		hookRes.Response = []byte(`null`)
+	case MutatingPreResolve:
+		if input, _, _, err := jsonparser.Get(jsonData, "input"); err == nil {
+			hookRes.Input = input
+		} else {
+			hookRes.Input = []byte(`{}`)
+		}
+	case MutatingPostResolve:
+		response, _, _, err := jsonparser.Get(jsonData, "response")
+		if err != nil {
+			return nil
+		}
+		hookRes.Response = response
+	case HttpTransportOnRequest, HttpTransportOnResponse:
+		hookRes.Response = []byte(`{"skip":true}`)
+	}
+	return hookRes
+}
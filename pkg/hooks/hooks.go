@@ -4,18 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/buger/jsonparser"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/jensneuse/abstractlogger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/wundergraph/wundergraph/pkg/metrics"
 	"github.com/wundergraph/wundergraph/pkg/pool"
+	"github.com/wundergraph/wundergraph/pkg/tracing"
 )
 
 type WunderGraphRequest struct {
@@ -81,6 +86,21 @@ type OnRequestHookResponse struct {
 	Request *WunderGraphRequest `json:"request"`
 }
 
+// OnSubscriptionStartHookResponse is the response of the onSubscriptionStart hook
+type OnSubscriptionStartHookResponse struct {
+	// Reject the subscription, e.g. because the user has too many concurrent subscriptions
+	Reject bool `json:"reject"`
+	// StatusCode of the rejected request, defaults to 403
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+}
+
+// OnSubscriptionMessageFilterHookResponse is the response of the onSubscriptionMessageFilter hook
+type OnSubscriptionMessageFilterHookResponse struct {
+	// Drop the message, it's not sent to the client
+	Drop bool `json:"drop"`
+}
+
 type OnResponseHookPayload struct {
 	Response      WunderGraphResponse `json:"response"`
 	OperationName string              `json:"operationName"`
@@ -122,31 +142,95 @@ const (
 	HttpTransportOnResponse MiddlewareHook = "onOriginResponse"
 
 	WsTransportOnConnectionInit MiddlewareHook = "onConnectionInit"
+
+	// OnSubscriptionStart is called before a subscription is started, it can reject the subscription
+	OnSubscriptionStart MiddlewareHook = "onSubscriptionStart"
+	// OnSubscriptionEnd is called once a started subscription ended, e.g. because the client went away
+	OnSubscriptionEnd MiddlewareHook = "onSubscriptionEnd"
+	// OnSubscriptionMessageFilter is called for every message of a subscription, it can drop the message
+	OnSubscriptionMessageFilter MiddlewareHook = "onSubscriptionMessageFilter"
 )
 
+// middlewareHooks are all hooks, hooks named in the config must be one of them
+var middlewareHooks = map[MiddlewareHook]bool{
+	MockResolve:                 true,
+	PreResolve:                  true,
+	PostResolve:                 true,
+	CustomResolve:               true,
+	MutatingPreResolve:          true,
+	MutatingPostResolve:         true,
+	PostAuthentication:          true,
+	PostLogout:                  true,
+	MutatingPostAuthentication:  true,
+	RevalidateAuthentication:    true,
+	HttpTransportOnRequest:      true,
+	HttpTransportOnResponse:     true,
+	WsTransportOnConnectionInit: true,
+	OnSubscriptionStart:         true,
+	OnSubscriptionEnd:           true,
+	OnSubscriptionMessageFilter: true,
+}
+
 type Client struct {
-	serverUrl  string
-	httpClient *retryablehttp.Client
-	log        abstractlogger.Logger
+	serverUrl    string
+	httpClient   *retryablehttp.Client
+	retryClients map[retryClientKey]*retryablehttp.Client
+	transport    http.RoundTripper
+	log          abstractlogger.Logger
+	metrics      *metrics.Metrics
+
+	unixSocket    string
+	h2c           bool
+	defaultPolicy Policy
+	policies      map[MiddlewareHook]Policy
+
+	breakerOptions CircuitBreakerOptions
+	breakers       *circuitBreakers
+	lastGoodMu     sync.RWMutex
+	lastGood       map[string]*MiddlewareHookResponse
+
+	inProcess []InProcessHooks
+}
+
+type Option func(client *Client)
+
+// WithMetrics records the duration and outcome of every hook request
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(client *Client) {
+		client.metrics = m
+	}
 }
 
-func NewClient(serverUrl string, logger abstractlogger.Logger) *Client {
-	httpClient := retryablehttp.NewClient()
-	// retry timeout is a power of 2, use 3 to have a reasonable timeout of 6 seconds
-	// INFO: retryablehttp also handles retry-after headers
-	httpClient.RetryMax = 3
-	httpClient.RetryWaitMin = time.Second * 1
-	httpClient.RetryWaitMax = time.Second * 30
-	httpClient.HTTPClient.Timeout = time.Minute * 1
-	httpClient.Logger = log.New(ioutil.Discard, "", log.LstdFlags)
-	httpClient.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
-		logger.Debug("hook request call", abstractlogger.Int("attempt", attempt), abstractlogger.String("url", req.URL.String()))
+func NewClient(serverUrl string, logger abstractlogger.Logger, opts ...Option) *Client {
+	client := &Client{
+		serverUrl:      serverUrl,
+		log:            logger,
+		defaultPolicy:  DefaultPolicy,
+		policies:       map[MiddlewareHook]Policy{},
+		breakerOptions: DefaultCircuitBreakerOptions,
+		lastGood:       map[string]*MiddlewareHookResponse{},
 	}
 
-	return &Client{
-		serverUrl:  serverUrl,
-		httpClient: httpClient,
+	for i := range opts {
+		opts[i](client)
 	}
+
+	if client.defaultPolicy.Timeout <= 0 {
+		client.defaultPolicy.Timeout = DefaultPolicy.Timeout
+	}
+	for hook, policy := range client.policies {
+		if policy.Timeout <= 0 {
+			policy.Timeout = client.defaultPolicy.Timeout
+			client.policies[hook] = policy
+		}
+	}
+
+	client.transport = newTransport(client.unixSocket, client.h2c)
+	client.newRetryClients()
+	client.httpClient = client.retryClients[newRetryClientKey(client.defaultPolicy, true)]
+	client.breakers = newCircuitBreakers(client.breakerOptions)
+
+	return client
 }
 
 func (c *Client) DoGlobalRequest(ctx context.Context, hook MiddlewareHook, jsonData []byte) (*MiddlewareHookResponse, error) {
@@ -161,6 +245,11 @@ func (c *Client) DoOperationRequest(ctx context.Context, operationName string, h
 	return c.doRequest(ctx, "operation/"+operationName, hook, jsonData)
 }
 
+// DoGraphQLRequest calls a hook of requests to the GraphQL endpoint with the given operation name
+func (c *Client) DoGraphQLRequest(ctx context.Context, operationName string, hook MiddlewareHook, jsonData []byte) (*MiddlewareHookResponse, error) {
+	return c.doRequest(ctx, "global/graphql/"+operationName, hook, jsonData)
+}
+
 func (c *Client) DoAuthenticationRequest(ctx context.Context, hook MiddlewareHook, jsonData []byte) (*MiddlewareHookResponse, error) {
 	return c.doRequest(ctx, "authentication", hook, jsonData)
 }
@@ -178,44 +267,109 @@ func (c *Client) setInternalHookData(ctx context.Context, jsonData []byte) []byt
 	return jsonData
 }
 
+// doRequest calls the hook unless its circuit breaker is open.
+// Failed requests are handled according to the failure policy of the hook.
 func (c *Client) doRequest(ctx context.Context, action string, hook MiddlewareHook, jsonData []byte) (*MiddlewareHookResponse, error) {
+	if len(c.inProcess) != 0 {
+		hookRes, err := c.inProcessRequest(ctx, action, hook, jsonData)
+		if !errors.Is(err, ErrHookNotImplemented) {
+			return hookRes, err
+		}
+	}
+
+	key := action + "/" + string(hook)
+	if !c.breakers.allow(key) {
+		return c.fallback(key, hook, jsonData, fmt.Errorf("middleware hook %s failed: %w", string(hook), ErrCircuitOpen))
+	}
+
+	hookRes, err := c.request(ctx, action, hook, jsonData)
+	if err != nil {
+		if ctx.Err() != nil {
+			// the client went away, this says nothing about the hooks server
+			return nil, err
+		}
+		if c.breakers.record(key, false) {
+			c.log.Error("circuit breaker of middleware hook opened",
+				abstractlogger.String("hook", key),
+				abstractlogger.Error(err),
+			)
+		}
+		return c.fallback(key, hook, jsonData, err)
+	}
+	c.breakers.record(key, true)
+
+	// errors of the hook itself are no failure of the hooks server
+	if hookRes.Error != "" {
+		return nil, fmt.Errorf("middleware hook %s failed with error: %s", string(hook), hookRes.Error)
+	}
+	c.storeLastGood(key, hook, jsonData, hookRes)
+	return hookRes, nil
+}
+
+func (c *Client) request(ctx context.Context, action string, hook MiddlewareHook, jsonData []byte) (hookRes *MiddlewareHookResponse, err error) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "hook "+string(hook),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("wg.hook.action", action),
+			attribute.String("wg.hook.name", string(hook)),
+		),
+	)
+	defer func() {
+		c.metrics.ObserveHook(action, string(hook), err, time.Since(start))
+		tracing.EndSpan(span, err)
+	}()
+
 	jsonData = c.setInternalHookData(ctx, jsonData)
 	r, err := http.NewRequestWithContext(ctx, "POST", c.serverUrl+"/"+action+"/"+string(hook), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	r.Header.Set("Content-Type", "application/json")
+	tracing.InjectHeaders(ctx, r.Header)
 	req, err := retryablehttp.FromRequest(r)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.retryClient(hook).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("middleware hook %s failed with invalid status code: %d, cause: %w", string(hook), 500, err)
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("middleware hook %s failed with invalid status code: %d", string(hook), resp.StatusCode)
 	}
 
 	dec := json.NewDecoder(resp.Body)
 
-	var hookRes MiddlewareHookResponse
-	err = dec.Decode(&hookRes)
+	hookRes = &MiddlewareHookResponse{}
+	err = dec.Decode(hookRes)
 	if err != nil {
 		return nil, fmt.Errorf("response of middleware hook %s could not be decoded: %w", string(hook), err)
 	}
-	if hookRes.Error != "" {
-		return nil, fmt.Errorf("middleware hook %s failed with error: %s", string(hook), hookRes.Error)
-	}
 
-	return &hookRes, nil
+	return hookRes, nil
 }
 
 func (c *Client) DoHealthCheckRequest(timeout time.Duration) (status bool) {
-	resp, err := c.httpClient.Get(fmt.Sprintf("%s/health", c.serverUrl))
-	if err != nil || resp.StatusCode != 200 {
-		return
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.HealthCheck(ctx) == nil
+}
 
-	return true
+// HealthCheck requests the health endpoint of the hooks server once, without retries, until ctx is done
+func (c *Client) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/health", c.serverUrl), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Transport: c.transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("hooks server is not healthy, status code %d", resp.StatusCode)
+	}
+	return nil
 }
@@ -0,0 +1,315 @@
+package hooks
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jensneuse/abstractlogger"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestClient_UnixSocketH2C(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "hooks.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+
+	var protocols []string
+	srv := &http.Server{
+		Handler: h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			protocols = append(protocols, r.Proto)
+			assert.Equal(t, "/operation/Users/preResolve", r.URL.Path)
+			_, _ = w.Write([]byte(`{"op":"Users","hook":"preResolve"}`))
+		}), &http2.Server{}),
+	}
+	go func() { _ = srv.Serve(listener) }()
+	defer srv.Close()
+
+	client := NewClient("http://localhost", abstractlogger.NoopLogger, WithUnixSocket(socket), WithH2C())
+	for i := 0; i < 2; i++ {
+		res, err := client.DoOperationRequest(context.Background(), "Users", PreResolve, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "preResolve", res.Hook)
+	}
+	assert.Equal(t, []string{"HTTP/2.0", "HTTP/2.0"}, protocols)
+}
+
+func TestClient_Retries(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	policy := Policy{Timeout: time.Second, MaxRetries: 1}
+	client := NewClient(srv.URL, abstractlogger.NoopLogger, WithDefaultPolicy(policy))
+	client.retryClient(PreResolve).RetryWaitMin = time.Millisecond
+	client.retryClient(PreResolve).RetryWaitMax = time.Millisecond
+
+	_, err := client.DoOperationRequest(context.Background(), "Users", PreResolve, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	// the hooks server received the request, so it must not be sent again
+	atomic.StoreInt32(&attempts, 0)
+	_, err = client.DoOperationRequest(context.Background(), "Users", MutatingPreResolve, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestClient_HealthCheck(t *testing.T) {
+	var attempts int32
+	healthy := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		select {
+		case <-healthy:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, abstractlogger.NoopLogger, WithDefaultPolicy(Policy{Timeout: time.Second, MaxRetries: 3}))
+
+	// the health check is bound to the context, not the policy of the hooks
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, client.HealthCheck(ctx), context.DeadlineExceeded)
+	assert.False(t, client.DoHealthCheckRequest(50*time.Millisecond))
+
+	// failed health checks aren't retried
+	close(healthy)
+	atomic.StoreInt32(&attempts, 0)
+	assert.EqualError(t, client.HealthCheck(context.Background()), "hooks server is not healthy, status code 503")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestClient_Policies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 100)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, abstractlogger.NoopLogger,
+		WithPolicy(PostResolve, Policy{Timeout: time.Millisecond * 10}),
+	)
+
+	_, err := client.DoOperationRequest(context.Background(), "Users", PreResolve, nil)
+	assert.NoError(t, err)
+	_, err = client.DoOperationRequest(context.Background(), "Users", PostResolve, nil)
+	assert.Error(t, err)
+}
+
+func TestRetryUnsentRequests(t *testing.T) {
+	client := NewClient("http://localhost", abstractlogger.NoopLogger,
+		WithUnixSocket(filepath.Join(t.TempDir(), "missing.sock")),
+		WithDefaultPolicy(Policy{Timeout: time.Second, MaxRetries: 1}),
+	)
+	client.retryClient(MutatingPreResolve).RetryWaitMin = time.Millisecond
+	client.retryClient(MutatingPreResolve).RetryWaitMax = time.Millisecond
+
+	var dials int32
+	transport := client.transport.(*http.Transport)
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return dial(ctx, network, addr)
+	}
+
+	_, err := client.DoOperationRequest(context.Background(), "Users", MutatingPreResolve, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&dials))
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	var (
+		attempts int32
+		healthy  int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"response":{"data":{"id":1}}}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, abstractlogger.NoopLogger,
+		WithDefaultPolicy(Policy{Timeout: time.Second}),
+		WithCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 2, OpenTimeout: time.Millisecond * 50}),
+	)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.DoOperationRequest(context.Background(), "Users", PreResolve, nil)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	}
+	_, err := client.DoOperationRequest(context.Background(), "Users", PreResolve, nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.Equal(t, []CircuitBreakerStatus{{Hook: "operation/Users/preResolve", State: CircuitOpen, Failures: 2}}, client.CircuitBreakers())
+
+	// breakers are tracked per hook and operation
+	_, err = client.DoOperationRequest(context.Background(), "Posts", PreResolve, nil)
+	assert.NotErrorIs(t, err, ErrCircuitOpen)
+
+	// once the circuit was open long enough, a single request probes the hooks server
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(time.Millisecond * 60)
+	res, err := client.DoOperationRequest(context.Background(), "Users", PreResolve, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"data":{"id":1}}`, string(res.Response))
+	assert.Equal(t, CircuitClosed, client.CircuitBreakers()[1].State)
+}
+
+func TestClient_FailurePolicies(t *testing.T) {
+	var healthy int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"response":{"data":{"id":1}}}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, abstractlogger.NoopLogger,
+		WithDefaultPolicy(Policy{Timeout: time.Second}),
+		WithPolicy(MutatingPreResolve, Policy{Timeout: time.Second, OnFailure: FailSkip}),
+		WithPolicy(CustomResolve, Policy{Timeout: time.Second, OnFailure: FailLastGood}),
+		WithPolicy(MockResolve, Policy{Timeout: time.Second, OnFailure: FailSkip}),
+		WithPolicy(PostAuthentication, Policy{Timeout: time.Second, OnFailure: FailLastGood}),
+	)
+
+	_, err := client.DoOperationRequest(context.Background(), "Users", CustomResolve, []byte(`{"__wg":{"user":{"userId":"1"}}}`))
+	assert.NoError(t, err)
+	_, err = client.DoAuthenticationRequest(context.Background(), PostAuthentication, []byte(`{"__wg":{"user":{"userId":"1"}}}`))
+	assert.NoError(t, err)
+
+	atomic.StoreInt32(&healthy, 0)
+
+	_, err = client.DoOperationRequest(context.Background(), "Users", PreResolve, nil)
+	assert.Error(t, err)
+
+	res, err := client.DoOperationRequest(context.Background(), "Users", MutatingPreResolve, []byte(`{"input":{"id":2}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":2}`, string(res.Input))
+
+	res, err = client.DoOperationRequest(context.Background(), "Users", CustomResolve, []byte(`{"__wg":{"user":{"userId":"1"}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"data":{"id":1}}`, string(res.Response))
+
+	// there's no last good response of another operation or user
+	_, err = client.DoOperationRequest(context.Background(), "Posts", CustomResolve, []byte(`{"__wg":{"user":{"userId":"1"}}}`))
+	assert.Error(t, err)
+	_, err = client.DoOperationRequest(context.Background(), "Users", CustomResolve, []byte(`{"__wg":{"user":{"userId":"2"}}}`))
+	assert.Error(t, err)
+
+	// responses of authentication hooks are never reused
+	_, err = client.DoAuthenticationRequest(context.Background(), PostAuthentication, []byte(`{"__wg":{"user":{"userId":"1"}}}`))
+	assert.Error(t, err)
+
+	// skipping mockResolve would leave the client without a response
+	_, err = client.DoOperationRequest(context.Background(), "Users", MockResolve, nil)
+	assert.Error(t, err)
+}
+
+func TestClient_DefaultSkipPolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, abstractlogger.NoopLogger,
+		WithDefaultPolicy(Policy{Timeout: time.Second, OnFailure: FailSkip}),
+	)
+
+	_, err := client.DoOperationRequest(context.Background(), "Users", PostResolve, nil)
+	assert.NoError(t, err)
+
+	// hooks deciding about access fail closed, even if the default policy skips failed hooks
+	for _, hook := range []MiddlewareHook{PostAuthentication, MutatingPostAuthentication, RevalidateAuthentication} {
+		_, err = client.DoAuthenticationRequest(context.Background(), hook, []byte(`{"__wg":{"user":{"userId":"1"}},"user":{"userId":"1"}}`))
+		assert.Error(t, err, hook)
+	}
+	_, err = client.DoOperationRequest(context.Background(), "Users", OnSubscriptionStart, nil)
+	assert.Error(t, err)
+}
+
+func TestSkipResponse(t *testing.T) {
+	res := skipResponse(CustomResolve, nil)
+	assert.Equal(t, `null`, string(res.Response))
+
+	res = skipResponse(MutatingPostResolve, []byte(`{"response":{"data":{"id":1}}}`))
+	assert.Equal(t, `{"data":{"id":1}}`, string(res.Response))
+
+	res = skipResponse(HttpTransportOnRequest, []byte(`{"request":{}}`))
+	assert.Equal(t, `{"skip":true}`, string(res.Response))
+
+	assert.Nil(t, skipResponse(MockResolve, nil))
+}
+
+type goHooks struct {
+	UnimplementedInProcessHooks
+	payloads []string
+}
+
+func (h *goHooks) MutatingPostResolve(ctx context.Context, operationName string, payload []byte) (*MiddlewareHookResponse, error) {
+	h.payloads = append(h.payloads, operationName+" "+string(payload))
+	return &MiddlewareHookResponse{Op: operationName, Hook: string(MutatingPostResolve), Response: []byte(`{"data":{"id":2}}`)}, nil
+}
+
+func (h *goHooks) OnOriginRequest(ctx context.Context, payload []byte) (*MiddlewareHookResponse, error) {
+	panic("unexpected request")
+}
+
+func TestClient_InProcessHooks(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = w.Write([]byte(`{"response":{}}`))
+	}))
+	defer srv.Close()
+
+	h := &goHooks{}
+	client := NewClient(srv.URL, abstractlogger.NoopLogger, WithInProcessHooks(h))
+
+	res, err := client.DoOperationRequest(context.Background(), "Users", MutatingPostResolve, []byte(`{"response":{"data":{"id":1}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"data":{"id":2}}`, string(res.Response))
+	assert.Equal(t, []string{`Users {"response":{"data":{"id":1}}}`}, h.payloads)
+
+	// hooks which aren't implemented in Go are sent to the hooks server
+	_, err = client.DoOperationRequest(context.Background(), "Users", PreResolve, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/operation/Users/preResolve"}, paths)
+
+	_, err = client.DoGlobalRequest(context.Background(), HttpTransportOnRequest, nil)
+	assert.ErrorContains(t, err, "panicked: unexpected request")
+}
+
+func TestValidatePolicy(t *testing.T) {
+	assert.NoError(t, ValidatePolicy(CustomResolve, Policy{OnFailure: FailLastGood}))
+	assert.NoError(t, ValidatePolicy(MutatingPostResolve, Policy{OnFailure: FailSkip}))
+	assert.Error(t, ValidatePolicy(MutatingPostResolve, Policy{OnFailure: FailLastGood}))
+	assert.Error(t, ValidatePolicy(HttpTransportOnRequest, Policy{OnFailure: FailLastGood}))
+	assert.Error(t, ValidatePolicy(RevalidateAuthentication, Policy{OnFailure: FailLastGood}))
+	assert.Error(t, ValidatePolicy(PostAuthentication, Policy{OnFailure: FailSkip}))
+	assert.Error(t, ValidatePolicy(MutatingPostAuthentication, Policy{OnFailure: FailSkip}))
+	assert.Error(t, ValidatePolicy(RevalidateAuthentication, Policy{OnFailure: FailSkip}))
+	assert.Error(t, ValidatePolicy(OnSubscriptionStart, Policy{OnFailure: FailSkip}))
+	assert.NoError(t, ValidatePolicy(OnSubscriptionEnd, Policy{OnFailure: FailSkip}))
+	assert.EqualError(t, ValidatePolicy(MiddlewareHook("preResolv"), Policy{}), `unknown hook "preResolv"`)
+}
@@ -0,0 +1,205 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrHookNotImplemented is returned by in-process hooks which should be sent to the hooks server instead
+var ErrHookNotImplemented = errors.New("hook not implemented")
+
+// InProcessHooks implements middleware hooks in Go, so they are called directly instead of through the hooks server.
+// Payloads and responses are the JSON documents the hooks server receives and returns,
+// e.g. the payload of operation hooks carries the user in "__wg", the variables in "input" and the response in "response".
+// Embed UnimplementedInProcessHooks to implement only some of the hooks,
+// all others are sent to the hooks server.
+// Hooks of requests to the GraphQL endpoint are always sent to the hooks server.
+type InProcessHooks interface {
+	MockResolve(ctx context.Context, operationName string, payload []byte) (*MiddlewareHookResponse, error)
+	PreResolve(ctx context.Context, operationName string, payload []byte) (*MiddlewareHookResponse, error)
+	PostResolve(ctx context.Context, operationName string, payload []byte) (*MiddlewareHookResponse, error)
+	CustomResolve(ctx context.Context, operationName string, payload []byte) (*MiddlewareHookResponse, error)
+	MutatingPreResolve(ctx context.Context, operationName string, payload []byte) (*MiddlewareHookResponse, error)
+	MutatingPostResolve(ctx context.Context, operationName string, payload []byte) (*MiddlewareHookResponse, error)
+	OnSubscriptionStart(ctx context.Context, operationName string, payload []byte) (*MiddlewareHookResponse, error)
+	OnSubscriptionEnd(ctx context.Context, operationName string, payload []byte) (*MiddlewareHookResponse, error)
+	OnSubscriptionMessageFilter(ctx context.Context, operationName string, payload []byte) (*MiddlewareHookResponse, error)
+
+	PostAuthentication(ctx context.Context, payload []byte) (*MiddlewareHookResponse, error)
+	PostLogout(ctx context.Context, payload []byte) (*MiddlewareHookResponse, error)
+	MutatingPostAuthentication(ctx context.Context, payload []byte) (*MiddlewareHookResponse, error)
+	RevalidateAuthentication(ctx context.Context, payload []byte) (*MiddlewareHookResponse, error)
+
+	OnOriginRequest(ctx context.Context, payload []byte) (*MiddlewareHookResponse, error)
+	OnOriginResponse(ctx context.Context, payload []byte) (*MiddlewareHookResponse, error)
+	OnConnectionInit(ctx context.Context, payload []byte) (*MiddlewareHookResponse, error)
+}
+
+// UnimplementedInProcessHooks sends all hooks to the hooks server
+type UnimplementedInProcessHooks struct{}
+
+func (UnimplementedInProcessHooks) MockResolve(context.Context, string, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) PreResolve(context.Context, string, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) PostResolve(context.Context, string, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) CustomResolve(context.Context, string, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) MutatingPreResolve(context.Context, string, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) MutatingPostResolve(context.Context, string, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) OnSubscriptionStart(context.Context, string, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) OnSubscriptionEnd(context.Context, string, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) OnSubscriptionMessageFilter(context.Context, string, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) PostAuthentication(context.Context, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) PostLogout(context.Context, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) MutatingPostAuthentication(context.Context, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) RevalidateAuthentication(context.Context, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) OnOriginRequest(context.Context, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) OnOriginResponse(context.Context, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+func (UnimplementedInProcessHooks) OnConnectionInit(context.Context, []byte) (*MiddlewareHookResponse, error) {
+	return nil, ErrHookNotImplemented
+}
+
+// WithInProcessHooks calls the hooks implemented by h directly.
+// It can be used multiple times, the first in-process hooks implementing a hook handle it.
+func WithInProcessHooks(h InProcessHooks) Option {
+	return func(client *Client) {
+		client.inProcess = append(client.inProcess, h)
+	}
+}
+
+// inProcessRequest calls the in-process hook, it returns ErrHookNotImplemented if the hook should be sent to the hooks server
+func (c *Client) inProcessRequest(ctx context.Context, action string, hook MiddlewareHook, jsonData []byte) (*MiddlewareHookResponse, error) {
+	start := time.Now()
+	hookRes, err := c.callInProcess(ctx, action, hook, c.setInternalHookData(ctx, jsonData))
+	if errors.Is(err, ErrHookNotImplemented) {
+		return nil, err
+	}
+	if err == nil && hookRes == nil {
+		err = fmt.Errorf("in-process middleware hook %s returned no response", string(hook))
+	}
+	c.metrics.ObserveHook(action, string(hook), err, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	if hookRes.Error != "" {
+		return nil, fmt.Errorf("middleware hook %s failed with error: %s", string(hook), hookRes.Error)
+	}
+	return hookRes, nil
+}
+
+// callInProcess dispatches the hook to the first in-process hooks implementing it
+func (c *Client) callInProcess(ctx context.Context, action string, hook MiddlewareHook, payload []byte) (*MiddlewareHookResponse, error) {
+	if !strings.HasPrefix(action, "operation/") && isOperationHook(hook) {
+		return nil, ErrHookNotImplemented
+	}
+	operationName := strings.TrimPrefix(action, "operation/")
+	for _, h := range c.inProcess {
+		hookRes, err := dispatchInProcess(ctx, h, operationName, hook, payload)
+		if !errors.Is(err, ErrHookNotImplemented) {
+			return hookRes, err
+		}
+	}
+	return nil, ErrHookNotImplemented
+}
+
+func isOperationHook(hook MiddlewareHook) bool {
+	switch hook {
+	case MockResolve, PreResolve, PostResolve, CustomResolve, MutatingPreResolve, MutatingPostResolve,
+		OnSubscriptionStart, OnSubscriptionEnd, OnSubscriptionMessageFilter:
+		return true
+	default:
+		return false
+	}
+}
+
+// dispatchInProcess calls the hook of h, panics are returned as errors
+func dispatchInProcess(ctx context.Context, h InProcessHooks, operationName string, hook MiddlewareHook, payload []byte) (hookRes *MiddlewareHookResponse, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			hookRes, err = nil, fmt.Errorf("in-process middleware hook %s panicked: %v", string(hook), recovered)
+		}
+	}()
+
+	switch hook {
+	case MockResolve:
+		return h.MockResolve(ctx, operationName, payload)
+	case PreResolve:
+		return h.PreResolve(ctx, operationName, payload)
+	case PostResolve:
+		return h.PostResolve(ctx, operationName, payload)
+	case CustomResolve:
+		return h.CustomResolve(ctx, operationName, payload)
+	case MutatingPreResolve:
+		return h.MutatingPreResolve(ctx, operationName, payload)
+	case MutatingPostResolve:
+		return h.MutatingPostResolve(ctx, operationName, payload)
+	case OnSubscriptionStart:
+		return h.OnSubscriptionStart(ctx, operationName, payload)
+	case OnSubscriptionEnd:
+		return h.OnSubscriptionEnd(ctx, operationName, payload)
+	case OnSubscriptionMessageFilter:
+		return h.OnSubscriptionMessageFilter(ctx, operationName, payload)
+	case PostAuthentication:
+		return h.PostAuthentication(ctx, payload)
+	case PostLogout:
+		return h.PostLogout(ctx, payload)
+	case MutatingPostAuthentication:
+		return h.MutatingPostAuthentication(ctx, payload)
+	case RevalidateAuthentication:
+		return h.RevalidateAuthentication(ctx, payload)
+	case HttpTransportOnRequest:
+		return h.OnOriginRequest(ctx, payload)
+	case HttpTransportOnResponse:
+		return h.OnOriginResponse(ctx, payload)
+	case WsTransportOnConnectionInit:
+		return h.OnConnectionInit(ctx, payload)
+	default:
+		return nil, ErrHookNotImplemented
+	}
+}
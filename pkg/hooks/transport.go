@@ -0,0 +1,180 @@
+package hooks
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/jensneuse/abstractlogger"
+	"golang.org/x/net/http2"
+)
+
+// Policy controls timeouts and retries of hook requests
+type Policy struct {
+	// Timeout limits each attempt
+	Timeout time.Duration
+	// MaxRetries of failed requests. Non-idempotent hooks are only retried
+	// if the request didn't reach the hooks server.
+	MaxRetries int
+	OnFailure  FailurePolicy
+}
+
+// DefaultPolicy applies to all hooks without a policy of their own
+var DefaultPolicy = Policy{
+	Timeout:    time.Minute,
+	MaxRetries: 3,
+}
+
+// idempotentHooks neither change the request nor the response, so sending them twice is safe.
+// All other hooks, e.g. mutatingPreResolve, might have side effects and are not retried once sent.
+var idempotentHooks = map[MiddlewareHook]bool{
+	MockResolve:                 true,
+	PreResolve:                  true,
+	PostResolve:                 true,
+	RevalidateAuthentication:    true,
+	OnSubscriptionMessageFilter: true,
+}
+
+// WithUnixSocket connects to the hooks server through the Unix domain socket at path.
+// The host of the server url is ignored.
+func WithUnixSocket(path string) Option {
+	return func(client *Client) {
+		client.unixSocket = path
+	}
+}
+
+// WithH2C multiplexes all hook requests over a persistent cleartext HTTP/2 connection.
+// The hooks server must accept HTTP/2 with prior knowledge.
+func WithH2C() Option {
+	return func(client *Client) {
+		client.h2c = true
+	}
+}
+
+// WithDefaultPolicy replaces DefaultPolicy for all hooks without a policy of their own
+func WithDefaultPolicy(policy Policy) Option {
+	return func(client *Client) {
+		client.defaultPolicy = policy
+	}
+}
+
+// WithPolicy sets the policy of a single hook
+func WithPolicy(hook MiddlewareHook, policy Policy) Option {
+	return func(client *Client) {
+		client.policies[hook] = policy
+	}
+}
+
+type retryClientKey struct {
+	timeout    time.Duration
+	maxRetries int
+	idempotent bool
+}
+
+func newRetryClientKey(policy Policy, idempotent bool) retryClientKey {
+	return retryClientKey{timeout: policy.Timeout, maxRetries: policy.MaxRetries, idempotent: idempotent}
+}
+
+func (c *Client) policy(hook MiddlewareHook) Policy {
+	if policy, ok := c.policies[hook]; ok {
+		return policy
+	}
+	return c.defaultPolicy
+}
+
+// retryClient returns the client sending requests of the hook according to its policy
+func (c *Client) retryClient(hook MiddlewareHook) *retryablehttp.Client {
+	return c.retryClients[newRetryClientKey(c.policy(hook), idempotentHooks[hook])]
+}
+
+func (c *Client) newRetryClients() {
+	c.retryClients = map[retryClientKey]*retryablehttp.Client{}
+	policies := []Policy{c.defaultPolicy}
+	for _, policy := range c.policies {
+		policies = append(policies, policy)
+	}
+	for _, policy := range policies {
+		for _, idempotent := range []bool{true, false} {
+			key := newRetryClientKey(policy, idempotent)
+			if _, ok := c.retryClients[key]; !ok {
+				c.retryClients[key] = c.newRetryClient(policy, idempotent)
+			}
+		}
+	}
+}
+
+func (c *Client) newRetryClient(policy Policy, idempotent bool) *retryablehttp.Client {
+	httpClient := retryablehttp.NewClient()
+	// the backoff doubles from RetryWaitMin up to RetryWaitMax between attempts
+	// INFO: retryablehttp also handles retry-after headers
+	httpClient.RetryMax = policy.MaxRetries
+	httpClient.RetryWaitMin = time.Second * 1
+	httpClient.RetryWaitMax = time.Second * 30
+	httpClient.HTTPClient.Timeout = policy.Timeout
+	if c.transport != nil {
+		httpClient.HTTPClient.Transport = c.transport
+	}
+	if !idempotent {
+		httpClient.CheckRetry = retryUnsentRequests
+	}
+	httpClient.Logger = log.New(ioutil.Discard, "", log.LstdFlags)
+	httpClient.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
+		c.log.Debug("hook request call", abstractlogger.Int("attempt", attempt), abstractlogger.String("url", req.URL.String()))
+	}
+	return httpClient
+}
+
+// retryUnsentRequests only retries requests which failed to connect to the hooks server
+func retryUnsentRequests(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// newTransport returns nil if the default transport of the retry clients should be used
+func newTransport(unixSocket string, h2c bool) http.RoundTripper {
+	if unixSocket == "" && !h2c {
+		return nil
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 90 * time.Second,
+	}
+	dial := dialer.DialContext
+	if unixSocket != "" {
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", unixSocket)
+		}
+	}
+
+	if h2c {
+		return &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+			// pings detect broken connections, so they aren't reused for all subsequent hooks
+			ReadIdleTimeout: 30 * time.Second,
+			PingTimeout:     10 * time.Second,
+		}
+	}
+
+	return &http.Transport{
+		DialContext:         dial,
+		MaxIdleConns:        1024,
+		MaxIdleConnsPerHost: 1024,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
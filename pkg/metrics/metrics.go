@@ -0,0 +1,216 @@
+// Package metrics implements Prometheus instrumentation for the WunderNode.
+//
+// A nil *Metrics is valid and records nothing, so components can be
+// instrumented unconditionally and metrics collection can be turned off
+// by simply not creating a Metrics instance.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/wundergraph/wundergraph/internal/statusrecorder"
+)
+
+const (
+	namespace = "wundergraph"
+
+	StatusSuccess = "success"
+	StatusError   = "error"
+
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+
+	// maxClientOperationLabels is the number of distinct operation names chosen by clients recorded as labels
+	maxClientOperationLabels = 100
+)
+
+type Metrics struct {
+	registry            *prometheus.Registry
+	operationDuration   *prometheus.HistogramVec
+	hookDuration        *prometheus.HistogramVec
+	dataSourceDuration  *prometheus.HistogramVec
+	cacheRequests       *prometheus.CounterVec
+	activeSubscriptions *prometheus.GaugeVec
+
+	// tenant labels the operation metrics of an API of a node serving multiple APIs, see WithTenant
+	tenant           string
+	clientOperations *clientOperations
+}
+
+// clientOperations are the operation names chosen by clients which are recorded as labels, shared by all tenants
+type clientOperations struct {
+	mux   sync.Mutex
+	names map[string]struct{}
+}
+
+// New creates a Metrics instance backed by its own registry, which also
+// exposes the default Go runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "node",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of client requests to operations, by tenant, operation name, type and HTTP status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"tenant", "operation", "type", "status"}),
+		hookDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "node",
+			Name:      "hook_duration_seconds",
+			Help:      "Duration of requests to the hooks server, by tenant, action, hook and outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"tenant", "action", "hook", "status"}),
+		dataSourceDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "node",
+			Name:      "datasource_request_duration_seconds",
+			Help:      "Duration of upstream requests, by data source host and HTTP status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"datasource", "status"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "node",
+			Name:      "cache_requests_total",
+			Help:      "Number of operation cache lookups, by tenant, operation name and result (hit or miss).",
+		}, []string{"tenant", "operation", "result"}),
+		activeSubscriptions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "node",
+			Name:      "active_subscriptions",
+			Help:      "Number of currently open subscriptions and live queries, by tenant and operation name.",
+		}, []string{"tenant", "operation"}),
+		clientOperations: &clientOperations{
+			names: map[string]struct{}{},
+		},
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.operationDuration,
+		m.hookDuration,
+		m.dataSourceDuration,
+		m.cacheRequests,
+		m.activeSubscriptions,
+	)
+
+	return m
+}
+
+// WithTenant returns a Metrics instance recording the operation metrics of the API tenant, e.g. its path prefix.
+// It shares the registry with m. The tenant label is empty unless the node serves multiple APIs.
+func (m *Metrics) WithTenant(tenant string) *Metrics {
+	if m == nil {
+		return nil
+	}
+	withTenant := *m
+	withTenant.tenant = tenant
+	return &withTenant
+}
+
+// Handler returns the http.Handler serving the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveOperation(operationName, operationType string, statusCode int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.operationDuration.WithLabelValues(m.tenant, operationName, operationType, strconv.Itoa(statusCode)).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ObserveHook(action, hook string, err error, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	status := StatusSuccess
+	if err != nil {
+		status = StatusError
+	}
+	m.hookDuration.WithLabelValues(m.tenant, action, hook, status).Observe(duration.Seconds())
+}
+
+// ObserveDataSource records an upstream request. If err is not nil, the status is recorded as "error".
+func (m *Metrics) ObserveDataSource(host string, statusCode int, err error, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	status := StatusError
+	if err == nil {
+		status = strconv.Itoa(statusCode)
+	}
+	m.dataSourceDuration.WithLabelValues(host, status).Observe(duration.Seconds())
+}
+
+func (m *Metrics) CacheHit(operationName string) {
+	if m == nil {
+		return
+	}
+	m.cacheRequests.WithLabelValues(m.tenant, operationName, cacheHit).Inc()
+}
+
+func (m *Metrics) CacheMiss(operationName string) {
+	if m == nil {
+		return
+	}
+	m.cacheRequests.WithLabelValues(m.tenant, operationName, cacheMiss).Inc()
+}
+
+func (m *Metrics) SubscriptionStarted(operationName string) {
+	if m == nil {
+		return
+	}
+	m.activeSubscriptions.WithLabelValues(m.tenant, operationName).Inc()
+}
+
+func (m *Metrics) SubscriptionEnded(operationName string) {
+	if m == nil {
+		return
+	}
+	m.activeSubscriptions.WithLabelValues(m.tenant, operationName).Dec()
+}
+
+// ClientOperationLabel returns the label of an operation named by the client, e.g. on the GraphQL endpoint.
+// Unnamed operations and, once maxClientOperationLabels distinct names have been recorded,
+// all operations with new names are labeled fallback, so clients can't create unbounded time series.
+func (m *Metrics) ClientOperationLabel(operationName, fallback string) string {
+	if m == nil || operationName == "" {
+		return fallback
+	}
+	m.clientOperations.mux.Lock()
+	defer m.clientOperations.mux.Unlock()
+	if _, ok := m.clientOperations.names[operationName]; ok {
+		return operationName
+	}
+	if len(m.clientOperations.names) >= maxClientOperationLabels {
+		return fallback
+	}
+	m.clientOperations.names[operationName] = struct{}{}
+	return operationName
+}
+
+// InstrumentOperation wraps handler to record the duration and status code of each request
+func (m *Metrics) InstrumentOperation(operationName, operationType string, handler http.Handler) http.Handler {
+	if m == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := statusrecorder.New(w)
+		handler.ServeHTTP(recorder, r)
+		m.ObserveOperation(operationName, operationType, recorder.StatusCode(), time.Since(start))
+	})
+}
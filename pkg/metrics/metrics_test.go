@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_InstrumentOperation(t *testing.T) {
+	m := New()
+
+	handler := m.InstrumentOperation("Weather", "query", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(http.Flusher)
+		assert.True(t, ok)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/operations/Weather", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/operations/Weather", nil))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(m.operationDuration))
+	expected := `
+# HELP wundergraph_node_cache_requests_total Number of operation cache lookups, by tenant, operation name and result (hit or miss).
+# TYPE wundergraph_node_cache_requests_total counter
+wundergraph_node_cache_requests_total{operation="Weather",result="hit",tenant=""} 2
+wundergraph_node_cache_requests_total{operation="Weather",result="miss",tenant=""} 1
+`
+	m.CacheHit("Weather")
+	m.CacheHit("Weather")
+	m.CacheMiss("Weather")
+	assert.NoError(t, testutil.CollectAndCompare(m.cacheRequests, strings.NewReader(expected)))
+
+	m.SubscriptionStarted("Messages")
+	m.SubscriptionStarted("Messages")
+	m.SubscriptionEnded("Messages")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.activeSubscriptions.WithLabelValues("", "Messages")))
+
+	m.ObserveHook("operation/Weather", "preResolve", nil, time.Millisecond)
+	m.ObserveHook("operation/Weather", "preResolve", errors.New("failed"), time.Millisecond)
+	assert.Equal(t, 2, testutil.CollectAndCount(m.hookDuration))
+
+	m.ObserveDataSource("weather.example.com", http.StatusOK, nil, time.Millisecond)
+	m.ObserveDataSource("weather.example.com", 0, errors.New("connection refused"), time.Millisecond)
+	assert.Equal(t, 2, testutil.CollectAndCount(m.dataSourceDuration))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `wundergraph_node_operation_duration_seconds_count{operation="Weather",status="400",tenant="",type="query"} 2`)
+	assert.Contains(t, rec.Body.String(), `wundergraph_node_datasource_request_duration_seconds_count{datasource="weather.example.com",status="error"} 1`)
+}
+
+func TestMetrics_WithTenant(t *testing.T) {
+	m := New()
+	a, b := m.WithTenant("a"), m.WithTenant("b")
+
+	a.ObserveOperation("Weather", "query", http.StatusOK, time.Millisecond)
+	b.ObserveOperation("Weather", "query", http.StatusOK, time.Millisecond)
+	a.SubscriptionStarted("Messages")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.activeSubscriptions.WithLabelValues("a", "Messages")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.activeSubscriptions.WithLabelValues("b", "Messages")))
+
+	// all tenants are served by the registry of m
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `wundergraph_node_operation_duration_seconds_count{operation="Weather",status="200",tenant="a",type="query"} 1`)
+	assert.Contains(t, rec.Body.String(), `wundergraph_node_operation_duration_seconds_count{operation="Weather",status="200",tenant="b",type="query"} 1`)
+
+	var nilMetrics *Metrics
+	assert.Nil(t, nilMetrics.WithTenant("a"))
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+
+	called := false
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	m.InstrumentOperation("Weather", "query", handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, called)
+
+	assert.NotPanics(t, func() {
+		m.CacheHit("Weather")
+		m.CacheMiss("Weather")
+		m.SubscriptionStarted("Weather")
+		m.SubscriptionEnded("Weather")
+		m.ObserveHook("operation/Weather", "preResolve", nil, time.Millisecond)
+		m.ObserveDataSource("weather.example.com", http.StatusOK, nil, time.Millisecond)
+	})
+}
+
+func TestMetrics_ClientOperationLabel(t *testing.T) {
+	m := New()
+
+	assert.Equal(t, "graphql", m.ClientOperationLabel("", "graphql"))
+	assert.Equal(t, "Users", m.ClientOperationLabel("Users", "graphql"))
+
+	for i := 0; i < maxClientOperationLabels; i++ {
+		m.ClientOperationLabel("Operation"+strconv.Itoa(i), "graphql")
+	}
+	assert.Equal(t, "graphql", m.ClientOperationLabel("Posts", "graphql"))
+	assert.Equal(t, "Users", m.ClientOperationLabel("Users", "graphql"))
+
+	var nilMetrics *Metrics
+	assert.Equal(t, "graphql", nilMetrics.ClientOperationLabel("Users", "graphql"))
+}
@@ -1,5 +1,7 @@
 package node
 
+import "github.com/wundergraph/wundergraph/pkg/hooks"
+
 type BuildInfo struct {
 	Version, Commit, Date, BuiltBy string
 }
@@ -13,4 +15,6 @@ type HealthCheckReport struct {
 	ServerStatus string    `json:"serverStatus"`
 	NodeStatus   string    `json:"nodeStatus"`
 	BuildInfo    BuildInfo `json:"buildInfo"`
+	// HookCircuitBreakers lists the circuit breakers of all hooks called so far
+	HookCircuitBreakers []hooks.CircuitBreakerStatus `json:"hookCircuitBreakers,omitempty"`
 }
@@ -2,21 +2,35 @@ package node
 
 import (
 	"fmt"
+	"net"
 	"path"
+	"strings"
 	"time"
 
+	"google.golang.org/protobuf/proto"
+
 	"github.com/wundergraph/wundergraph/pkg/apihandler"
+	"github.com/wundergraph/wundergraph/pkg/hooks"
 	"github.com/wundergraph/wundergraph/pkg/loadvariable"
 	"github.com/wundergraph/wundergraph/pkg/logging"
+	"github.com/wundergraph/wundergraph/pkg/querylimits"
+	"github.com/wundergraph/wundergraph/pkg/tracing"
 	"github.com/wundergraph/wundergraph/pkg/wgpb"
 )
 
+// Server configures the connections to the node, timeouts are in seconds and disabled if 0
 type Server struct {
 	GracefulShutdownTimeout int64
 	KeepAlive               int64
 	ReadTimeout             int64
+	ReadHeaderTimeout       int64
 	WriteTimeout            int64
 	IdleTimeout             int64
+	MaxHeaderBytes          int
+	// MaxConnections limits the concurrent connections per listener, unlimited if 0
+	MaxConnections int
+	// StreamTimeout replaces ReadTimeout and WriteTimeout for subscriptions and live queries
+	StreamTimeout int64
 }
 
 type WunderNodeConfig struct {
@@ -26,7 +40,9 @@ type WunderNodeConfig struct {
 
 func CreateConfig(graphConfig *wgpb.WunderGraphConfiguration) (WunderNodeConfig, error) {
 	const (
-		defaultTimeout = 10 * time.Second
+		defaultTimeout     = 10 * time.Second
+		defaultMetricsHost = "localhost"
+		defaultMetricsPort = 8881
 	)
 
 	logLevelStr := loadvariable.String(graphConfig.Api.NodeOptions.Logger.Level)
@@ -39,6 +55,28 @@ func CreateConfig(graphConfig *wgpb.WunderGraphConfiguration) (WunderNodeConfig,
 	listener := &apihandler.Listener{
 		Host: loadvariable.String(graphConfig.Api.NodeOptions.Listen.Host),
 		Port: uint16(loadvariable.Int(graphConfig.Api.NodeOptions.Listen.Port)),
+		H2C:  graphConfig.Api.NodeOptions.Listen.GetH2C(),
+	}
+	if tlsOptions := graphConfig.Api.NodeOptions.Listen.GetTls(); tlsOptions != nil {
+		listener.TLS = apihandler.ListenerTLS{
+			CertFile:           loadvariable.String(tlsOptions.CertFile),
+			KeyFile:            loadvariable.String(tlsOptions.KeyFile),
+			ClientCAFile:       loadvariable.String(tlsOptions.ClientCaFile),
+			ClientCertOptional: tlsOptions.ClientCertOptional,
+			DisableHTTP2:       tlsOptions.DisableHttp2,
+		}
+		if acme := tlsOptions.GetAcme(); acme.GetEnabled() {
+			listener.TLS.ACME = apihandler.ListenerACME{
+				Enabled:              true,
+				Email:                loadvariable.String(acme.Email),
+				DirectoryURL:         loadvariable.String(acme.DirectoryUrl),
+				DirectoryCAFile:      loadvariable.String(acme.DirectoryCaFile),
+				Challenge:            acme.Challenge,
+				HTTPChallengeAddress: loadvariable.String(acme.HttpChallengeAddress),
+				Storage:              acme.Storage,
+				StorageDir:           loadvariable.String(acme.StorageDir),
+			}
+		}
 	}
 
 	defaultRequestTimeout := defaultTimeout
@@ -46,6 +84,74 @@ func CreateConfig(graphConfig *wgpb.WunderGraphConfiguration) (WunderNodeConfig,
 		defaultRequestTimeout = time.Duration(graphConfig.Api.NodeOptions.DefaultRequestTimeoutSeconds) * time.Second
 	}
 
+	var metrics apihandler.MetricsOptions
+	if metricsOptions := graphConfig.Api.NodeOptions.Metrics; metricsOptions != nil && metricsOptions.Enabled {
+		metrics.Enabled = true
+		metrics.Listener = &apihandler.Listener{
+			Host: defaultMetricsHost,
+			Port: defaultMetricsPort,
+		}
+		if metricsOptions.Listen != nil {
+			if host := loadvariable.String(metricsOptions.Listen.Host); host != "" {
+				metrics.Listener.Host = host
+			}
+			if port := loadvariable.Int(metricsOptions.Listen.Port); port != 0 {
+				metrics.Listener.Port = uint16(port)
+			}
+		}
+	}
+
+	var tracingOptions tracing.Options
+	if nodeTracing := graphConfig.Api.NodeOptions.Tracing; nodeTracing != nil && nodeTracing.Enabled {
+		tracingOptions.Enabled = true
+		switch nodeTracing.Exporter {
+		case wgpb.TracingExporterKind_OTLP_TRACING_EXPORTER:
+			tracingOptions.Exporter = tracing.ExporterOTLP
+		case wgpb.TracingExporterKind_FILE_TRACING_EXPORTER:
+			tracingOptions.Exporter = tracing.ExporterFile
+		default:
+			return WunderNodeConfig{}, fmt.Errorf("unknown tracing exporter: %s", nodeTracing.Exporter)
+		}
+		tracingOptions.OTLPEndpoint = loadvariable.String(nodeTracing.OtlpEndpoint)
+		tracingOptions.FilePath = loadvariable.String(nodeTracing.FilePath)
+	}
+
+	var rateLimit apihandler.RateLimitOptions
+	if rateLimitOptions := graphConfig.Api.NodeOptions.RateLimit; rateLimitOptions != nil {
+		switch rateLimitOptions.Store {
+		case wgpb.RateLimitStoreKind_IN_MEMORY_RATE_LIMIT_STORE:
+			// the zero value keeps the limits in memory
+		case wgpb.RateLimitStoreKind_REDIS_RATE_LIMIT_STORE:
+			rateLimit.RedisURL = loadvariable.String(rateLimitOptions.RedisUrl)
+			if rateLimit.RedisURL == "" {
+				return WunderNodeConfig{}, fmt.Errorf("redis rate limit store requires a redisUrl")
+			}
+		default:
+			return WunderNodeConfig{}, fmt.Errorf("unknown rate limit store: %s", rateLimitOptions.Store)
+		}
+		rateLimit.TrustedProxies, err = parseTrustedProxies(loadvariable.Strings(rateLimitOptions.TrustedProxies))
+		if err != nil {
+			return WunderNodeConfig{}, err
+		}
+	}
+
+	hooksClientOptions, err := hooksOptions(graphConfig.Api.ServerOptions.Hooks)
+	if err != nil {
+		return WunderNodeConfig{}, err
+	}
+
+	var graphqlLimits querylimits.Limits
+	if limits := graphConfig.GraphQLEndpointLimits; limits != nil {
+		graphqlLimits = querylimits.Limits{
+			MaxDepth:        int(limits.MaxDepth),
+			MaxFields:       int(limits.MaxFields),
+			MaxCost:         int(limits.MaxCost),
+			DefaultListSize: int(limits.DefaultListSize),
+		}
+	}
+
+	cacheConfig := cacheOptions(graphConfig.Api.NodeOptions.Cache)
+
 	config := WunderNodeConfig{
 		Api: &apihandler.Api{
 			PrimaryHost:           fmt.Sprintf("%s:%d", listener.Host, listener.Port),
@@ -54,35 +160,148 @@ func CreateConfig(graphConfig *wgpb.WunderGraphConfiguration) (WunderNodeConfig,
 			EngineConfiguration:   graphConfig.Api.EngineConfiguration,
 			EnableSingleFlight:    true,
 			EnableGraphqlEndpoint: graphConfig.DangerouslyEnableGraphQLEndpoint,
+			GraphQLLimits:         graphqlLimits,
+			GraphQLHooks:          graphConfig.GraphQLEndpointHooks,
+			GraphQLHooksOptional:  graphConfig.AllowGraphQLEndpointOperationsWithoutHooks,
 			Operations:            graphConfig.Api.Operations,
 			CorsConfiguration:     graphConfig.Api.CorsConfiguration,
 			S3UploadConfiguration: graphConfig.Api.S3UploadConfiguration,
-			CacheConfig: &wgpb.ApiCacheConfig{
-				Kind: wgpb.ApiCacheKind_IN_MEMORY_CACHE,
-				InMemoryConfig: &wgpb.InMemoryCacheConfig{
-					MaxSize: 1e9,
-				},
-			},
-			AuthenticationConfig: graphConfig.Api.AuthenticationConfig,
-			Webhooks:             graphConfig.Api.Webhooks,
+			CacheConfig:           cacheConfig,
+			AuthenticationConfig:  graphConfig.Api.AuthenticationConfig,
+			Webhooks:              graphConfig.Api.Webhooks,
 			Options: &apihandler.Options{
 				ServerUrl:     loadvariable.String(graphConfig.Api.ServerOptions.ServerUrl),
+				Hooks:         hooksClientOptions,
 				PublicNodeUrl: loadvariable.String(graphConfig.Api.NodeOptions.PublicNodeUrl),
 				Listener:      listener,
 				Logging: apihandler.Logging{
 					Level: logLevel,
 				},
-				DefaultTimeout: defaultRequestTimeout,
+				DefaultTimeout:  defaultRequestTimeout,
+				Metrics:         metrics,
+				Tracing:         tracingOptions,
+				RateLimit:       rateLimit,
+				CachePurgeToken: loadvariable.String(graphConfig.Api.NodeOptions.CachePurgeToken),
 			},
+			GraphQLMaxBatchSize:     int(graphConfig.GraphQLEndpointLimits.GetMaxBatchSize()),
+			GraphQLBatchConcurrency: int(graphConfig.GraphQLEndpointLimits.GetBatchConcurrency()),
 		},
-		Server: &Server{
-			GracefulShutdownTimeout: 0,
-			KeepAlive:               5,
-			ReadTimeout:             5,
-			WriteTimeout:            5,
-			IdleTimeout:             10,
-		},
+		Server: serverOptions(graphConfig.Api.NodeOptions.Server),
 	}
 
 	return config, nil
 }
+
+func serverOptions(config *wgpb.NodeServerOptions) *Server {
+	const (
+		defaultReadTimeout       = 10
+		defaultReadHeaderTimeout = 5
+		defaultWriteTimeout      = 60
+		defaultIdleTimeout       = 120
+		defaultKeepAlive         = 90
+		defaultMaxHeaderBytes    = 1 << 20
+	)
+	orDefault := func(value, defaultValue int64) int64 {
+		if value > 0 {
+			return value
+		}
+		return defaultValue
+	}
+	return &Server{
+		KeepAlive:         orDefault(config.GetKeepAliveSeconds(), defaultKeepAlive),
+		ReadTimeout:       orDefault(config.GetReadTimeoutSeconds(), defaultReadTimeout),
+		ReadHeaderTimeout: orDefault(config.GetReadHeaderTimeoutSeconds(), defaultReadHeaderTimeout),
+		WriteTimeout:      orDefault(config.GetWriteTimeoutSeconds(), defaultWriteTimeout),
+		IdleTimeout:       orDefault(config.GetIdleTimeoutSeconds(), defaultIdleTimeout),
+		MaxHeaderBytes:    int(orDefault(config.GetMaxHeaderBytes(), defaultMaxHeaderBytes)),
+		MaxConnections:    int(config.GetMaxConnections()),
+		StreamTimeout:     config.GetStreamTimeoutSeconds(),
+	}
+}
+
+// parseTrustedProxies parses IPs and CIDR ranges, an IP is a range containing only itself
+func parseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	proxies := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if strings.Contains(value, "/") {
+			_, ipNet, err := net.ParseCIDR(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			proxies = append(proxies, ipNet)
+			continue
+		}
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", value)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return proxies, nil
+}
+
+// hooksOptions returns an error if a policy names an unknown hook or can't apply to one of its hooks
+func hooksOptions(config *wgpb.HooksClientOptions) (apihandler.HooksOptions, error) {
+	options := apihandler.HooksOptions{
+		UnixSocket: loadvariable.String(config.GetUnixSocket()),
+		H2C:        config.GetH2C(),
+		Policies:   map[hooks.MiddlewareHook]hooks.Policy{},
+	}
+	if config.GetDefaultPolicy() != nil {
+		policy := hookPolicy(config.DefaultPolicy)
+		options.DefaultPolicy = &policy
+	}
+	if breaker := config.GetCircuitBreaker(); breaker != nil {
+		options.CircuitBreaker = &hooks.CircuitBreakerOptions{
+			Disabled:         breaker.Disabled,
+			FailureThreshold: int(breaker.FailureThreshold),
+			OpenTimeout:      time.Duration(breaker.OpenSeconds) * time.Second,
+		}
+	}
+	for _, policy := range config.GetPolicies() {
+		for _, hook := range policy.Hooks {
+			requestPolicy := hookPolicy(policy)
+			if err := hooks.ValidatePolicy(hooks.MiddlewareHook(hook), requestPolicy); err != nil {
+				return apihandler.HooksOptions{}, fmt.Errorf("invalid hook policy: %w", err)
+			}
+			options.Policies[hooks.MiddlewareHook(hook)] = requestPolicy
+		}
+	}
+	return options, nil
+}
+
+func hookPolicy(policy *wgpb.HookRequestPolicy) hooks.Policy {
+	hookPolicy := hooks.Policy{
+		Timeout:    time.Duration(policy.TimeoutSeconds) * time.Second,
+		MaxRetries: int(policy.MaxRetries),
+	}
+	switch policy.OnFailure {
+	case wgpb.HookFailurePolicy_SKIP_HOOK_FAILURE:
+		hookPolicy.OnFailure = hooks.FailSkip
+	case wgpb.HookFailurePolicy_LAST_GOOD_HOOK_FAILURE:
+		hookPolicy.OnFailure = hooks.FailLastGood
+	}
+	return hookPolicy
+}
+
+// cacheOptions defaults to an in-memory cache of 1 GB
+func cacheOptions(cacheConfig *wgpb.ApiCacheConfig) *wgpb.ApiCacheConfig {
+	if cacheConfig == nil {
+		cacheConfig = &wgpb.ApiCacheConfig{
+			Kind: wgpb.ApiCacheKind_IN_MEMORY_CACHE,
+		}
+	}
+	if cacheConfig.Kind == wgpb.ApiCacheKind_IN_MEMORY_CACHE && cacheConfig.InMemoryConfig.GetMaxSize() <= 0 {
+		// cacheConfig belongs to the caller, the default is applied to a copy
+		cacheConfig = proto.Clone(cacheConfig).(*wgpb.ApiCacheConfig)
+		if cacheConfig.InMemoryConfig == nil {
+			cacheConfig.InMemoryConfig = &wgpb.InMemoryCacheConfig{}
+		}
+		cacheConfig.InMemoryConfig.MaxSize = 1e9
+	}
+	return cacheConfig
+}
@@ -0,0 +1,172 @@
+package node
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/wundergraph/wundergraph/pkg/apihandler"
+	"github.com/wundergraph/wundergraph/pkg/healthcheck"
+	"github.com/wundergraph/wundergraph/pkg/wgpb"
+)
+
+// generation serves the requests of a single config.
+// It's retired once a new config has been swapped in and all of its requests finished.
+type generation struct {
+	handler http.Handler
+	// operations by the path they are served on
+	operations map[string]*wgpb.Operation
+	// engineConfiguration contains the data sources all operations are resolved with
+	engineConfiguration *wgpb.EngineConfiguration
+	// close releases the resources of the generation, e.g. open streams
+	close func()
+	// health reports the health of the hooks server of the config
+	health func() (*HealthCheckReport, bool)
+	// ready probes the dependencies of the config
+	ready func(ctx context.Context) (*healthcheck.Report, bool)
+	// hosts are the allowed host names of the API, certificates are obtained for them with ACME
+	hosts []string
+
+	inflight sync.WaitGroup
+	mu       sync.Mutex
+	requests map[*http.Request]context.CancelFunc
+}
+
+func newGeneration(handler http.Handler, pathPrefix string, operations []*wgpb.Operation, engineConfiguration *wgpb.EngineConfiguration, close func()) *generation {
+	g := &generation{
+		handler:             handler,
+		operations:          make(map[string]*wgpb.Operation, len(operations)),
+		engineConfiguration: engineConfiguration,
+		close:               close,
+		requests:            map[*http.Request]context.CancelFunc{},
+	}
+	for _, operation := range operations {
+		g.operations[operationPath(pathPrefix, operation.Name)] = operation
+	}
+	return g
+}
+
+func operationPath(pathPrefix, operationName string) string {
+	return path.Join("/", pathPrefix, "operations", operationName)
+}
+
+func (g *generation) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer g.inflight.Done()
+
+	// only operations can be canceled by a reload, all other requests just drain
+	if _, ok := g.operations[r.URL.Path]; !ok {
+		g.handler.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	g.mu.Lock()
+	g.requests[r] = cancel
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		delete(g.requests, r)
+		g.mu.Unlock()
+	}()
+
+	g.handler.ServeHTTP(w, r)
+}
+
+// cancelChanged cancels the requests of operations which next removes or serves differently,
+// requests of unchanged operations, e.g. long-running subscriptions, keep running.
+// If the engine configuration changed, e.g. a data source, all operations are served differently.
+func (g *generation) cancelChanged(next *generation) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	engineChanged := !proto.Equal(g.engineConfiguration, next.engineConfiguration)
+	canceled := 0
+	for r, cancel := range g.requests {
+		nextOperation, ok := next.operations[r.URL.Path]
+		if ok && !engineChanged && proto.Equal(g.operations[r.URL.Path], nextOperation) {
+			continue
+		}
+		cancel()
+		canceled++
+	}
+	return canceled
+}
+
+// retire waits for all requests to finish and releases the generation
+func (g *generation) retire() {
+	g.inflight.Wait()
+	if g.close != nil {
+		g.close()
+	}
+}
+
+// isStream reports whether r subscribes to a subscription or a live query
+func (g *generation) isStream(r *http.Request) bool {
+	operation, ok := g.operations[r.URL.Path]
+	if !ok {
+		return false
+	}
+	return operation.OperationType == wgpb.OperationType_SUBSCRIPTION || r.URL.Query().Get(apihandler.WG_LIVE) == "true"
+}
+
+// handlerSwitch serves requests with the current generation and swaps generations atomically,
+// so config updates never close the listeners
+type handlerSwitch struct {
+	mu      sync.RWMutex
+	current *generation
+}
+
+func newHandlerSwitch(initial *generation) *handlerSwitch {
+	return &handlerSwitch{
+		current: initial,
+	}
+}
+
+func (s *handlerSwitch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.acquire().ServeHTTP(w, r)
+}
+
+// acquire registers a request with the current generation, which releases it once it served the request
+func (s *handlerSwitch) acquire() *generation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g := s.current
+	// registered while holding the lock, so retire can't miss the request
+	g.inflight.Add(1)
+	return g
+}
+
+func (s *handlerSwitch) generation() *generation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+func (s *handlerSwitch) allowedHosts() []string {
+	return s.generation().hosts
+}
+
+func (s *handlerSwitch) isStream(r *http.Request) bool {
+	return s.generation().isStream(r)
+}
+
+// swap makes next serve all new requests, the previous generation is retired in the background
+func (s *handlerSwitch) swap(next *generation) (canceled int) {
+	s.mu.Lock()
+	previous := s.current
+	s.current = next
+	s.mu.Unlock()
+	canceled = previous.cancelChanged(next)
+	go previous.retire()
+	return canceled
+}
+
+// retire retires the current generation, once the server stopped accepting requests
+func (s *handlerSwitch) retire() {
+	go s.generation().retire()
+}
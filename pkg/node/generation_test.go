@@ -0,0 +1,139 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/wundergraph/pkg/wgpb"
+)
+
+func TestHandlerSwitch_Swap(t *testing.T) {
+	started := make(chan struct{}, 3)
+	finish := make(chan struct{})
+	blocking := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			select {
+			case <-finish:
+				_, _ = w.Write([]byte(name))
+			case <-r.Context().Done():
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		})
+	}
+
+	var closed int32
+	operations := []*wgpb.Operation{
+		{Name: "Unchanged", Content: "subscription { a }"},
+		{Name: "Changed", Content: "query { b }"},
+	}
+	first := newGeneration(blocking("first"), "api/main", operations, nil, func() {
+		atomic.AddInt32(&closed, 1)
+	})
+	handlers := newHandlerSwitch(first)
+	srv := httptest.NewServer(handlers)
+	defer srv.Close()
+
+	type result struct {
+		path   string
+		status int
+	}
+	results := make(chan result, 3)
+	for _, path := range []string{"/api/main/operations/Unchanged", "/api/main/operations/Changed", "/health"} {
+		path := path
+		go func() {
+			res, err := http.Get(srv.URL + path)
+			if !assert.NoError(t, err) {
+				results <- result{path: path}
+				return
+			}
+			_ = res.Body.Close()
+			results <- result{path: path, status: res.StatusCode}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+
+	second := newGeneration(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("second"))
+	}), "api/main", []*wgpb.Operation{
+		{Name: "Unchanged", Content: "subscription { a }"},
+		{Name: "Changed", Content: "query { c }"},
+	}, nil, nil)
+	assert.Equal(t, 1, handlers.swap(second))
+
+	// the request of the changed operation is canceled, all others keep running on the first generation
+	res := <-results
+	assert.Equal(t, result{path: "/api/main/operations/Changed", status: http.StatusServiceUnavailable}, res)
+
+	// new requests are served by the second generation
+	res2, err := http.Get(srv.URL + "/api/main/operations/Unchanged")
+	require.NoError(t, err)
+	_ = res2.Body.Close()
+	assert.Equal(t, http.StatusOK, res2.StatusCode)
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&closed))
+	close(finish)
+	for i := 0; i < 2; i++ {
+		res := <-results
+		assert.Equal(t, http.StatusOK, res.status, res.path)
+	}
+
+	// the first generation is released once it's drained
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&closed) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHandlerSwitch_SwapDataSource(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	operations := []*wgpb.Operation{
+		{Name: "Messages", Content: "subscription { messages }", OperationType: wgpb.OperationType_SUBSCRIPTION},
+	}
+	engineConfiguration := func(url string) *wgpb.EngineConfiguration {
+		return &wgpb.EngineConfiguration{
+			DatasourceConfigurations: []*wgpb.DataSourceConfiguration{
+				{
+					Kind: wgpb.DataSourceKind_GRAPHQL,
+					CustomGraphql: &wgpb.DataSourceCustom_GraphQL{
+						Subscription: &wgpb.GraphQLSubscriptionConfiguration{
+							Url: &wgpb.ConfigurationVariable{StaticVariableContent: url},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	handlers := newHandlerSwitch(newGeneration(handler, "api/main", operations, engineConfiguration("ws://a"), nil))
+	srv := httptest.NewServer(handlers)
+	defer srv.Close()
+
+	status := make(chan int, 1)
+	go func() {
+		res, err := http.Get(srv.URL + "/api/main/operations/Messages")
+		if !assert.NoError(t, err) {
+			status <- 0
+			return
+		}
+		_ = res.Body.Close()
+		status <- res.StatusCode
+	}()
+	<-started
+
+	// the operation is unchanged, but its data source is served differently
+	next := newGeneration(http.NotFoundHandler(), "api/main", operations, engineConfiguration("ws://b"), nil)
+	assert.Equal(t, 1, handlers.swap(next))
+	assert.Equal(t, http.StatusOK, <-status)
+}
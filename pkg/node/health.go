@@ -0,0 +1,99 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jensneuse/abstractlogger"
+
+	"github.com/wundergraph/wundergraph/pkg/apicache"
+	"github.com/wundergraph/wundergraph/pkg/apihandler"
+	"github.com/wundergraph/wundergraph/pkg/engineconfigloader"
+	"github.com/wundergraph/wundergraph/pkg/healthcheck"
+	"github.com/wundergraph/wundergraph/pkg/hooks"
+)
+
+const (
+	livenessEndpoint  = "/health/live"
+	readinessEndpoint = "/health/ready"
+)
+
+// readinessReportTTL limits how often the public readiness endpoint probes the dependencies
+const readinessReportTTL = 2 * time.Second
+
+// LivenessReport reports that the node is serving requests, regardless of the health of its dependencies
+type LivenessReport struct {
+	Status    string    `json:"status"`
+	BuildInfo BuildInfo `json:"buildInfo"`
+}
+
+// readinessProbes returns the probes of the readiness report,
+// the hooks server is probed if its health check is enabled, datasources and the cache if readiness probes are enabled.
+// Database probes don't start stopped query engines, see database.LazyEngine.Ping.
+// Errors are logged instead of being reported.
+func (n *Node) readinessProbes(api *apihandler.Api, loader *engineconfigloader.EngineConfigLoader, cache apicache.Cache, hooksClient *hooks.Client) ([]healthcheck.Probe, error) {
+	var probes []healthcheck.Probe
+	if n.options.hooksServerHealthCheck {
+		probes = append(probes, healthcheck.Probe{
+			Name:    "server",
+			Kind:    healthcheck.KindHooks,
+			Timeout: n.options.healthCheckTimeout,
+			Check:   hooksClient.HealthCheck,
+		})
+	}
+	if !n.options.readinessProbes {
+		return probes, nil
+	}
+	if api.EngineConfiguration != nil {
+		datasources, err := loader.Probes(api.EngineConfiguration)
+		if err != nil {
+			return nil, err
+		}
+		probes = append(probes, datasources...)
+	}
+	if redisCache, ok := cache.(*apicache.RedisCache); ok {
+		probes = append(probes, healthcheck.Probe{
+			Name:  "cache",
+			Kind:  healthcheck.KindRedis,
+			Check: redisCache.Ping,
+		})
+	}
+	for i := range probes {
+		probes[i].Check = n.logProbeError(probes[i].Name, probes[i].Check)
+	}
+	return probes, nil
+}
+
+func (n *Node) logProbeError(name string, check func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		err := check(ctx)
+		if err != nil {
+			n.log.Warn("readiness probe failed",
+				abstractlogger.String("probe", name),
+				abstractlogger.Error(err),
+			)
+		}
+		return err
+	}
+}
+
+func (n *Node) serveLiveness(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	_ = json.NewEncoder(w).Encode(LivenessReport{
+		Status:    "LIVE",
+		BuildInfo: n.info,
+	})
+}
+
+// serveReadiness writes report, with 503 Service Unavailable if the node isn't ready
+func serveReadiness(w http.ResponseWriter, report interface{}, ready bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -2,12 +2,17 @@ package node
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -15,25 +20,44 @@ import (
 	"github.com/jensneuse/abstractlogger"
 	"github.com/pires/go-proxyproto"
 	"github.com/valyala/fasthttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/netutil"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/time/rate"
 
+	"github.com/wundergraph/wundergraph/pkg/apicache"
 	"github.com/wundergraph/wundergraph/pkg/apihandler"
 	"github.com/wundergraph/wundergraph/pkg/engineconfigloader"
+	"github.com/wundergraph/wundergraph/pkg/fixtures"
+	"github.com/wundergraph/wundergraph/pkg/healthcheck"
 	"github.com/wundergraph/wundergraph/pkg/hooks"
 	"github.com/wundergraph/wundergraph/pkg/httpidletimeout"
 	"github.com/wundergraph/wundergraph/pkg/loadvariable"
 	"github.com/wundergraph/wundergraph/pkg/logging"
+	"github.com/wundergraph/wundergraph/pkg/metrics"
 	"github.com/wundergraph/wundergraph/pkg/node/nodetemplates"
 	"github.com/wundergraph/wundergraph/pkg/pool"
+	"github.com/wundergraph/wundergraph/pkg/ratelimit"
+	"github.com/wundergraph/wundergraph/pkg/remoteconfig"
+	"github.com/wundergraph/wundergraph/pkg/tracing"
 	"github.com/wundergraph/wundergraph/pkg/validate"
+	"github.com/wundergraph/wundergraph/pkg/wasmhooks"
+	"github.com/wundergraph/wundergraph/pkg/watcher"
 	"github.com/wundergraph/wundergraph/pkg/wgpb"
 )
 
+const (
+	defaultRemoteConfigPollInterval = 10 * time.Second
+	remoteConfigTimeout             = 30 * time.Second
+)
+
 const (
 	rootEndpoint        = "/"
 	healthCheckEndpoint = "/health"
+	metricsEndpoint     = "/metrics"
 )
 
 func New(ctx context.Context, info BuildInfo, wundergraphDir string, log abstractlogger.Logger) *Node {
@@ -66,11 +90,18 @@ type Node struct {
 	apiClient      *fasthttp.Client
 	options        options
 	WundergraphDir string
+	metrics        *metrics.Metrics
+	metricsServer  *http.Server
+	tracerProvider *sdktrace.TracerProvider
+	rateLimiter    ratelimit.Limiter
+	// rateLimit are the options rateLimiter was created with
+	rateLimit apihandler.RateLimitOptions
 }
 
 type options struct {
 	staticConfig        *WunderNodeConfig
 	fileSystemConfig    *string
+	fileSystemConfigs   []string
 	enableDebugMode     bool
 	forceHttpsRedirects bool
 	enableIntrospection bool
@@ -88,6 +119,17 @@ type options struct {
 	idleHandler             func()
 	hooksServerHealthCheck  bool
 	healthCheckTimeout      time.Duration
+	readinessProbes         bool
+	readinessProbeTimeout   time.Duration
+	inProcessHooks          hooks.InProcessHooks
+	fixtures                fixtures.Options
+	remoteConfig            *remoteConfig
+}
+
+type remoteConfig struct {
+	source       remoteconfig.Source
+	publicKey    ed25519.PublicKey
+	pollInterval time.Duration
 }
 
 type Option func(options *options)
@@ -99,6 +141,29 @@ func WithHooksServerHealthCheck(timeout time.Duration) Option {
 	}
 }
 
+// WithReadinessProbes makes /health/ready probe the datasources and the cache of the API,
+// probes time out after timeout unless their datasource configures a request timeout
+func WithReadinessProbes(timeout time.Duration) Option {
+	return func(options *options) {
+		options.readinessProbes = true
+		options.readinessProbeTimeout = timeout
+	}
+}
+
+// WithInProcessHooks calls the hooks implemented in Go by h directly instead of through the hooks server
+func WithInProcessHooks(h hooks.InProcessHooks) Option {
+	return func(options *options) {
+		options.inProcessHooks = h
+	}
+}
+
+// WithFixtures records the responses of all origins as fixtures or serves the recorded fixtures instead
+func WithFixtures(fixtures fixtures.Options) Option {
+	return func(options *options) {
+		options.fixtures = fixtures
+	}
+}
+
 func WithStaticWunderNodeConfig(config WunderNodeConfig) Option {
 	return func(options *options) {
 		options.staticConfig = &config
@@ -146,6 +211,25 @@ func WithFileSystemConfig(configFilePath string) Option {
 	}
 }
 
+// WithRemoteConfig polls the config from source and applies it once its signature is verified with publicKey
+func WithRemoteConfig(source remoteconfig.Source, publicKey ed25519.PublicKey, pollInterval time.Duration) Option {
+	return func(options *options) {
+		options.remoteConfig = &remoteConfig{
+			source:       source,
+			publicKey:    publicKey,
+			pollInterval: pollInterval,
+		}
+	}
+}
+
+// WithFileSystemConfigs serves the APIs of multiple config files from one node.
+// Each API is mounted under its own path prefix or hosts and reloads when its config file changes.
+func WithFileSystemConfigs(configFilePaths ...string) Option {
+	return func(options *options) {
+		options.fileSystemConfigs = configFilePaths
+	}
+}
+
 func WithConfigFileChange(event chan struct{}) Option {
 	return func(options *options) {
 		options.configFileChange = event
@@ -226,6 +310,39 @@ func (n *Node) StartBlocking(opts ...Option) error {
 				return nil
 			})
 		}
+	case len(options.fileSystemConfigs) > 0:
+		n.log.Info("Api config: multiple files",
+			abstractlogger.Strings("config_file_names", options.fileSystemConfigs),
+		)
+		g.Go(func() error {
+			err := n.serveTenants(options.fileSystemConfigs)
+			if err != nil {
+				n.log.Error("could not start a node",
+					abstractlogger.Error(err),
+				)
+				return err
+			}
+			return nil
+		})
+	case options.remoteConfig != nil:
+		n.log.Info("Api config: remote polling",
+			abstractlogger.String("source", options.remoteConfig.source.String()),
+		)
+		g.Go(func() error {
+			err := n.reconfigureOnConfigUpdate()
+			if err != nil {
+				n.log.Error("could not reconfigure config update",
+					abstractlogger.Error(err),
+				)
+				return err
+			}
+			return nil
+		})
+
+		g.Go(func() error {
+			n.remotePollConfig(*options.remoteConfig)
+			return nil
+		})
 	default:
 		return errors.New("could not start a node. no config present")
 	}
@@ -234,8 +351,25 @@ func (n *Node) StartBlocking(opts ...Option) error {
 }
 
 func (n *Node) Shutdown(ctx context.Context) error {
+	if n.metricsServer != nil {
+		if err := n.metricsServer.Shutdown(ctx); err != nil {
+			n.log.Error("Error during metrics server shutdown", abstractlogger.Error(err))
+		}
+	}
+	if n.tracerProvider != nil {
+		if err := n.tracerProvider.Shutdown(ctx); err != nil {
+			n.log.Error("Error during tracer provider shutdown", abstractlogger.Error(err))
+		}
+	}
+	if closer, ok := n.rateLimiter.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			n.log.Error("Error closing rate limiter", abstractlogger.Error(err))
+		}
+	}
 	if n.server != nil {
-		return n.server.Shutdown(ctx)
+		if err := n.server.Shutdown(ctx); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -247,10 +381,13 @@ func (n *Node) Close() error {
 	return nil
 }
 
-func (n *Node) newListeners(configuration *apihandler.Listener) ([]net.Listener, error) {
+func (n *Node) newListeners(configuration *apihandler.Listener, server *Server) ([]net.Listener, error) {
 	cfg := net.ListenConfig{
 		KeepAlive: 90 * time.Second,
 	}
+	if server.KeepAlive > 0 {
+		cfg.KeepAlive = time.Duration(server.KeepAlive) * time.Second
+	}
 
 	host, port := configuration.Host, configuration.Port
 
@@ -301,6 +438,12 @@ func (n *Node) newListeners(configuration *apihandler.Listener) ([]net.Listener,
 		})
 	}
 
+	if server.MaxConnections > 0 {
+		for i := range listeners {
+			listeners[i] = netutil.LimitListener(listeners[i], server.MaxConnections)
+		}
+	}
+
 	return listeners, nil
 }
 
@@ -326,8 +469,9 @@ func (n *Node) GetHealthReport(hooksClient *hooks.Client) (*HealthCheckReport, b
 		ServerStatus: "NOT_READY",
 		// For now we assume that the server is ready
 		// because we don't have any health checks
-		NodeStatus: "READY",
-		BuildInfo:  n.info,
+		NodeStatus:          "READY",
+		BuildInfo:           n.info,
+		HookCircuitBreakers: hooksClient.CircuitBreakers(),
 	}
 
 	if n.options.hooksServerHealthCheck {
@@ -344,13 +488,148 @@ func (n *Node) GetHealthReport(hooksClient *hooks.Client) (*HealthCheckReport, b
 	return healthCheck, true
 }
 
+func hooksClientOptions(options apihandler.HooksOptions, m *metrics.Metrics) []hooks.Option {
+	opts := []hooks.Option{
+		hooks.WithMetrics(m),
+	}
+	if options.DefaultPolicy != nil {
+		opts = append(opts, hooks.WithDefaultPolicy(*options.DefaultPolicy))
+	}
+	if options.CircuitBreaker != nil {
+		opts = append(opts, hooks.WithCircuitBreaker(*options.CircuitBreaker))
+	}
+	if options.UnixSocket != "" {
+		opts = append(opts, hooks.WithUnixSocket(options.UnixSocket))
+	}
+	if options.H2C {
+		opts = append(opts, hooks.WithH2C())
+	}
+	for hook, policy := range options.Policies {
+		opts = append(opts, hooks.WithPolicy(hook, policy))
+	}
+	return opts
+}
+
+// newWasmHooks compiles the WebAssembly modules of all operations configuring one,
+// it returns nil if there are none
+func (n *Node) newWasmHooks(operations []*wgpb.Operation, log abstractlogger.Logger) (*wasmhooks.Hooks, error) {
+	modules := map[string][]byte{}
+	for _, operation := range operations {
+		path := operation.GetHooksConfiguration().GetWasmModule()
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(n.WundergraphDir, path)
+		}
+		binary, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("wasm module of operation %s: %w", operation.Name, err)
+		}
+		modules[operation.Name] = binary
+	}
+	if len(modules) == 0 {
+		return nil, nil
+	}
+	return wasmhooks.New(n.ctx, modules, wasmhooks.Options{}, log)
+}
+
+// newRateLimiter keeps the rate limits of operations in Redis if configured, in memory otherwise
+func newRateLimiter(options apihandler.RateLimitOptions) (ratelimit.Limiter, error) {
+	if options.RedisURL != "" {
+		return ratelimit.NewRedis(options.RedisURL)
+	}
+	return ratelimit.NewInMemory(), nil
+}
+
+// startMetricsServer serves the Prometheus metrics on a dedicated listener.
+// Metrics are kept across config reloads, so the server is only started once.
+func (n *Node) startMetricsServer(listener *apihandler.Listener) error {
+	if n.metrics != nil {
+		return nil
+	}
+
+	addr := fmt.Sprintf("%s:%d", listener.Host, listener.Port)
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("could not listen for metrics on %s: %w", addr, err)
+	}
+
+	n.metrics = metrics.New()
+
+	router := mux.NewRouter()
+	router.Handle(metricsEndpoint, n.metrics.Handler())
+	n.metricsServer = &http.Server{
+		Handler: router,
+	}
+
+	go func() {
+		n.log.Info("metrics listening on",
+			abstractlogger.String("addr", l.Addr().String()),
+			abstractlogger.String("path", metricsEndpoint),
+		)
+		if err := n.metricsServer.Serve(l); err != nil && err != http.ErrServerClosed {
+			n.log.Error("metrics server failed", abstractlogger.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+// startServer serves a static config until the node is closed
 func (n *Node) startServer(nodeConfig WunderNodeConfig) error {
+	g, err := n.newGeneration(nodeConfig, "")
+	if err != nil {
+		return err
+	}
+	handlers := newHandlerSwitch(g)
+	// stopping the server closes the connections, the remaining requests finish with canceled contexts
+	defer handlers.retire()
+	return n.serve(nodeConfig, handlers)
+}
+
+// newGeneration builds the handlers of a config, it returns an error if the config can't be served.
+// tenant labels the metrics of the API with its path prefix if the node serves multiple APIs, it's empty otherwise.
+func (n *Node) newGeneration(nodeConfig WunderNodeConfig, tenant string) (*generation, error) {
 	logLevel := nodeConfig.Api.Options.Logging.Level
 	if n.options.enableDebugMode {
 		logLevel = abstractlogger.DebugLevel
 	}
 
-	n.log = abstractlogger.NewZapLogger(logging.Zap().With(zap.String("component", "@wundergraph/node")), logLevel)
+	// the logger belongs to the generation, requests of the previous one keep using theirs
+	log := abstractlogger.NewZapLogger(logging.Zap().With(zap.String("component", "@wundergraph/node")), logLevel)
+
+	if nodeConfig.Api.Options.Metrics.Enabled {
+		if err := n.startMetricsServer(nodeConfig.Api.Options.Metrics.Listener); err != nil {
+			log.Error("startMetricsServer", abstractlogger.Error(err))
+			return nil, err
+		}
+	}
+
+	apiMetrics := n.metrics.WithTenant(tenant)
+
+	// like metrics, the tracer provider is kept across config reloads
+	if nodeConfig.Api.Options.Tracing.Enabled && n.tracerProvider == nil {
+		tracerProvider, err := tracing.Init(n.ctx, nodeConfig.Api.Options.Tracing)
+		if err != nil {
+			log.Error("tracing.Init", abstractlogger.Error(err))
+			return nil, err
+		}
+		n.tracerProvider = tracerProvider
+	}
+
+	// rate limits must survive config reloads, otherwise every reload would reset the budgets
+	if n.rateLimiter == nil {
+		rateLimiter, err := newRateLimiter(nodeConfig.Api.Options.RateLimit)
+		if err != nil {
+			log.Error("newRateLimiter", abstractlogger.Error(err))
+			return nil, err
+		}
+		n.rateLimiter = rateLimiter
+		n.rateLimit = nodeConfig.Api.Options.RateLimit
+	} else if nodeConfig.Api.Options.RateLimit.RedisURL != n.rateLimit.RedisURL {
+		log.Warn("rate limit store changed, restart the node to apply it")
+	}
 
 	router := mux.NewRouter()
 
@@ -370,16 +649,18 @@ func (n *Node) startServer(nodeConfig WunderNodeConfig) error {
 	}
 
 	var streamClosers []chan struct{}
+	// the cache of the builder, each generation connects to Redis on its own
+	var cache apicache.Cache
 
 	n.setApiDevConfigDefaults(nodeConfig.Api)
 
 	valid, messages := validate.ApiConfig(nodeConfig.Api)
 
 	if !valid {
-		n.log.Error("API config invalid",
+		log.Error("API config invalid",
 			abstractlogger.Strings("errors", messages),
 		)
-		return errors.New("API config invalid")
+		return nil, errors.New("API config invalid")
 	}
 
 	dialer := &net.Dialer{
@@ -399,11 +680,47 @@ func (n *Node) startServer(nodeConfig WunderNodeConfig) error {
 
 	serverUrl := strings.TrimSuffix(nodeConfig.Api.Options.ServerUrl, "/")
 
-	hooksClient := hooks.NewClient(serverUrl, n.log)
+	hooksClientOpts := hooksClientOptions(nodeConfig.Api.Options.Hooks, apiMetrics)
+	if n.options.inProcessHooks != nil {
+		hooksClientOpts = append(hooksClientOpts, hooks.WithInProcessHooks(n.options.inProcessHooks))
+	}
+	wasmHooks, err := n.newWasmHooks(nodeConfig.Api.Operations, log)
+	if err != nil {
+		log.Error("newWasmHooks", abstractlogger.Error(err))
+		return nil, err
+	}
+	// streams, modules and the cache are released once the last request of the generation finished
+	release := func() {
+		for _, closer := range streamClosers {
+			close(closer)
+		}
+		if closer, ok := cache.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Error("Error closing cache", abstractlogger.Error(err))
+			}
+		}
+		if wasmHooks != nil {
+			if err := wasmHooks.Close(context.Background()); err != nil {
+				log.Error("Error closing wasm hooks", abstractlogger.Error(err))
+			}
+		}
+	}
+	if wasmHooks != nil {
+		hooksClientOpts = append(hooksClientOpts, hooks.WithInProcessHooks(wasmHooks))
+	}
+	hooksClient := hooks.NewClient(serverUrl, log, hooksClientOpts...)
+
+	nodeConfig.Api.Options.Fixtures = n.options.fixtures
+	if n.options.fixtures.Mode != fixtures.Disabled {
+		log.Info("origin fixtures enabled",
+			abstractlogger.String("mode", string(n.options.fixtures.Mode)),
+			abstractlogger.String("dir", n.options.fixtures.Dir),
+		)
+	}
 
-	transportFactory := apihandler.NewApiTransportFactory(nodeConfig.Api, hooksClient, n.options.enableDebugMode)
+	transportFactory := apihandler.NewApiTransportFactory(nodeConfig.Api, hooksClient, apiMetrics, n.options.enableDebugMode)
 
-	n.log.Debug("http.Client.Transport",
+	log.Debug("http.Client.Transport",
 		abstractlogger.Bool("enableDebugMode", n.options.enableDebugMode),
 	)
 
@@ -411,7 +728,7 @@ func (n *Node) startServer(nodeConfig WunderNodeConfig) error {
 		transportFactory,
 		defaultTransport,
 		n.options.enableDebugMode,
-		n.log,
+		log,
 		hooksClient,
 	))
 
@@ -424,34 +741,40 @@ func (n *Node) startServer(nodeConfig WunderNodeConfig) error {
 		GitHubAuthDemoClientSecret: n.options.githubAuthDemo.ClientSecret,
 		HookServerURL:              serverUrl,
 		DevMode:                    n.options.devMode,
+		Metrics:                    apiMetrics,
+		RateLimiter:                n.rateLimiter,
+	}
+	if len(n.options.fileSystemConfigs) > 0 {
+		// APIs on the same domain must not share their sessions
+		builderConfig.CookiePath = path.Join("/", nodeConfig.Api.PathPrefix)
 	}
 
-	builder := apihandler.NewBuilder(n.pool, n.log, loader, hooksClient, builderConfig)
-	internalBuilder := apihandler.NewInternalBuilder(n.pool, n.log, loader)
+	builder := apihandler.NewBuilder(n.pool, log, loader, hooksClient, builderConfig)
+	internalBuilder := apihandler.NewInternalBuilder(n.pool, log, loader)
 
 	publicClosers, err := builder.BuildAndMountApiHandler(n.ctx, router, nodeConfig.Api)
+	streamClosers = append(streamClosers, publicClosers...)
+	cache = builder.Cache()
 	if err != nil {
-		n.log.Error("BuildAndMountApiHandler", abstractlogger.Error(err))
+		log.Error("BuildAndMountApiHandler", abstractlogger.Error(err))
+		release()
+		return nil, err
 	}
-	streamClosers = append(streamClosers, publicClosers...)
+
+	builder.MountCachePurgeHandler(internalRouter, nodeConfig.Api)
 
 	internalClosers, err := internalBuilder.BuildAndMountInternalApiHandler(n.ctx, internalRouter, nodeConfig.Api)
+	streamClosers = append(streamClosers, internalClosers...)
 	if err != nil {
-		n.log.Error("BuildAndMountInternalApiHandler", abstractlogger.Error(err))
+		log.Error("BuildAndMountInternalApiHandler", abstractlogger.Error(err))
+		release()
+		return nil, err
 	}
 
-	streamClosers = append(streamClosers, internalClosers...)
-
-	defer func() {
-		for _, closer := range streamClosers {
-			close(closer)
-		}
-	}()
-
 	router.Handle(rootEndpoint, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		template, err := nodetemplates.GetTemplateByPath(rootEndpoint)
 		if err != nil {
-			n.log.Error("GetTemplateByPath", abstractlogger.Error(err))
+			log.Error("GetTemplateByPath", abstractlogger.Error(err))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -478,8 +801,42 @@ func (n *Node) startServer(nodeConfig WunderNodeConfig) error {
 		_ = json.NewEncoder(w).Encode(report)
 	}))
 
+	probes, err := n.readinessProbes(nodeConfig.Api, loader, cache, hooksClient)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	router.Handle(livenessEndpoint, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.serveLiveness(w)
+	}))
+	readiness := healthcheck.NewCached(probes, n.options.readinessProbeTimeout, readinessReportTTL)
+	router.Handle(readinessEndpoint, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report, ready := readiness.Run(r.Context())
+		serveReadiness(w, report, ready)
+	}))
+
+	g := newGeneration(router, nodeConfig.Api.PathPrefix, nodeConfig.Api.Operations, nodeConfig.Api.EngineConfiguration, release)
+	g.health = func() (*HealthCheckReport, bool) {
+		return n.GetHealthReport(hooksClient)
+	}
+	g.ready = readiness.Run
+	g.hosts = nodeConfig.Api.Hosts
+	return g, nil
+}
+
+// serve serves handler on the listeners of the config until the node is closed.
+// Listeners are kept when the handlers behind handler are swapped.
+func (n *Node) serve(nodeConfig WunderNodeConfig, handler http.Handler) error {
+	server := nodeConfig.Server
+	if server == nil {
+		server = &Server{}
+	}
 	n.server = &http.Server{
-		Handler: router,
+		// read and write timeouts are enforced per request, streams have their own
+		Handler:           withTimeouts(handler, server),
+		ReadHeaderTimeout: time.Duration(server.ReadHeaderTimeout) * time.Second,
+		IdleTimeout:       time.Duration(server.IdleTimeout) * time.Second,
+		MaxHeaderBytes:    server.MaxHeaderBytes,
 		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
 			return context.WithValue(ctx, "conn", c)
 		},
@@ -489,11 +846,11 @@ func (n *Node) startServer(nodeConfig WunderNodeConfig) error {
 	if n.options.idleTimeout > 0 {
 		opts := []httpidletimeout.Option{
 			httpidletimeout.WithSkip(func(r *http.Request) bool {
-				return r.URL.Path == healthCheckEndpoint
+				return hasPathPrefix(r.URL.Path, healthCheckEndpoint)
 			}),
 		}
 		timeoutMiddleware := httpidletimeout.New(n.options.idleTimeout, opts...)
-		router.Use(timeoutMiddleware.Handler)
+		n.server.Handler = timeoutMiddleware.Handler(n.server.Handler)
 		n.server.RegisterOnShutdown(timeoutMiddleware.Cancel)
 		timeoutMiddleware.Start()
 		go func() {
@@ -502,7 +859,31 @@ func (n *Node) startServer(nodeConfig WunderNodeConfig) error {
 		}()
 	}
 
-	listeners, err := n.newListeners(nodeConfig.Api.Options.Listener)
+	listenerOptions := nodeConfig.Api.Options.Listener
+	useTLS := listenerOptions.TLS.Enabled()
+	if useTLS {
+		var (
+			tlsConfig *tls.Config
+			err       error
+		)
+		if hosts, ok := handler.(hostsHandler); ok && listenerOptions.TLS.ACME.Enabled {
+			tlsConfig, err = n.newACMETLSConfig(nodeConfig, hosts.allowedHosts)
+		} else {
+			tlsConfig, err = newTLSConfig(listenerOptions.TLS, n.log)
+		}
+		if err != nil {
+			return err
+		}
+		n.server.TLSConfig = tlsConfig
+		if listenerOptions.TLS.DisableHTTP2 {
+			// a non-nil map keeps the server from enabling HTTP/2
+			n.server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+	} else if listenerOptions.H2C {
+		n.server.Handler = h2c.NewHandler(n.server.Handler, &http2.Server{})
+	}
+
+	listeners, err := n.newListeners(listenerOptions, server)
 	if err != nil {
 		return err
 	}
@@ -514,9 +895,18 @@ func (n *Node) startServer(nodeConfig WunderNodeConfig) error {
 		g.Go(func() error {
 			n.log.Info("listening on",
 				abstractlogger.String("addr", l.Addr().String()),
+				abstractlogger.Bool("tls", useTLS),
+				abstractlogger.Bool("h2c", listenerOptions.H2C && !useTLS),
 			)
 
-			if err := n.server.Serve(l); err != nil {
+			var err error
+			if useTLS {
+				// the certificate is served by the TLS config
+				err = n.server.ServeTLS(l, "", "")
+			} else {
+				err = n.server.Serve(l)
+			}
+			if err != nil {
 				if err == http.ErrServerClosed {
 					n.log.Debug("listener closed",
 						abstractlogger.String("addr", l.Addr().String()),
@@ -590,23 +980,61 @@ WithNext:
 	return filtered
 }
 
+// reconfigureOnConfigUpdate starts the server with the first valid config and swaps the handlers on updates.
+// Requests in flight finish on the config they started with, unless their operation changed.
+// Invalid configs are rejected, the previous config keeps serving.
 func (n *Node) reconfigureOnConfigUpdate() error {
 	g, ctx := errgroup.WithContext(n.ctx)
 
+	var (
+		handlers *handlerSwitch
+		listener apihandler.Listener
+		server   Server
+	)
+
 	for {
 		select {
 		case config := <-n.configCh:
-			n.log.Debug("Updated config -> (re-)configuring server")
-			_ = n.Close()
+			next, err := n.newGeneration(config, "")
+			if err != nil {
+				if handlers == nil {
+					n.log.Error("invalid config, waiting for a valid one", abstractlogger.Error(err))
+				} else {
+					n.log.Error("invalid config, previous config keeps serving", abstractlogger.Error(err))
+				}
+				continue
+			}
 
-			// in a new routine, startServer is blocking
-			g.Go(func() error {
-				err := n.startServer(config)
-				if err != nil {
-					return err
+			if handlers == nil {
+				n.log.Debug("Initial config -> starting server")
+				handlers = newHandlerSwitch(next)
+				if config.Api.Options.Listener != nil {
+					listener = *config.Api.Options.Listener
 				}
-				return nil
-			})
+				if config.Server != nil {
+					server = *config.Server
+				}
+				// in a new routine, serve is blocking
+				g.Go(func() error {
+					defer handlers.retire()
+					return n.serve(config, handlers)
+				})
+				continue
+			}
+
+			if config.Api.Options.Listener != nil && *config.Api.Options.Listener != listener {
+				n.log.Warn("listener changed, restart the node to apply it",
+					abstractlogger.String("host", config.Api.Options.Listener.Host),
+					abstractlogger.Int("port", int(config.Api.Options.Listener.Port)),
+				)
+			}
+			if config.Server != nil && *config.Server != server {
+				n.log.Warn("server timeouts or limits changed, restart the node to apply them")
+			}
+			canceled := handlers.swap(next)
+			n.log.Debug("Updated config -> swapped handlers",
+				abstractlogger.Int("canceledRequests", canceled),
+			)
 
 		case <-ctx.Done():
 			return g.Wait()
@@ -623,39 +1051,177 @@ func (n *Node) filePollConfig(filePath string) error {
 			if !ok {
 				return nil
 			}
-			err := n.reloadFileConfig(filePath)
-			if err != nil {
-				return err
-			}
+			// unreadable configs are logged by reloadFileConfig, the previous config keeps serving
+			_ = n.reloadFileConfig(filePath)
 		}
 	}
 }
 
 func (n *Node) reloadFileConfig(filePath string) error {
+	config, err := n.readConfigFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	n.configCh <- config
+
+	return nil
+}
+
+// readConfigFile loads the node config from a WunderGraph config file, errors are logged
+func (n *Node) readConfigFile(filePath string) (WunderNodeConfig, error) {
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		n.log.Error("reloadFileConfig ioutil.ReadFile", abstractlogger.String("filePath", filePath), abstractlogger.Error(err))
-		return err
+		return WunderNodeConfig{}, err
 	}
 	if len(data) == 0 {
-		return errors.New("empty config file")
+		n.log.Error("reloadFileConfig empty config file", abstractlogger.String("filePath", filePath))
+		return WunderNodeConfig{}, errors.New("empty config file")
 	}
 	var graphConfig wgpb.WunderGraphConfiguration
 	err = json.Unmarshal(data, &graphConfig)
 	if err != nil {
 		n.log.Error("reloadFileConfig json.Unmarshal", abstractlogger.String("filePath", filePath), abstractlogger.Error(err))
-		return err
+		return WunderNodeConfig{}, err
 	}
 
 	config, err := CreateConfig(&graphConfig)
 	if err != nil {
 		n.log.Error("reloadFileConfig CreateConfig", abstractlogger.String("filePath", filePath), abstractlogger.Error(err))
-		return err
+		return WunderNodeConfig{}, err
 	}
+	return config, nil
+}
 
-	n.configCh <- config
+// serveTenants serves the APIs of all config files and reloads each API when its file changes.
+// The listener of the first valid config is used for all APIs.
+func (n *Node) serveTenants(configFilePaths []string) error {
+	tenants := newTenantRouter(n.serveLiveness)
+	var (
+		listenerConfig *WunderNodeConfig
+		watchPaths     []*watcher.WatchPath
+	)
+	// watchers report absolute paths
+	known := make(map[string]bool, len(configFilePaths))
+	for _, configFilePath := range configFilePaths {
+		if abs, err := filepath.Abs(configFilePath); err == nil {
+			configFilePath = abs
+		}
+		known[configFilePath] = true
+		watchPaths = append(watchPaths, &watcher.WatchPath{Path: configFilePath})
+		config, err := n.reloadTenant(tenants, configFilePath)
+		if err == nil && listenerConfig == nil {
+			listenerConfig = &config
+		}
+	}
+	if listenerConfig == nil {
+		return errors.New("none of the API configs is valid")
+	}
 
-	return nil
+	configWatcher := watcher.NewWatcher("tenants", &watcher.Config{
+		WatchPaths: watchPaths,
+	}, n.log)
+	go func() {
+		err := configWatcher.Watch(n.ctx, func(paths []string) error {
+			for _, changed := range paths {
+				if known[changed] {
+					_, _ = n.reloadTenant(tenants, changed)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			n.log.Error("watcher",
+				abstractlogger.String("watcher", "tenants"),
+				abstractlogger.Error(err),
+			)
+		}
+	}()
+
+	defer tenants.retire()
+	return n.serve(*listenerConfig, tenants)
+}
+
+// reloadTenant (re-)configures the API of a config file, invalid configs are rejected and the previous config keeps serving
+func (n *Node) reloadTenant(tenants *tenantRouter, configFilePath string) (WunderNodeConfig, error) {
+	config, err := n.readConfigFile(configFilePath)
+	if err != nil {
+		return config, err
+	}
+	// the path prefix identifies the API independent of where its config file is deployed
+	next, err := n.newGeneration(config, config.Api.PathPrefix)
+	if err == nil {
+		var canceled int
+		canceled, err = tenants.update(configFilePath, config.Api, next)
+		if err != nil {
+			next.retire()
+		} else {
+			n.log.Debug("Updated config -> swapped API handlers",
+				abstractlogger.String("filePath", configFilePath),
+				abstractlogger.String("pathPrefix", config.Api.PathPrefix),
+				abstractlogger.Int("canceledRequests", canceled),
+			)
+		}
+	}
+	if err != nil {
+		n.log.Error("invalid API config, previous config keeps serving",
+			abstractlogger.String("filePath", configFilePath),
+			abstractlogger.Error(err),
+		)
+	}
+	return config, err
+}
+
+// remotePollConfig applies the remote config whenever its version changes, until the node is closed
+func (n *Node) remotePollConfig(config remoteConfig) {
+	if config.pollInterval <= 0 {
+		config.pollInterval = defaultRemoteConfigPollInterval
+	}
+	ticker := time.NewTicker(config.pollInterval)
+	defer ticker.Stop()
+
+	version := ""
+	for {
+		version = n.reloadRemoteConfig(config, version)
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reloadRemoteConfig applies the remote config if it changed since version and returns its version.
+// Unavailable, unsigned and invalid configs are logged, the previous config keeps serving.
+func (n *Node) reloadRemoteConfig(config remoteConfig, version string) string {
+	ctx, cancel := context.WithTimeout(n.ctx, remoteConfigTimeout)
+	defer cancel()
+
+	graphConfig, next, err := remoteconfig.Load(ctx, config.source, config.publicKey, version)
+	if errors.Is(err, remoteconfig.ErrNotModified) {
+		return version
+	}
+	if err != nil {
+		n.log.Error("reloadRemoteConfig remoteconfig.Load", abstractlogger.String("source", config.source.String()), abstractlogger.Error(err))
+		return version
+	}
+
+	nodeConfig, err := CreateConfig(graphConfig)
+	if err != nil {
+		n.log.Error("reloadRemoteConfig CreateConfig", abstractlogger.String("source", config.source.String()), abstractlogger.Error(err))
+		return version
+	}
+
+	n.log.Info("remote config updated",
+		abstractlogger.String("source", config.source.String()),
+		abstractlogger.String("version", next),
+	)
+	select {
+	case n.configCh <- nodeConfig:
+	case <-n.ctx.Done():
+	}
+	return next
 }
 
 func uniqueStrings(slice []string) []string {
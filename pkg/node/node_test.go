@@ -23,6 +23,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/wundergraph/wundergraph/pkg/apihandler"
+	"github.com/wundergraph/wundergraph/pkg/hooks"
 	"github.com/wundergraph/wundergraph/pkg/logging"
 	"github.com/wundergraph/wundergraph/pkg/wgpb"
 )
@@ -184,6 +185,46 @@ func TestNode(t *testing.T) {
 		httpexpect.ContentOpts{MediaType: "text/html"})
 }
 
+func TestHooksOptions(t *testing.T) {
+	options, err := hooksOptions(&wgpb.HooksClientOptions{
+		Policies: []*wgpb.HookRequestPolicy{
+			{Hooks: []string{"preResolve"}, OnFailure: wgpb.HookFailurePolicy_SKIP_HOOK_FAILURE},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, hooks.FailSkip, options.Policies[hooks.PreResolve].OnFailure)
+
+	_, err = hooksOptions(&wgpb.HooksClientOptions{
+		Policies: []*wgpb.HookRequestPolicy{{Hooks: []string{"preResolv"}}},
+	})
+	assert.EqualError(t, err, `invalid hook policy: unknown hook "preResolv"`)
+
+	_, err = hooksOptions(&wgpb.HooksClientOptions{
+		Policies: []*wgpb.HookRequestPolicy{
+			{Hooks: []string{"mutatingPostResolve"}, OnFailure: wgpb.HookFailurePolicy_LAST_GOOD_HOOK_FAILURE},
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestCacheOptions(t *testing.T) {
+	assert.Equal(t, int64(1e9), cacheOptions(nil).GetInMemoryConfig().GetMaxSize())
+
+	configured := &wgpb.ApiCacheConfig{
+		Kind: wgpb.ApiCacheKind_IN_MEMORY_CACHE,
+		InMemoryConfig: &wgpb.InMemoryCacheConfig{
+			Compression: wgpb.CacheCompression_GZIP_CACHE_COMPRESSION,
+		},
+	}
+	options := cacheOptions(configured)
+	assert.Equal(t, int64(1e9), options.InMemoryConfig.MaxSize)
+	assert.Equal(t, wgpb.CacheCompression_GZIP_CACHE_COMPRESSION, options.InMemoryConfig.Compression)
+	assert.Equal(t, int64(0), configured.InMemoryConfig.MaxSize)
+
+	disabled := &wgpb.ApiCacheConfig{Kind: wgpb.ApiCacheKind_NO_CACHE}
+	assert.Same(t, disabled, cacheOptions(disabled))
+}
+
 func TestWebHooks(t *testing.T) {
 
 	var paths []string
@@ -0,0 +1,244 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wundergraph/wundergraph/pkg/apihandler"
+	"github.com/wundergraph/wundergraph/pkg/healthcheck"
+)
+
+// tenant is an API of a node serving multiple APIs
+type tenant struct {
+	// name identifies the tenant, e.g. by the path of its config file
+	name       string
+	pathPrefix string
+	// hosts restrict the tenant to requests for these hosts, all hosts are allowed if empty
+	hosts    []string
+	handlers *handlerSwitch
+}
+
+// matches reports whether the tenant serves r and how specific the match is.
+// Matches on a host are more specific than on any host, longer path prefixes more specific than shorter ones.
+func (t *tenant) matches(r *http.Request) (bool, int) {
+	if !hasPathPrefix(r.URL.Path, "/"+t.pathPrefix) && !hasPathPrefix(r.URL.Path, "/internal/"+t.pathPrefix) {
+		return false, 0
+	}
+	specificity := len(t.pathPrefix)
+	if len(t.hosts) == 0 {
+		return true, specificity
+	}
+	for _, host := range t.hosts {
+		if r.Host == host {
+			// longer than any path
+			return true, specificity + 1<<16
+		}
+	}
+	return false, 0
+}
+
+// overlaps reports whether both tenants would serve the same requests
+func (t *tenant) overlaps(pathPrefix string, hosts []string) bool {
+	if t.pathPrefix != pathPrefix {
+		return false
+	}
+	if len(t.hosts) == 0 || len(hosts) == 0 {
+		return len(t.hosts) == len(hosts)
+	}
+	for _, a := range t.hosts {
+		for _, b := range hosts {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// tenantRouter routes requests to the APIs of a node by their path prefix and host.
+// Every API reloads independently, its handlers are swapped without affecting the others.
+type tenantRouter struct {
+	mu      sync.RWMutex
+	tenants map[string]*tenant
+	// serveLiveness reports the liveness of the node
+	serveLiveness func(w http.ResponseWriter)
+}
+
+func newTenantRouter(serveLiveness func(w http.ResponseWriter)) *tenantRouter {
+	return &tenantRouter{
+		tenants:       map[string]*tenant{},
+		serveLiveness: serveLiveness,
+	}
+}
+
+func (t *tenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if g := t.acquire(r); g != nil {
+		g.ServeHTTP(w, r)
+		return
+	}
+	switch r.URL.Path {
+	case healthCheckEndpoint:
+		t.serveHealth(w)
+		return
+	case livenessEndpoint:
+		t.serveLiveness(w)
+		return
+	case readinessEndpoint:
+		t.serveReadiness(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// acquire registers r with the current generation of the matching tenant while holding the lock,
+// so an update moving the tenant retires the generation only after r finished.
+// The caller must serve r with the returned generation.
+func (t *tenantRouter) acquire(r *http.Request) *generation {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if match := t.matchLocked(r); match != nil {
+		return match.handlers.acquire()
+	}
+	return nil
+}
+
+func (t *tenantRouter) match(r *http.Request) *tenant {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.matchLocked(r)
+}
+
+func (t *tenantRouter) matchLocked(r *http.Request) *tenant {
+	var (
+		best            *tenant
+		bestSpecificity int
+	)
+	for _, candidate := range t.tenants {
+		if ok, specificity := candidate.matches(r); ok && specificity >= bestSpecificity {
+			best, bestSpecificity = candidate, specificity
+		}
+	}
+	return best
+}
+
+// serveHealth reports the health of all APIs, the node is only healthy if all of them are
+func (t *tenantRouter) serveHealth(w http.ResponseWriter) {
+	t.mu.RLock()
+	reports := make(map[string]*HealthCheckReport, len(t.tenants))
+	healthy := true
+	for name, tenant := range t.tenants {
+		g := tenant.handlers.generation()
+		if g.health == nil {
+			continue
+		}
+		report, ok := g.health()
+		reports[name] = report
+		healthy = healthy && ok
+	}
+	t.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(reports)
+}
+
+// serveReadiness probes the dependencies of all APIs, the node is only ready if all of them are
+func (t *tenantRouter) serveReadiness(w http.ResponseWriter, r *http.Request) {
+	t.mu.RLock()
+	probes := make(map[string]func(ctx context.Context) (*healthcheck.Report, bool), len(t.tenants))
+	for name, tenant := range t.tenants {
+		if g := tenant.handlers.generation(); g.ready != nil {
+			probes[name] = g.ready
+		}
+	}
+	t.mu.RUnlock()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		reports = make(map[string]*healthcheck.Report, len(probes))
+		ready   = true
+	)
+	for name, probe := range probes {
+		wg.Add(1)
+		go func(name string, probe func(ctx context.Context) (*healthcheck.Report, bool)) {
+			defer wg.Done()
+			report, ok := probe(r.Context())
+			mu.Lock()
+			reports[name] = report
+			ready = ready && ok
+			mu.Unlock()
+		}(name, probe)
+	}
+	wg.Wait()
+	serveReadiness(w, reports, ready)
+}
+
+// update serves api with next, replacing the previous generation of the tenant.
+// It fails if another tenant already serves the same path prefix and hosts.
+func (t *tenantRouter) update(name string, api *apihandler.Api, next *generation) (canceled int, err error) {
+	hosts := uniqueStrings(api.Hosts)
+	sort.Strings(hosts)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, other := range t.tenants {
+		if other.name != name && other.overlaps(api.PathPrefix, hosts) {
+			return 0, fmt.Errorf("API %s is already served on /%s", other.name, api.PathPrefix)
+		}
+	}
+	previous, ok := t.tenants[name]
+	if ok && previous.pathPrefix == api.PathPrefix && strings.Join(previous.hosts, ",") == strings.Join(hosts, ",") {
+		return previous.handlers.swap(next), nil
+	}
+	t.tenants[name] = &tenant{
+		name:       name,
+		pathPrefix: api.PathPrefix,
+		hosts:      hosts,
+		handlers:   newHandlerSwitch(next),
+	}
+	if ok {
+		// the API moved, requests on the previous routes are drained
+		previous.handlers.retire()
+	}
+	return 0, nil
+}
+
+func (t *tenantRouter) isStream(r *http.Request) bool {
+	if match := t.match(r); match != nil {
+		return match.handlers.isStream(r)
+	}
+	return false
+}
+
+// allowedHosts are the allowed hosts of all APIs
+func (t *tenantRouter) allowedHosts() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var hosts []string
+	for _, tenant := range t.tenants {
+		hosts = append(hosts, tenant.handlers.allowedHosts()...)
+	}
+	return uniqueStrings(hosts)
+}
+
+// retire retires the current generations of all tenants, once the server stopped accepting requests
+func (t *tenantRouter) retire() {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, tenant := range t.tenants {
+		tenant.handlers.retire()
+	}
+}
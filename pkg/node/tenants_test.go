@@ -0,0 +1,143 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/wundergraph/pkg/apihandler"
+	"github.com/wundergraph/wundergraph/pkg/healthcheck"
+)
+
+func TestTenantRouter(t *testing.T) {
+	tenants := newTenantRouter((&Node{}).serveLiveness)
+	update := func(name string, api *apihandler.Api, body string) error {
+		_, err := tenants.update(name, api, newGeneration(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}), api.PathPrefix, nil, nil, nil))
+		return err
+	}
+	get := func(host, path string) (int, string) {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Host = host
+		w := httptest.NewRecorder()
+		tenants.ServeHTTP(w, r)
+		body, _ := io.ReadAll(w.Result().Body)
+		return w.Code, string(body)
+	}
+
+	require.NoError(t, update("users.json", &apihandler.Api{PathPrefix: "users/main"}, "users"))
+	require.NoError(t, update("posts.json", &apihandler.Api{PathPrefix: "posts/main"}, "posts"))
+	require.NoError(t, update("tenant.json", &apihandler.Api{PathPrefix: "users/main", Hosts: []string{"tenant.example.com"}}, "tenant"))
+
+	_, body := get("localhost", "/users/main/operations/Users")
+	assert.Equal(t, "users", body)
+	_, body = get("localhost", "/internal/posts/main/operations/Posts")
+	assert.Equal(t, "posts", body)
+	// APIs on their own host take precedence
+	_, body = get("tenant.example.com", "/users/main/operations/Users")
+	assert.Equal(t, "tenant", body)
+	code, _ := get("localhost", "/users/mainframe/operations/Users")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// APIs can't take over the routes of others
+	assert.ErrorContains(t, update("other.json", &apihandler.Api{PathPrefix: "posts/main"}, "other"), "posts.json")
+
+	// reloads only affect the reloaded API
+	require.NoError(t, update("posts.json", &apihandler.Api{PathPrefix: "posts/main"}, "posts v2"))
+	_, body = get("localhost", "/posts/main/operations/Posts")
+	assert.Equal(t, "posts v2", body)
+	_, body = get("localhost", "/users/main/operations/Users")
+	assert.Equal(t, "users", body)
+
+	// APIs can move to another prefix
+	require.NoError(t, update("posts.json", &apihandler.Api{PathPrefix: "posts/v3"}, "posts v3"))
+	_, body = get("localhost", "/posts/v3/operations/Posts")
+	assert.Equal(t, "posts v3", body)
+	code, _ = get("localhost", "/posts/main/operations/Posts")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestTenantRouter_MoveDrainsRequests(t *testing.T) {
+	tenants := newTenantRouter((&Node{}).serveLiveness)
+	started, release, closed := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	previous := newGeneration(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("posts"))
+	}), "posts/main", nil, nil, func() { close(closed) })
+	_, err := tenants.update("posts.json", &apihandler.Api{PathPrefix: "posts/main"}, previous)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		tenants.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/main/operations/Posts", nil))
+	}()
+	<-started
+
+	next := newGeneration(http.NotFoundHandler(), "posts/v2", nil, nil, nil)
+	_, err = tenants.update("posts.json", &apihandler.Api{PathPrefix: "posts/v2"}, next)
+	require.NoError(t, err)
+	select {
+	case <-closed:
+		t.Fatal("the previous generation was closed while serving a request")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-served
+	<-closed
+	assert.Equal(t, "posts", w.Body.String())
+}
+
+func TestTenantRouterReadiness(t *testing.T) {
+	tenants := newTenantRouter((&Node{}).serveLiveness)
+	update := func(name string, ready bool) {
+		g := newGeneration(http.NotFoundHandler(), name, nil, nil, nil)
+		g.ready = func(ctx context.Context) (*healthcheck.Report, bool) {
+			status := healthcheck.StatusReady
+			if !ready {
+				status = healthcheck.StatusNotReady
+			}
+			return &healthcheck.Report{Status: status}, ready
+		}
+		_, err := tenants.update(name, &apihandler.Api{PathPrefix: name}, g)
+		require.NoError(t, err)
+	}
+	get := func(path string) (int, map[string]healthcheck.Report) {
+		w := httptest.NewRecorder()
+		tenants.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var reports map[string]healthcheck.Report
+		_ = json.NewDecoder(w.Body).Decode(&reports)
+		return w.Code, reports
+	}
+
+	update("users", true)
+	update("posts", true)
+	code, reports := get(readinessEndpoint)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, reports, 2)
+
+	// the node is only ready if all APIs are
+	update("posts", false)
+	code, reports = get(readinessEndpoint)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, healthcheck.StatusNotReady, reports["posts"].Status)
+	assert.Equal(t, healthcheck.StatusReady, reports["users"].Status)
+
+	// liveness doesn't depend on the APIs
+	w := httptest.NewRecorder()
+	tenants.ServeHTTP(w, httptest.NewRequest(http.MethodGet, livenessEndpoint, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-cache, no-store, must-revalidate", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `"buildInfo"`)
+}
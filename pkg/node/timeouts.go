@@ -0,0 +1,134 @@
+package node
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// streamHandler serves long-lived streams, e.g. subscriptions and live queries
+type streamHandler interface {
+	isStream(r *http.Request) bool
+}
+
+// withTimeouts enforces the read and write timeouts per request instead of per connection like http.Server,
+// so streams can follow their own timeout policy.
+// The read timeout only applies to the request body, the write timeout to the whole request.
+// Requests which aren't known to be streams upfront, e.g. subscriptions to the GraphQL endpoint,
+// switch to the stream timeout once they flush their response.
+// HTTP/1 requests get deadlines on their connection, HTTP/2 requests share it with other streams
+// and are canceled once their timeout elapsed instead.
+func withTimeouts(handler http.Handler, server *Server) http.Handler {
+	readTimeout := time.Duration(server.ReadTimeout) * time.Second
+	writeTimeout := time.Duration(server.WriteTimeout) * time.Second
+	streamTimeout := time.Duration(server.StreamTimeout) * time.Second
+	streams, _ := handler.(streamHandler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read, write := readTimeout, writeTimeout
+		if streams != nil && streams.isStream(r) {
+			read, write = streamTimeout, streamTimeout
+		}
+		var onStream func()
+		if r.ProtoMajor < 2 {
+			conn, ok := r.Context().Value("conn").(net.Conn)
+			if !ok {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			_ = conn.SetWriteDeadline(deadline(write))
+			// once the body is consumed, the connection is read in the background to detect clients going away,
+			// a read deadline would cancel the request
+			if r.Body != nil && r.Body != http.NoBody {
+				_ = conn.SetReadDeadline(deadline(read))
+				r.Body = &deadlineBody{ReadCloser: r.Body, conn: conn}
+			} else {
+				_ = conn.SetReadDeadline(time.Time{})
+			}
+			onStream = func() {
+				_ = conn.SetWriteDeadline(deadline(streamTimeout))
+			}
+		} else {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			var (
+				mu    sync.Mutex
+				timer *time.Timer
+			)
+			if write > 0 {
+				timer = time.AfterFunc(write, cancel)
+			}
+			defer func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if timer != nil {
+					timer.Stop()
+				}
+			}()
+			onStream = func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if timer != nil && !timer.Stop() {
+					// the request timed out already
+					return
+				}
+				timer = nil
+				if streamTimeout > 0 {
+					timer = time.AfterFunc(streamTimeout, cancel)
+				}
+			}
+			r = r.WithContext(ctx)
+		}
+		handler.ServeHTTP(&timeoutWriter{ResponseWriter: w, onStream: onStream}, r)
+	})
+}
+
+// timeoutWriter switches the request to the stream timeout on the first flush
+type timeoutWriter struct {
+	http.ResponseWriter
+	once     sync.Once
+	onStream func()
+}
+
+func (w *timeoutWriter) Flush() {
+	w.once.Do(w.onStream)
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// deadlineBody clears the read deadline of the connection once the body is consumed
+type deadlineBody struct {
+	io.ReadCloser
+	conn net.Conn
+	once sync.Once
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.clearDeadline()
+	}
+	return n, err
+}
+
+func (b *deadlineBody) Close() error {
+	b.clearDeadline()
+	return b.ReadCloser.Close()
+}
+
+func (b *deadlineBody) clearDeadline() {
+	b.once.Do(func() {
+		_ = b.conn.SetReadDeadline(time.Time{})
+	})
+}
+
+// deadline returns the deadline of timeout, no deadline if timeout is 0
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
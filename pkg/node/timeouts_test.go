@@ -0,0 +1,129 @@
+package node
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sleepHandler sleeps for the duration in the sleep query parameter, streams are requested with stream=true
+// or started by flushing the response with flush=true
+type sleepHandler struct{}
+
+func (sleepHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, err := io.Copy(io.Discard, r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("flush") == "true" {
+		w.(http.Flusher).Flush()
+	}
+	duration, _ := time.ParseDuration(r.URL.Query().Get("sleep"))
+	select {
+	case <-time.After(duration):
+		_, _ = w.Write([]byte("done"))
+	case <-r.Context().Done():
+	}
+}
+
+func (sleepHandler) isStream(r *http.Request) bool {
+	return r.URL.Query().Get("stream") == "true"
+}
+
+// serveWithTimeouts serves sleepHandler with the timeouts of server and returns its URL
+func serveWithTimeouts(t *testing.T, server *Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{
+		Handler: withTimeouts(sleepHandler{}, server),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, "conn", c)
+		},
+	}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+	return "http://" + l.Addr().String()
+}
+
+func readBody(res *http.Response, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return string(body), err
+}
+
+func TestWithTimeouts(t *testing.T) {
+	url := serveWithTimeouts(t, &Server{
+		ReadTimeout:  1,
+		WriteTimeout: 1,
+	})
+	get := func(query string) (string, error) {
+		return readBody(http.Get(url + "/?" + query))
+	}
+
+	body, err := get("sleep=10ms")
+	require.NoError(t, err)
+	assert.Equal(t, "done", body)
+
+	// the write timeout cuts off slow requests
+	_, err = get("sleep=1500ms")
+	assert.Error(t, err)
+
+	// streams aren't limited without a stream timeout
+	body, err = get("sleep=1500ms&stream=true")
+	require.NoError(t, err)
+	assert.Equal(t, "done", body)
+
+	// requests flushing their response turn into streams, e.g. subscriptions to the GraphQL endpoint
+	body, err = get("sleep=1500ms&flush=true")
+	require.NoError(t, err)
+	assert.Equal(t, "done", body)
+
+	// deadlines of streams don't leak into later requests on the same connection
+	body, err = get("sleep=10ms")
+	require.NoError(t, err)
+	assert.Equal(t, "done", body)
+}
+
+func TestWithTimeouts_ReadTimeout(t *testing.T) {
+	url := serveWithTimeouts(t, &Server{
+		ReadTimeout:  1,
+		WriteTimeout: 3,
+	})
+
+	// the read timeout only applies to reading the body
+	body, err := readBody(http.Get(url + "/?sleep=1500ms"))
+	require.NoError(t, err)
+	assert.Equal(t, "done", body)
+
+	body, err = readBody(http.Post(url+"/?sleep=1500ms", "application/json", strings.NewReader(`{"query":"{__typename}"}`)))
+	require.NoError(t, err)
+	assert.Equal(t, "done", body)
+
+	// slow bodies are cut off
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte(`{"query":`))
+		time.Sleep(1500 * time.Millisecond)
+		_, _ = pw.Write([]byte(`"{__typename}"}`))
+		_ = pw.Close()
+	}()
+	res, err := http.Post(url+"/", "application/json", pr)
+	if err == nil {
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+}
@@ -0,0 +1,231 @@
+package node
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jensneuse/abstractlogger"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/wundergraph/wundergraph/pkg/apicache"
+	"github.com/wundergraph/wundergraph/pkg/apihandler"
+	"github.com/wundergraph/wundergraph/pkg/autotls"
+	"github.com/wundergraph/wundergraph/pkg/wgpb"
+)
+
+const (
+	// certReloadInterval limits how often the certificate files are checked for changes
+	certReloadInterval = 5 * time.Second
+	// acmeRedisKeyPrefix namespaces the ACME certificates in the Redis of the API cache
+	acmeRedisKeyPrefix = "wundergraph:acme:"
+)
+
+// hostsHandler serves APIs restricted to their allowed hosts
+type hostsHandler interface {
+	allowedHosts() []string
+}
+
+// newTLSConfig terminates TLS with the certificate of the listener, verifying client certificates if a client CA is configured
+func newTLSConfig(options apihandler.ListenerTLS, log abstractlogger.Logger) (*tls.Config, error) {
+	if options.CertFile == "" || options.KeyFile == "" {
+		return nil, errors.New("TLS requires a certFile and a keyFile")
+	}
+	reloader, err := newCertReloader(options.CertFile, options.KeyFile, log)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if options.DisableHTTP2 {
+		config.NextProtos = []string{"http/1.1"}
+	}
+	if err := configureClientAuth(config, options); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// newACMETLSConfig terminates TLS with certificates obtained through ACME for the allowed hosts,
+// hosts is called for every new host, so reloaded configs can allow new hosts
+func (n *Node) newACMETLSConfig(nodeConfig WunderNodeConfig, hosts func() []string) (*tls.Config, error) {
+	options := nodeConfig.Api.Options.Listener.TLS
+	if len(hosts()) == 0 {
+		return nil, errors.New("ACME requires allowedHostNames to request certificates for")
+	}
+	var cache autocert.Cache
+	switch options.ACME.Storage {
+	case wgpb.AcmeStorageKind_REDIS_ACME_STORAGE:
+		redisOptions, err := apihandler.RedisCacheOptions(nodeConfig.Api.CacheConfig.GetRedisConfig())
+		if err != nil {
+			return nil, fmt.Errorf("ACME redis storage: %w", err)
+		}
+		client, err := apicache.NewRedisClient(redisOptions)
+		if err != nil {
+			return nil, fmt.Errorf("ACME redis storage: %w", err)
+		}
+		go func() {
+			<-n.ctx.Done()
+			_ = client.Close()
+		}()
+		cache = autotls.NewRedisCache(client, acmeRedisKeyPrefix)
+	default:
+		if options.ACME.StorageDir == "" {
+			return nil, errors.New("ACME disk storage requires a storageDir")
+		}
+		cache = autocert.DirCache(options.ACME.StorageDir)
+	}
+	manager, err := autotls.NewManager(autotls.Options{
+		Email:           options.ACME.Email,
+		DirectoryURL:    options.ACME.DirectoryURL,
+		DirectoryCAFile: options.ACME.DirectoryCAFile,
+		Hosts:           hosts,
+		Cache:           cache,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var solver autotls.ChallengeSolver = autotls.TLSALPNSolver{}
+	if options.ACME.Challenge == wgpb.AcmeChallengeKind_HTTP_ACME_CHALLENGE {
+		solver = autotls.HTTPSolver{
+			Addr: options.ACME.HTTPChallengeAddress,
+			Log:  n.log,
+		}
+	}
+	if err := solver.Start(n.ctx, manager); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: manager.GetCertificate,
+		NextProtos:     append([]string{"h2", "http/1.1"}, solver.NextProtos()...),
+	}
+	if options.DisableHTTP2 {
+		config.NextProtos = append([]string{"http/1.1"}, solver.NextProtos()...)
+	}
+	if err := configureClientAuth(config, options); err != nil {
+		return nil, err
+	}
+	if config.ClientAuth != tls.NoClientCert {
+		// ACME servers validating TLS-ALPN-01 challenges don't present client certificates
+		withoutClientAuth := config.Clone()
+		withoutClientAuth.ClientAuth = tls.NoClientCert
+		config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			for _, proto := range hello.SupportedProtos {
+				if proto == acme.ALPNProto {
+					return withoutClientAuth, nil
+				}
+			}
+			return nil, nil
+		}
+	}
+	return config, nil
+}
+
+// configureClientAuth verifies client certificates if a client CA is configured
+func configureClientAuth(config *tls.Config, options apihandler.ListenerTLS) error {
+	if options.ClientCAFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(options.ClientCAFile)
+	if err != nil {
+		return fmt.Errorf("could not read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("client CA file %s contains no PEM encoded certificates", options.ClientCAFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	if options.ClientCertOptional {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return nil
+}
+
+// certReloader serves a certificate and reloads it once its files changed,
+// so rotated certificates are picked up without restarting the node
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      abstractlogger.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string, log abstractlogger.Logger) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		log:      log,
+		checked:  time.Now(),
+	}
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) < certReloadInterval {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+	modTime, err := r.filesModTime()
+	if err != nil || modTime.Equal(r.modTime) {
+		return r.cert, nil
+	}
+	// rotations might replace the certificate and the key one after another,
+	// failed reloads keep the previous certificate and are retried on the next check
+	if err := r.load(modTime); err != nil {
+		r.log.Error("could not reload TLS certificate, serving the previous one",
+			abstractlogger.String("certFile", r.certFile),
+			abstractlogger.Error(err),
+		)
+	}
+	return r.cert, nil
+}
+
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load TLS certificate: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// filesModTime returns the latest modification time of the certificate and key files
+func (r *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
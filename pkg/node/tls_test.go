@@ -0,0 +1,188 @@
+package node
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jensneuse/abstractlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+
+	"github.com/wundergraph/wundergraph/pkg/apihandler"
+)
+
+// writeCert writes a certificate for localhost signed by parent, it's self-signed if parent is nil
+func writeCert(t *testing.T, dir, name string, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	issuer, signer := template, interface{}(key)
+	if parent != nil {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600))
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	cert.Leaf, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeCert(t, dir, "ca", nil)
+	writeCert(t, dir, "server", &ca)
+	client := writeCert(t, dir, "client", &ca)
+
+	tlsConfig, err := newTLSConfig(apihandler.ListenerTLS{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}, abstractlogger.NoopLogger)
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}),
+		TLSConfig: tlsConfig,
+	}
+	go func() {
+		_ = srv.ServeTLS(l, "", "")
+	}()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      roots,
+					Certificates: certs,
+				},
+				ForceAttemptHTTP2: true,
+			},
+		}
+	}
+	url := "https://" + l.Addr().String()
+
+	res, err := newClient(client).Get(url)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, 2, res.ProtoMajor)
+	assert.Equal(t, "server", res.TLS.PeerCertificates[0].Subject.CommonName)
+
+	// mTLS rejects clients without a certificate
+	_, err = newClient().Get(url)
+	assert.Error(t, err)
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, dir, "server", nil)
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+
+	reloader, err := newCertReloader(certFile, keyFile, abstractlogger.NoopLogger)
+	require.NoError(t, err)
+	first, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+
+	// rotate the certificate
+	rotated := writeCert(t, dir, "server", nil)
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	cert, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Same(t, first, cert, "files are only checked every certReloadInterval")
+
+	reloader.checked = time.Now().Add(-certReloadInterval)
+	cert, err = reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, rotated.Certificate, cert.Certificate)
+
+	// broken rotations keep the previous certificate
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0600))
+	evenLater := later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(keyFile, evenLater, evenLater))
+	reloader.checked = time.Now().Add(-certReloadInterval)
+	cert, err = reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, rotated.Certificate, cert.Certificate)
+}
+
+func TestNewACMETLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, dir, "ca", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := &Node{ctx: ctx, log: abstractlogger.NoopLogger}
+	config := WunderNodeConfig{
+		Api: &apihandler.Api{
+			Options: &apihandler.Options{
+				Listener: &apihandler.Listener{
+					TLS: apihandler.ListenerTLS{
+						ClientCAFile: filepath.Join(dir, "ca.crt"),
+						ACME: apihandler.ListenerACME{
+							Enabled:    true,
+							StorageDir: filepath.Join(dir, "acme"),
+						},
+					},
+				},
+			},
+		},
+	}
+	hosts := []string{"api.example.com"}
+
+	tlsConfig, err := n.newACMETLSConfig(config, func() []string { return hosts })
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h2", "http/1.1", acme.ALPNProto}, tlsConfig.NextProtos)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+
+	// ACME servers validating challenges don't present client certificates
+	challengeConfig, err := tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{SupportedProtos: []string{acme.ALPNProto}})
+	require.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, challengeConfig.ClientAuth)
+	clientConfig, err := tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{SupportedProtos: []string{"h2"}})
+	require.NoError(t, err)
+	assert.Nil(t, clientConfig)
+
+	hosts = nil
+	_, err = n.newACMETLSConfig(config, func() []string { return hosts })
+	assert.ErrorContains(t, err, "allowedHostNames")
+}
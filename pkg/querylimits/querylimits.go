@@ -0,0 +1,279 @@
+// Package querylimits restricts the depth, size and cost of GraphQL operations.
+//
+// The cost of an operation is the number of fields it may resolve. Every field
+// costs 1, multiplied by the sizes of the lists it is nested in. The size of a
+// list is read from the first, last or limit argument of the list field,
+// falling back to a configurable default.
+package querylimits
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/buger/jsonparser"
+	"github.com/wundergraph/graphql-go-tools/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/pkg/astvisitor"
+	"github.com/wundergraph/graphql-go-tools/pkg/operationreport"
+)
+
+// maxCost caps the computed cost to avoid overflows with huge list sizes
+const maxCost = 1 << 31
+
+// listSizeArguments are the field arguments determining the size of a returned list
+var listSizeArguments = []string{"first", "last", "limit"}
+
+// Limits configures the maximum depth, number of fields and cost of an operation.
+// A zero value disables the respective limit.
+type Limits struct {
+	MaxDepth  int
+	MaxFields int
+	MaxCost   int
+	// DefaultListSize is the size assumed for lists without a list size argument,
+	// defaults to 1
+	DefaultListSize int
+}
+
+func (l Limits) Enabled() bool {
+	return l.MaxDepth > 0 || l.MaxFields > 0 || l.MaxCost > 0
+}
+
+// Stats are reported to clients whose operation exceeds the limits
+type Stats struct {
+	Depth  int `json:"depth"`
+	Fields int `json:"fields"`
+	Cost   int `json:"cost"`
+}
+
+type LimitError struct {
+	Stats   Stats
+	message string
+}
+
+func (e *LimitError) Error() string {
+	return e.message
+}
+
+// Estimate is the variables independent part of the calculation, so it can be
+// cached together with the operation's plan
+type Estimate struct {
+	depth  int
+	fields int
+	// terms holds, for each field, the list sizes it's multiplied with
+	terms [][]listSize
+}
+
+// listSize is either a constant or read from a variable
+type listSize struct {
+	value        int
+	variableName string
+}
+
+// NewEstimate walks the operation selected by operationName in a normalized document. Fragments must be inlined.
+// operationName may be empty if the document contains a single operation, other operations are ignored.
+func NewEstimate(operation, definition *ast.Document, operationName []byte, defaultListSize int) (*Estimate, error) {
+	if defaultListSize <= 0 {
+		defaultListSize = 1
+	}
+
+	operationRef, ok := selectOperation(operation, operationName)
+	if !ok {
+		return nil, fmt.Errorf("operation %q not found", operationName)
+	}
+
+	walker := astvisitor.NewWalker(48)
+	visitor := &estimateVisitor{
+		Walker:          &walker,
+		defaultListSize: defaultListSize,
+		operationRef:    operationRef,
+		estimate:        &Estimate{},
+	}
+	walker.RegisterEnterDocumentVisitor(visitor)
+	walker.RegisterEnterOperationVisitor(visitor)
+	walker.RegisterFieldVisitor(visitor)
+
+	report := &operationreport.Report{}
+	walker.Walk(operation, definition, report)
+	if report.HasErrors() {
+		return nil, report
+	}
+	return visitor.estimate, nil
+}
+
+// selectOperation returns the ref of the operation definition named operationName,
+// or of the only operation definition if operationName is empty
+func selectOperation(operation *ast.Document, operationName []byte) (ref int, ok bool) {
+	if len(operationName) == 0 {
+		return 0, len(operation.OperationDefinitions) == 1
+	}
+	for i := range operation.OperationDefinitions {
+		if bytes.Equal(operation.OperationDefinitionNameBytes(i), operationName) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (e *Estimate) Depth() int {
+	return e.depth
+}
+
+func (e *Estimate) Fields() int {
+	return e.fields
+}
+
+// Cost returns the cost of the operation, reading list sizes from variables
+func (e *Estimate) Cost(variables []byte) int {
+	cost := 0
+	for _, term := range e.terms {
+		fieldCost := 1
+		for _, size := range term {
+			fieldCost = saturatingMul(fieldCost, size.resolve(variables))
+		}
+		cost += fieldCost
+		if cost >= maxCost {
+			return maxCost
+		}
+	}
+	return cost
+}
+
+// Check returns a *LimitError if the operation exceeds the depth, field or cost limits
+func (l Limits) Check(estimate *Estimate, variables []byte) error {
+	stats := Stats{
+		Depth:  estimate.Depth(),
+		Fields: estimate.Fields(),
+		Cost:   estimate.Cost(variables),
+	}
+	switch {
+	case l.MaxDepth > 0 && stats.Depth > l.MaxDepth:
+		return &LimitError{Stats: stats, message: fmt.Sprintf("operation depth %d exceeds the maximum depth of %d", stats.Depth, l.MaxDepth)}
+	case l.MaxFields > 0 && stats.Fields > l.MaxFields:
+		return &LimitError{Stats: stats, message: fmt.Sprintf("operation has %d fields, exceeding the maximum of %d", stats.Fields, l.MaxFields)}
+	case l.MaxCost > 0 && stats.Cost > l.MaxCost:
+		return &LimitError{Stats: stats, message: fmt.Sprintf("operation cost %d exceeds the maximum cost of %d", stats.Cost, l.MaxCost)}
+	}
+	return nil
+}
+
+// CheckStatic is like Check, but skips the variables dependent cost limit
+func (l Limits) CheckStatic(estimate *Estimate) error {
+	return Limits{MaxDepth: l.MaxDepth, MaxFields: l.MaxFields}.Check(estimate, nil)
+}
+
+func (s listSize) resolve(variables []byte) int {
+	value := int64(s.value)
+	if s.variableName != "" {
+		if variableValue, err := jsonparser.GetInt(variables, s.variableName); err == nil {
+			value = variableValue
+		}
+	}
+	if value < 0 {
+		return 0
+	}
+	if value > maxCost {
+		return maxCost
+	}
+	return int(value)
+}
+
+func saturatingMul(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a > maxCost/b {
+		return maxCost
+	}
+	return a * b
+}
+
+type estimateVisitor struct {
+	*astvisitor.Walker
+	operation, definition *ast.Document
+	defaultListSize       int
+
+	// operationRef is the operation to walk, all others are skipped
+	operationRef int
+	depth        int
+	// listSizes is the stack of list sizes of the enclosing list fields
+	listSizes []listSize
+	// listFields tracks which entered fields pushed onto listSizes
+	listFields []bool
+
+	estimate *Estimate
+}
+
+func (v *estimateVisitor) EnterDocument(operation, definition *ast.Document) {
+	v.operation = operation
+	v.definition = definition
+}
+
+func (v *estimateVisitor) EnterOperationDefinition(ref int) {
+	if ref != v.operationRef {
+		v.SkipNode()
+	}
+}
+
+func (v *estimateVisitor) EnterField(ref int) {
+	v.depth++
+	if v.depth > v.estimate.depth {
+		v.estimate.depth = v.depth
+	}
+	v.estimate.fields++
+	v.estimate.terms = append(v.estimate.terms, v.listSizes)
+
+	definition, ok := v.FieldDefinition(ref)
+	isList := ok && v.definition.TypeIsList(v.definition.FieldDefinitionType(definition))
+	v.listFields = append(v.listFields, isList)
+	if isList {
+		// full slice expression, so siblings don't share the appended element
+		v.listSizes = append(v.listSizes[:len(v.listSizes):len(v.listSizes)], v.fieldListSize(ref))
+	}
+}
+
+func (v *estimateVisitor) LeaveField(ref int) {
+	v.depth--
+	if v.listFields[len(v.listFields)-1] {
+		v.listSizes = v.listSizes[:len(v.listSizes)-1]
+	}
+	v.listFields = v.listFields[:len(v.listFields)-1]
+}
+
+func (v *estimateVisitor) fieldListSize(ref int) listSize {
+	size := listSize{value: v.defaultListSize}
+	for _, argument := range v.operation.FieldArguments(ref) {
+		if !isListSizeArgument(v.operation.ArgumentNameString(argument)) {
+			continue
+		}
+		value := v.operation.ArgumentValue(argument)
+		switch value.Kind {
+		case ast.ValueKindInteger:
+			size.value = int(v.operation.IntValueAsInt(value.Ref))
+		case ast.ValueKindVariable:
+			size.variableName = v.operation.VariableValueNameString(value.Ref)
+			size.value = v.variableDefaultValue(value.Ref, size.value)
+		}
+		return size
+	}
+	return size
+}
+
+func (v *estimateVisitor) variableDefaultValue(variableValueRef, fallback int) int {
+	definition, ok := v.operation.VariableDefinitionByNameAndOperation(v.operationRef, v.operation.VariableValueNameBytes(variableValueRef))
+	if !ok || !v.operation.VariableDefinitionHasDefaultValue(definition) {
+		return fallback
+	}
+	value := v.operation.VariableDefinitionDefaultValue(definition)
+	if value.Kind != ast.ValueKindInteger {
+		return fallback
+	}
+	return int(v.operation.IntValueAsInt(value.Ref))
+}
+
+func isListSizeArgument(name string) bool {
+	for _, argument := range listSizeArguments {
+		if name == argument {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package querylimits
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/buger/jsonparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/pkg/astnormalization"
+	"github.com/wundergraph/graphql-go-tools/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/pkg/asttransform"
+	"github.com/wundergraph/graphql-go-tools/pkg/operationreport"
+)
+
+const schema = `
+type Query {
+	users(first: Int, after: String): [User!]!
+	user(id: ID!): User
+}
+
+type User {
+	id: ID!
+	name: String!
+	friends(limit: Int): [User!]!
+	tags: [String!]!
+}
+`
+
+// estimate returns the Estimate of the normalized operation together with the
+// variables extracted by the normalization, which would be merged into the request variables
+func estimate(t *testing.T, operation string, defaultListSize int) (*Estimate, []byte) {
+	definition, report := astparser.ParseGraphqlDocumentString(schema)
+	require.False(t, report.HasErrors(), report.Error())
+	require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+	doc, report := astparser.ParseGraphqlDocumentString(operation)
+	require.False(t, report.HasErrors(), report.Error())
+
+	report = operationreport.Report{}
+	astnormalization.NewNormalizer(true, true).NormalizeOperation(&doc, &definition, &report)
+	require.False(t, report.HasErrors(), report.Error())
+
+	estimate, err := NewEstimate(&doc, &definition, nil, defaultListSize)
+	require.NoError(t, err)
+	return estimate, doc.Input.Variables
+}
+
+func TestEstimate(t *testing.T) {
+	t.Run("list size from arguments", func(t *testing.T) {
+		e, variables := estimate(t, `{ users(first: 10) { id friends(limit: 5) { name } } }`, 0)
+		assert.Equal(t, 3, e.Depth())
+		assert.Equal(t, 4, e.Fields())
+		// users + 10 * (id + friends) + 10 * 5 * name
+		assert.Equal(t, 1+10*2+10*5, e.Cost(variables))
+	})
+	t.Run("default list size", func(t *testing.T) {
+		e, variables := estimate(t, `{ user(id: "1") { tags friends { id } } }`, 20)
+		assert.Equal(t, 3, e.Depth())
+		assert.Equal(t, 4, e.Fields())
+		assert.Equal(t, 1+1+1+20, e.Cost(variables))
+	})
+	t.Run("fragments", func(t *testing.T) {
+		e, variables := estimate(t, `{ users(first: 2) { ...UserFields } } fragment UserFields on User { id name }`, 0)
+		assert.Equal(t, 2, e.Depth())
+		assert.Equal(t, 3, e.Fields())
+		assert.Equal(t, 1+2*2, e.Cost(variables))
+	})
+	t.Run("list size from variables", func(t *testing.T) {
+		e, variables := estimate(t, `query Users($first: Int = 3) { users(first: $first) { id } }`, 0)
+		assert.Equal(t, 1+3, e.Cost(variables))
+		assert.Equal(t, 1+100, e.Cost([]byte(`{"first":100}`)))
+		assert.Equal(t, 1, e.Cost([]byte(`{"first":-1}`)))
+		assert.Equal(t, maxCost, e.Cost([]byte(`{"first":100000000000}`)))
+	})
+}
+
+func TestEstimate_MultipleOperations(t *testing.T) {
+	definition, report := astparser.ParseGraphqlDocumentString(schema)
+	require.False(t, report.HasErrors(), report.Error())
+	require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+	doc, report := astparser.ParseGraphqlDocumentString(`
+		query Users { users(first: 100) { id friends(limit: 100) { id } } }
+		query User { user(id: "1") { id name } }
+	`)
+	require.False(t, report.HasErrors(), report.Error())
+
+	report = operationreport.Report{}
+	astnormalization.NewNormalizer(true, true).NormalizeNamedOperation(&doc, &definition, []byte("User"), &report)
+	require.False(t, report.HasErrors(), report.Error())
+
+	// only the executed operation is estimated
+	e, err := NewEstimate(&doc, &definition, []byte("User"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, e.Depth())
+	assert.Equal(t, 3, e.Fields())
+	assert.Equal(t, 3, e.Cost(doc.Input.Variables))
+
+	_, err = NewEstimate(&doc, &definition, []byte("Unknown"), 0)
+	assert.Error(t, err)
+	_, err = NewEstimate(&doc, &definition, nil, 0)
+	assert.Error(t, err)
+}
+
+func TestLimits_Check(t *testing.T) {
+	e, extractedVariables := estimate(t, `query Users($first: Int) { users(first: $first) { id friends(limit: 10) { id } } }`, 0)
+	variables := func(first int) []byte {
+		out, err := jsonparser.Set(extractedVariables, []byte(strconv.Itoa(first)), "first")
+		require.NoError(t, err)
+		return out
+	}
+
+	assert.NoError(t, Limits{}.Check(e, variables(1000)))
+	assert.NoError(t, Limits{MaxDepth: 3, MaxFields: 4, MaxCost: 121}.Check(e, variables(10)))
+
+	err := Limits{MaxDepth: 2}.Check(e, nil)
+	require.IsType(t, &LimitError{}, err)
+	assert.Equal(t, "operation depth 3 exceeds the maximum depth of 2", err.Error())
+
+	err = Limits{MaxFields: 3}.Check(e, nil)
+	require.IsType(t, &LimitError{}, err)
+	assert.Equal(t, "operation has 4 fields, exceeding the maximum of 3", err.Error())
+
+	err = Limits{MaxCost: 100}.Check(e, variables(10))
+	require.IsType(t, &LimitError{}, err)
+	assert.Equal(t, "operation cost 121 exceeds the maximum cost of 100", err.Error())
+	assert.Equal(t, Stats{Depth: 3, Fields: 4, Cost: 121}, err.(*LimitError).Stats)
+
+	assert.NoError(t, Limits{MaxCost: 100}.CheckStatic(e))
+}
@@ -0,0 +1,85 @@
+package remoteconfig
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type HTTPOptions struct {
+	URL string
+	// SignatureURL defaults to URL with SignatureSuffix
+	SignatureURL string
+	// Header is sent with both requests, e.g. for authorization
+	Header http.Header
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// NewHTTPSource fetches the config from a URL, it uses the ETag of the config as version
+func NewHTTPSource(options HTTPOptions) Source {
+	if options.SignatureURL == "" {
+		options.SignatureURL = options.URL + SignatureSuffix
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	return &httpSource{
+		options: options,
+	}
+}
+
+type httpSource struct {
+	options HTTPOptions
+}
+
+func (s *httpSource) String() string {
+	return s.options.URL
+}
+
+func (s *httpSource) Fetch(ctx context.Context, version string) (*Document, error) {
+	config, etag, err := s.get(ctx, s.options.URL, version)
+	if err != nil {
+		return nil, err
+	}
+	signature, _, err := s.get(ctx, s.options.SignatureURL, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		Config:    config,
+		Signature: signature,
+		Version:   etag,
+	}, nil
+}
+
+func (s *httpSource) get(ctx context.Context, url, etag string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for name, values := range s.options.Header {
+		req.Header[name] = values
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	res, err := s.options.Client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, etag, ErrNotModified
+	default:
+		return nil, "", fmt.Errorf("GET %s: unexpected status %d", url, res.StatusCode)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, res.Header.Get("ETag"), nil
+}
@@ -0,0 +1,121 @@
+// Package remoteconfig loads the WunderGraph configuration from remote sources, e.g. HTTP(S) URLs or S3-compatible buckets.
+//
+// Configs are only applied with a valid detached Ed25519 signature, stored next to the config.
+// Signatures are stored raw (64 bytes) or base64 encoded, e.g. signed with:
+//
+//	openssl pkeyutl -sign -inkey private.pem -rawin -in wundergraph.config.json -out wundergraph.config.json.sig
+package remoteconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/wundergraph/wundergraph/pkg/wgpb"
+)
+
+var (
+	// ErrNotModified is returned by sources if the config didn't change since the given version
+	ErrNotModified = errors.New("config not modified")
+	// ErrInvalidSignature is returned for configs whose signature doesn't match the public key
+	ErrInvalidSignature = errors.New("invalid config signature")
+)
+
+// SignatureSuffix is appended to the location of a config to find its signature by default
+const SignatureSuffix = ".sig"
+
+// Document is a config as fetched from a source
+type Document struct {
+	Config    []byte
+	Signature []byte
+	// Version identifies the config, e.g. by its ETag
+	Version string
+}
+
+// Source fetches configs
+type Source interface {
+	// Fetch returns the current config, or ErrNotModified if its version equals version
+	Fetch(ctx context.Context, version string) (*Document, error)
+	// String describes the location of the config for logging
+	String() string
+}
+
+// Load fetches the config from source and verifies its signature.
+// It returns ErrNotModified if the config didn't change since version.
+func Load(ctx context.Context, source Source, publicKey ed25519.PublicKey, version string) (*wgpb.WunderGraphConfiguration, string, error) {
+	doc, err := source.Fetch(ctx, version)
+	if err != nil {
+		return nil, version, err
+	}
+	if doc.Version == "" {
+		doc.Version = contentVersion(doc)
+	}
+	if version != "" && doc.Version == version {
+		return nil, version, ErrNotModified
+	}
+	if err := Verify(publicKey, doc.Config, doc.Signature); err != nil {
+		return nil, version, err
+	}
+	var config wgpb.WunderGraphConfiguration
+	if err := json.Unmarshal(doc.Config, &config); err != nil {
+		return nil, version, fmt.Errorf("invalid config: %w", err)
+	}
+	return &config, doc.Version, nil
+}
+
+// contentVersion identifies documents of sources without versions by their content
+func contentVersion(doc *Document) string {
+	hash := sha256.New()
+	_, _ = hash.Write(doc.Config)
+	_, _ = hash.Write(doc.Signature)
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// Verify checks the detached signature of config, the signature is either raw or base64 encoded
+func Verify(publicKey ed25519.PublicKey, config, signature []byte) error {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("missing Ed25519 public key to verify the config signature")
+	}
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+		if err != nil || len(decoded) != ed25519.SignatureSize {
+			return fmt.Errorf("%w: signature must be %d bytes, raw or base64 encoded", ErrInvalidSignature, ed25519.SignatureSize)
+		}
+		signature = decoded
+	}
+	if !ed25519.Verify(publicKey, config, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ParsePublicKey parses a PEM encoded (PKIX) or base64 encoded raw Ed25519 public key
+func ParsePublicKey(key string) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode([]byte(key)); block != nil {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key must be Ed25519, got %T", parsed)
+		}
+		return publicKey, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace([]byte(key))))
+	if err != nil {
+		return nil, fmt.Errorf("public key must be PEM or base64 encoded: %w", err)
+	}
+	if len(decoded) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(decoded))
+	}
+	return decoded, nil
+}
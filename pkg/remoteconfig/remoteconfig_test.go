@@ -0,0 +1,116 @@
+package remoteconfig
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_HTTPSource(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	config := []byte(`{"apiId":"1"}`)
+	signature := ed25519.Sign(privateKey, config)
+	etag := `"v1"`
+	var configRequests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/wundergraph.config.json":
+			configRequests++
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			_, _ = w.Write(config)
+		case "/wundergraph.config.json.sig":
+			_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(signature) + "\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	source := NewHTTPSource(HTTPOptions{
+		URL:    srv.URL + "/wundergraph.config.json",
+		Header: http.Header{"Authorization": []string{"Bearer token"}},
+	})
+	ctx := context.Background()
+
+	loaded, version, err := Load(ctx, source, publicKey, "")
+	require.NoError(t, err)
+	assert.Equal(t, "1", loaded.ApiId)
+	assert.Equal(t, etag, version)
+
+	_, version, err = Load(ctx, source, publicKey, version)
+	assert.ErrorIs(t, err, ErrNotModified)
+	assert.Equal(t, etag, version)
+	assert.Equal(t, 2, configRequests)
+
+	// configs signed with another key are rejected
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, version, err = Load(ctx, source, otherKey, "")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	assert.Equal(t, "", version)
+
+	config = []byte(`{"apiId":"2"}`)
+	etag = `"v2"`
+	_, _, err = Load(ctx, source, publicKey, `"v1"`)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestLoad_ContentVersion(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	config := []byte(`{"apiId":"1"}`)
+	source := staticSource{
+		Config:    config,
+		Signature: ed25519.Sign(privateKey, config),
+	}
+
+	_, version, err := Load(context.Background(), source, publicKey, "")
+	require.NoError(t, err)
+	assert.Contains(t, version, "sha256:")
+	_, _, err = Load(context.Background(), source, publicKey, version)
+	assert.ErrorIs(t, err, ErrNotModified)
+}
+
+func TestParsePublicKey(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	parsed, err := ParsePublicKey(base64.StdEncoding.EncodeToString(publicKey))
+	require.NoError(t, err)
+	assert.Equal(t, publicKey, parsed)
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	parsed, err = ParsePublicKey(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	require.NoError(t, err)
+	assert.Equal(t, publicKey, parsed)
+
+	_, err = ParsePublicKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
+
+type staticSource Document
+
+func (s staticSource) Fetch(context.Context, string) (*Document, error) {
+	doc := Document(s)
+	return &doc, nil
+}
+
+func (s staticSource) String() string {
+	return "static"
+}
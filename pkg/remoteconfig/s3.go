@@ -0,0 +1,106 @@
+package remoteconfig
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type S3Options struct {
+	// Endpoint of the S3-compatible service, e.g. s3.amazonaws.com
+	Endpoint string
+	Bucket   string
+	Key      string
+	// SignatureKey defaults to Key with SignatureSuffix
+	SignatureKey string
+	Region       string
+	UseSSL       bool
+	// AccessKeyID and SecretAccessKey default to the environment (AWS_* or MINIO_*) or the IAM role of the instance
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// NewS3Source fetches the config from an S3-compatible bucket, it uses the ETag of the object as version
+func NewS3Source(options S3Options) (Source, error) {
+	if options.SignatureKey == "" {
+		options.SignatureKey = options.Key + SignatureSuffix
+	}
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.IAM{
+			Client: &http.Client{
+				Transport: http.DefaultTransport,
+			},
+		},
+	})
+	if options.AccessKeyID != "" {
+		creds = credentials.NewStaticV4(options.AccessKeyID, options.SecretAccessKey, "")
+	}
+	client, err := minio.New(options.Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: options.UseSSL,
+		Region: options.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Source{
+		client:  client,
+		options: options,
+	}, nil
+}
+
+type s3Source struct {
+	client  *minio.Client
+	options S3Options
+}
+
+func (s *s3Source) String() string {
+	return fmt.Sprintf("s3://%s/%s", s.options.Bucket, s.options.Key)
+}
+
+func (s *s3Source) Fetch(ctx context.Context, version string) (*Document, error) {
+	info, err := s.client.StatObject(ctx, s.options.Bucket, s.options.Key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if version != "" && info.ETag == version {
+		return nil, ErrNotModified
+	}
+	// the ETag makes sure the config isn't replaced between both requests
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetMatchETag(info.ETag); err != nil {
+		return nil, err
+	}
+	config, err := s.get(ctx, s.options.Key, opts)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := s.get(ctx, s.options.SignatureKey, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		Config:    config,
+		Signature: signature,
+		Version:   info.ETag,
+	}, nil
+}
+
+func (s *s3Source) get(ctx context.Context, key string, opts minio.GetObjectOptions) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.options.Bucket, key, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, fmt.Errorf("s3://%s/%s: %w", s.options.Bucket, key, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// FileExporter writes finished spans to a file, one JSON object per line
+type FileExporter struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+type fileSpan struct {
+	TraceID           string                 `json:"traceId"`
+	SpanID            string                 `json:"spanId"`
+	ParentSpanID      string                 `json:"parentSpanId,omitempty"`
+	Name              string                 `json:"name"`
+	Kind              string                 `json:"kind"`
+	StartTime         time.Time              `json:"startTime"`
+	EndTime           time.Time              `json:"endTime"`
+	Attributes        map[string]interface{} `json:"attributes,omitempty"`
+	Status            string                 `json:"status"`
+	StatusDescription string                 `json:"statusDescription,omitempty"`
+}
+
+func NewFileExporter(filePath string) (*FileExporter, error) {
+	if filePath == "" {
+		return nil, errors.New("file exporter requires a file path")
+	}
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+func (e *FileExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.file == nil {
+		return errors.New("file exporter is shut down")
+	}
+
+	for _, span := range spans {
+		out := fileSpan{
+			TraceID:           span.SpanContext().TraceID().String(),
+			SpanID:            span.SpanContext().SpanID().String(),
+			Name:              span.Name(),
+			Kind:              span.SpanKind().String(),
+			StartTime:         span.StartTime(),
+			EndTime:           span.EndTime(),
+			Status:            span.Status().Code.String(),
+			StatusDescription: span.Status().Description,
+		}
+		if span.Parent().HasSpanID() {
+			out.ParentSpanID = span.Parent().SpanID().String()
+		}
+		if attributes := span.Attributes(); len(attributes) != 0 {
+			out.Attributes = make(map[string]interface{}, len(attributes))
+			for _, attribute := range attributes {
+				out.Attributes[string(attribute.Key)] = attribute.Value.AsInterface()
+			}
+		}
+		if err := e.encoder.Encode(out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *FileExporter) Shutdown(_ context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.file == nil {
+		return nil
+	}
+	err := e.file.Close()
+	e.file = nil
+	return err
+}
@@ -0,0 +1,150 @@
+// Package tracing implements OpenTelemetry distributed tracing for the WunderNode.
+//
+// Instrumented components always use the global TracerProvider and propagator,
+// which are no-ops until Init is called. Spans therefore cost next to nothing
+// when tracing is disabled.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/wundergraph/wundergraph/internal/statusrecorder"
+)
+
+const (
+	tracerName  = "github.com/wundergraph/wundergraph"
+	serviceName = "wundergraph-node"
+
+	OperationNameKey = attribute.Key("wg.operation.name")
+	OperationTypeKey = attribute.Key("wg.operation.type")
+)
+
+type Exporter int
+
+const (
+	// ExporterOTLP sends spans to an OpenTelemetry collector using OTLP over HTTP
+	ExporterOTLP Exporter = iota
+	// ExporterFile writes spans to a local file, one JSON object per line
+	ExporterFile
+)
+
+type Options struct {
+	Enabled  bool
+	Exporter Exporter
+	// OTLPEndpoint is the URL of the OTLP/HTTP collector, e.g. http://localhost:4318
+	// If empty, the OTEL_EXPORTER_OTLP_* environment variables are used.
+	OTLPEndpoint string
+	// FilePath is the file spans are appended to when using ExporterFile
+	FilePath string
+}
+
+// Init creates a TracerProvider exporting spans as configured by options and
+// installs it, together with the W3C trace context propagator, as the global one.
+// The returned TracerProvider must be shut down to flush pending spans.
+func Init(ctx context.Context, options Options) (*sdktrace.TracerProvider, error) {
+	exporter, err := newExporter(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider, nil
+}
+
+func newExporter(ctx context.Context, options Options) (sdktrace.SpanExporter, error) {
+	switch options.Exporter {
+	case ExporterFile:
+		return NewFileExporter(options.FilePath)
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if options.OTLPEndpoint != "" {
+			endpoint, err := url.Parse(options.OTLPEndpoint)
+			if err != nil {
+				return nil, fmt.Errorf("invalid OTLP endpoint %q: %w", options.OTLPEndpoint, err)
+			}
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint.Host))
+			if endpoint.Path != "" && endpoint.Path != "/" {
+				opts = append(opts, otlptracehttp.WithURLPath(endpoint.Path))
+			}
+			if endpoint.Scheme == "http" {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %d", options.Exporter)
+	}
+}
+
+// Tracer returns the tracer used to instrument the WunderNode
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// InjectHeaders adds the trace context of ctx to the outgoing request headers
+func InjectHeaders(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// EndSpan records err on the span, if not nil, and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// InstrumentOperation wraps handler to trace each request to an operation.
+// A trace context sent by the client in the traceparent header is continued.
+func InstrumentOperation(operationName, operationType string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := Tracer().Start(ctx, fmt.Sprintf("%s %s", operationType, operationName),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				OperationNameKey.String(operationName),
+				OperationTypeKey.String(operationType),
+				semconv.HTTPMethodKey.String(r.Method),
+				semconv.HTTPTargetKey.String(r.URL.RequestURI()),
+			),
+		)
+		defer span.End()
+
+		recorder := statusrecorder.New(w)
+		handler.ServeHTTP(recorder, r.WithContext(ctx))
+
+		statusCode := recorder.StatusCode()
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(statusCode))
+		if statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(statusCode))
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return recorder
+}
+
+func TestInstrumentOperation(t *testing.T) {
+	recorder := useRecorder(t)
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	var upstreamHeaders http.Header
+	handler := InstrumentOperation("Weather", "query", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHeaders = http.Header{}
+		InjectHeaders(r.Context(), upstreamHeaders)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/operations/Weather", nil)
+	req.Header.Set("traceparent", traceparent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "query Weather", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, span.Status().Code)
+
+	// the trace context handed to origins references the operation span
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+span.SpanContext().SpanID().String()+"-01", upstreamHeaders.Get("traceparent"))
+}
+
+func TestFileExporter(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "spans.json")
+	exporter, err := NewFileExporter(filePath)
+	require.NoError(t, err)
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	_, child := provider.Tracer("test").Start(ctx, "child")
+	EndSpan(child, errors.New("hook failed"))
+	parent.End()
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	file, err := os.Open(filePath)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var spans []fileSpan
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var span fileSpan
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, "Error", spans[0].Status)
+	assert.Equal(t, "hook failed", spans[0].StatusDescription)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, spans[1].TraceID, spans[0].TraceID)
+	assert.Equal(t, "parent", spans[1].Name)
+	assert.Empty(t, spans[1].ParentSpanID)
+}
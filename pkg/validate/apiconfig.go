@@ -2,12 +2,21 @@ package validate
 
 import (
 	"github.com/wundergraph/wundergraph/pkg/apihandler"
+	"github.com/wundergraph/wundergraph/pkg/hooks"
 	"github.com/wundergraph/wundergraph/pkg/loadvariable"
 )
 
 // ApiConfig validates the Api
 func ApiConfig(api *apihandler.Api) (valid bool, messages []string) {
 
+	if api.Options != nil {
+		for hook, policy := range api.Options.Hooks.Policies {
+			if err := hooks.ValidatePolicy(hook, policy); err != nil {
+				return false, []string{err.Error()}
+			}
+		}
+	}
+
 	if !api.HasCookieAuthEnabled() {
 		// skip following tests if cookie auth is not enabled
 		// in this case, we don't have to check it
@@ -0,0 +1,26 @@
+;; hooks.wasm is assembled from this module, e.g. with wat2wasm hooks.wat
+(module
+  (memory (export "memory") 1)
+  (data (i32.const 0) "{\"response\":{\"data\":{\"wasm\":true}}}")
+
+  ;; payloads are always written to the same location
+  (func (export "malloc") (param $size i32) (result i32)
+    i32.const 1024)
+
+  ;; mutatingPreResolve returns the payload, so the input stays as it is
+  (func (export "mutatingPreResolve") (param $ptr i32) (param $len i32) (result i64)
+    local.get $ptr
+    i64.extend_i32_u
+    i64.const 32
+    i64.shl
+    local.get $len
+    i64.extend_i32_u
+    i64.or)
+
+  ;; mutatingPostResolve replaces the response with the data segment
+  (func (export "mutatingPostResolve") (param $ptr i32) (param $len i32) (result i64)
+    i64.const 35)
+
+  (func (export "postResolve") (param $ptr i32) (param $len i32) (result i64)
+    unreachable)
+)
@@ -0,0 +1,240 @@
+// Package wasmhooks runs the preResolve, mutatingPreResolve, postResolve, mutatingPostResolve
+// and onOriginRequest hooks of operations in sandboxed WebAssembly modules.
+//
+// Modules exchange the JSON documents the hooks server receives and returns.
+// A module exports its linear memory as "memory", an allocator "malloc(size i32) i32"
+// and a function per implemented hook, e.g. "preResolve(ptr i32, len i32) i64".
+// Hook functions receive the payload written to memory allocated with malloc and return
+// the location of the response packed into an i64, the pointer in the upper and the length in the lower 32 bits.
+// If the module exports "free(ptr i32, len i32)", payloads and responses are freed after each call.
+package wasmhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/buger/jsonparser"
+	"github.com/jensneuse/abstractlogger"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/wundergraph/wundergraph/pkg/hooks"
+)
+
+type Options struct {
+	// MemoryLimitPages limits the memory of each module instance in pages of 64KiB, defaults to 256 (16MiB)
+	MemoryLimitPages uint32
+	// MaxIdleInstances is the number of instances kept per module for subsequent calls, defaults to 16
+	MaxIdleInstances int
+}
+
+// Hooks calls the hooks of operations configured with a WebAssembly module
+type Hooks struct {
+	hooks.UnimplementedInProcessHooks
+
+	runtime wazero.Runtime
+	// modules by operation name
+	modules map[string]*module
+	log     abstractlogger.Logger
+}
+
+// New compiles the modules, keyed by the name of the operation whose hooks they implement.
+// Operations can share a module, it's only compiled once.
+func New(ctx context.Context, modules map[string][]byte, options Options, log abstractlogger.Logger) (*Hooks, error) {
+	if options.MemoryLimitPages == 0 {
+		options.MemoryLimitPages = 256
+	}
+	if options.MaxIdleInstances <= 0 {
+		options.MaxIdleInstances = 16
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(options.MemoryLimitPages).
+		// stop modules once the request calling the hook is canceled
+		WithCloseOnContextDone(true),
+	)
+	h := &Hooks{
+		runtime: runtime,
+		modules: make(map[string]*module, len(modules)),
+		log:     log,
+	}
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, err
+	}
+
+	compiled := map[string]*module{}
+	for operationName, binary := range modules {
+		key := string(binary)
+		m, ok := compiled[key]
+		if !ok {
+			var err error
+			m, err = newModule(ctx, runtime, binary, options.MaxIdleInstances)
+			if err != nil {
+				_ = runtime.Close(ctx)
+				return nil, fmt.Errorf("wasm module of operation %s: %w", operationName, err)
+			}
+			compiled[key] = m
+		}
+		h.modules[operationName] = m
+	}
+	return h, nil
+}
+
+// Close releases all modules
+func (h *Hooks) Close(ctx context.Context) error {
+	return h.runtime.Close(ctx)
+}
+
+func (h *Hooks) PreResolve(ctx context.Context, operationName string, payload []byte) (*hooks.MiddlewareHookResponse, error) {
+	return h.call(ctx, operationName, hooks.PreResolve, payload)
+}
+
+func (h *Hooks) MutatingPreResolve(ctx context.Context, operationName string, payload []byte) (*hooks.MiddlewareHookResponse, error) {
+	return h.call(ctx, operationName, hooks.MutatingPreResolve, payload)
+}
+
+func (h *Hooks) PostResolve(ctx context.Context, operationName string, payload []byte) (*hooks.MiddlewareHookResponse, error) {
+	return h.call(ctx, operationName, hooks.PostResolve, payload)
+}
+
+func (h *Hooks) MutatingPostResolve(ctx context.Context, operationName string, payload []byte) (*hooks.MiddlewareHookResponse, error) {
+	return h.call(ctx, operationName, hooks.MutatingPostResolve, payload)
+}
+
+// OnOriginRequest is called for the operation named by the payload
+func (h *Hooks) OnOriginRequest(ctx context.Context, payload []byte) (*hooks.MiddlewareHookResponse, error) {
+	operationName, err := jsonparser.GetString(payload, "operationName")
+	if err != nil {
+		return nil, hooks.ErrHookNotImplemented
+	}
+	return h.call(ctx, operationName, hooks.HttpTransportOnRequest, payload)
+}
+
+func (h *Hooks) call(ctx context.Context, operationName string, hook hooks.MiddlewareHook, payload []byte) (*hooks.MiddlewareHookResponse, error) {
+	m, ok := h.modules[operationName]
+	if !ok || !m.exports[string(hook)] {
+		return nil, hooks.ErrHookNotImplemented
+	}
+	response, err := m.call(ctx, string(hook), payload)
+	if err != nil {
+		h.log.Debug("wasm hook failed",
+			abstractlogger.String("operationName", operationName),
+			abstractlogger.String("hook", string(hook)),
+			abstractlogger.Error(err),
+		)
+		return nil, fmt.Errorf("wasm middleware hook %s failed: %w", string(hook), err)
+	}
+	hookRes := &hooks.MiddlewareHookResponse{}
+	if err := json.Unmarshal(response, hookRes); err != nil {
+		return nil, fmt.Errorf("response of wasm middleware hook %s could not be decoded: %w", string(hook), err)
+	}
+	return hookRes, nil
+}
+
+type module struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	exports  map[string]bool
+	// instances are idle instances, modules aren't safe for concurrent use
+	instances chan api.Module
+}
+
+func newModule(ctx context.Context, runtime wazero.Runtime, binary []byte, maxIdleInstances int) (*module, error) {
+	compiled, err := runtime.CompileModule(ctx, binary)
+	if err != nil {
+		return nil, err
+	}
+	m := &module{
+		runtime:   runtime,
+		compiled:  compiled,
+		exports:   map[string]bool{},
+		instances: make(chan api.Module, maxIdleInstances),
+	}
+	for name := range compiled.ExportedFunctions() {
+		m.exports[name] = true
+	}
+	if _, ok := compiled.ExportedMemories()["memory"]; !ok {
+		return nil, errors.New(`module must export its memory as "memory"`)
+	}
+	if !m.exports["malloc"] {
+		return nil, errors.New(`module must export "malloc"`)
+	}
+	return m, nil
+}
+
+func (m *module) instance(ctx context.Context) (api.Module, error) {
+	select {
+	case instance := <-m.instances:
+		return instance, nil
+	default:
+		// an empty name allows instantiating the module multiple times,
+		// reactor modules, e.g. built with TinyGo, are initialized by _initialize
+		return m.runtime.InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().
+			WithName("").
+			WithStartFunctions("_initialize"),
+		)
+	}
+}
+
+func (m *module) release(ctx context.Context, instance api.Module) {
+	select {
+	case m.instances <- instance:
+	default:
+		_ = instance.Close(ctx)
+	}
+}
+
+func (m *module) call(ctx context.Context, function string, payload []byte) ([]byte, error) {
+	// instances run with the context they are instantiated with until their start functions returned,
+	// so they must not be bound to the request creating them
+	instance, err := m.instance(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	response, err := m.callInstance(ctx, instance, function, payload)
+	if err != nil {
+		// the instance might be left in an inconsistent state, e.g. after a trap
+		_ = instance.Close(context.Background())
+		return nil, err
+	}
+	m.release(ctx, instance)
+	return response, nil
+}
+
+func (m *module) callInstance(ctx context.Context, instance api.Module, function string, payload []byte) ([]byte, error) {
+	results, err := instance.ExportedFunction("malloc").Call(ctx, uint64(len(payload)))
+	if err != nil {
+		return nil, err
+	}
+	payloadPtr := uint32(results[0])
+	if !instance.Memory().Write(payloadPtr, payload) {
+		return nil, errors.New("malloc returned memory out of range")
+	}
+
+	results, err = instance.ExportedFunction(function).Call(ctx, uint64(payloadPtr), uint64(len(payload)))
+	if err != nil {
+		return nil, err
+	}
+	responsePtr, responseLen := uint32(results[0]>>32), uint32(results[0])
+	view, ok := instance.Memory().Read(responsePtr, responseLen)
+	if !ok {
+		return nil, errors.New("response out of memory range")
+	}
+	// the view is only valid until the next call
+	response := make([]byte, len(view))
+	copy(response, view)
+
+	if free := instance.ExportedFunction("free"); free != nil {
+		if _, err := free.Call(ctx, uint64(payloadPtr), uint64(len(payload))); err != nil {
+			return nil, err
+		}
+		if _, err := free.Call(ctx, uint64(responsePtr), uint64(responseLen)); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,73 @@
+package wasmhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/jensneuse/abstractlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/wundergraph/pkg/hooks"
+)
+
+func TestHooks(t *testing.T) {
+	binary, err := os.ReadFile("testdata/hooks.wasm")
+	require.NoError(t, err)
+
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = w.Write([]byte(`{"response":{}}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	wasmHooks, err := New(ctx, map[string][]byte{"Users": binary, "Posts": binary}, Options{}, abstractlogger.NoopLogger)
+	require.NoError(t, err)
+	defer wasmHooks.Close(ctx)
+	assert.Same(t, wasmHooks.modules["Users"], wasmHooks.modules["Posts"])
+
+	client := hooks.NewClient(srv.URL, abstractlogger.NoopLogger,
+		hooks.WithInProcessHooks(wasmHooks),
+		hooks.WithCircuitBreaker(hooks.CircuitBreakerOptions{Disabled: true}),
+	)
+
+	for i := 0; i < 3; i++ {
+		res, err := client.DoOperationRequest(ctx, "Users", hooks.MutatingPreResolve, []byte(`{"input":{"id":1}}`))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"id":1}`, string(res.Input))
+	}
+
+	res, err := client.DoOperationRequest(ctx, "Posts", hooks.MutatingPostResolve, []byte(`{"response":{"data":{}}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"wasm":true}}`, string(res.Response))
+
+	// traps fail the hook, the instance is replaced
+	_, err = client.DoOperationRequest(ctx, "Users", hooks.PostResolve, []byte(`{}`))
+	assert.ErrorContains(t, err, "wasm middleware hook postResolve failed")
+	res, err = client.DoOperationRequest(ctx, "Users", hooks.MutatingPreResolve, []byte(`{"input":{"id":2}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":2}`, string(res.Input))
+
+	// hooks the module doesn't export and operations without a module are sent to the hooks server
+	_, err = client.DoOperationRequest(ctx, "Users", hooks.PreResolve, []byte(`{}`))
+	assert.NoError(t, err)
+	_, err = client.DoOperationRequest(ctx, "Comments", hooks.MutatingPostResolve, []byte(`{}`))
+	assert.NoError(t, err)
+	_, err = client.DoGlobalRequest(ctx, hooks.HttpTransportOnRequest, []byte(`{"operationName":"Users"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"/operation/Users/preResolve",
+		"/operation/Comments/mutatingPostResolve",
+		"/global/httpTransport/onOriginRequest",
+	}, paths)
+}
+
+func TestNew_InvalidModule(t *testing.T) {
+	_, err := New(context.Background(), map[string][]byte{"Users": []byte("not wasm")}, Options{}, abstractlogger.NoopLogger)
+	assert.ErrorContains(t, err, "wasm module of operation Users")
+}